@@ -0,0 +1,388 @@
+// Package client is a typed Go client for the player API of a ggpoker node,
+// as described by its OpenAPI document.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/anthdm/ggpoker/p2p"
+	"github.com/gorilla/websocket"
+)
+
+// Error is returned when the API responds with an error.
+type Error struct {
+	StatusCode int
+	Code       p2p.ErrorCode
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+// Client takes the actions of a player through the API of its node.
+type Client struct {
+	// baseURL is like http://localhost:3001.
+	baseURL string
+	// prefix is the path of the table, empty for the first table of the node.
+	prefix string
+	token  string
+	http   *http.Client
+	// Retries is the number of times an action is sent again when the API
+	// could not be reached. Retries carry the same idempotency key, so an
+	// action is never taken twice.
+	Retries int
+}
+
+// New returns a client for the API at the given address, like :3001 or
+// http://localhost:3001, that authenticates with the given token.
+func New(addr, token string) *Client {
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+
+	return &Client{
+		baseURL: strings.TrimSuffix(addr, "/"),
+		token:   token,
+		http:    http.DefaultClient,
+		Retries: 2,
+	}
+}
+
+// Table returns a client for the table with the given ID, when the node plays
+// at several tables.
+func (c *Client) Table(id string) *Client {
+	table := *c
+	table.prefix = "/tables/" + url.PathEscape(id)
+	return &table
+}
+
+// Tables returns the tables the node plays at.
+func (c *Client) Tables(ctx context.Context) ([]p2p.TableInfo, error) {
+	node := *c
+	node.prefix = ""
+	tables := []p2p.TableInfo{}
+	if err := node.request(ctx, http.MethodGet, "/tables", &tables); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+func (c *Client) State(ctx context.Context) (*p2p.State, error) {
+	return c.do(ctx, http.MethodGet, "/state")
+}
+
+func (c *Client) Ready(ctx context.Context) (*p2p.State, error) {
+	return c.do(ctx, http.MethodPost, "/ready")
+}
+
+func (c *Client) Fold(ctx context.Context) (*p2p.State, error) {
+	return c.do(ctx, http.MethodPost, "/fold")
+}
+
+func (c *Client) Check(ctx context.Context) (*p2p.State, error) {
+	return c.do(ctx, http.MethodPost, "/check")
+}
+
+func (c *Client) Call(ctx context.Context) (*p2p.State, error) {
+	return c.do(ctx, http.MethodPost, "/call")
+}
+
+// Bet bets or raises, value is the amount we put in the pot with this bet.
+func (c *Client) Bet(ctx context.Context, value int) (*p2p.State, error) {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/bet/%d", value))
+}
+
+// Ledger returns the buy-ins and cash-outs of every player at the table.
+func (c *Client) Ledger(ctx context.Context) (*p2p.Ledger, error) {
+	ledger := &p2p.Ledger{}
+	if err := c.request(ctx, http.MethodGet, "/ledger", ledger); err != nil {
+		return nil, err
+	}
+	return ledger, nil
+}
+
+// Tournament returns the blind level, the prize pool and the finishing places
+// of the tournament played at the table.
+func (c *Client) Tournament(ctx context.Context) (*p2p.TournamentState, error) {
+	tournament := &p2p.TournamentState{}
+	if err := c.request(ctx, http.MethodGet, "/tournament", tournament); err != nil {
+		return nil, err
+	}
+	return tournament, nil
+}
+
+// Equities returns what the stacks of the players that are still in the
+// tournament are worth of the prizes that are left.
+func (c *Client) Equities(ctx context.Context) ([]p2p.Equity, error) {
+	equities := []p2p.Equity{}
+	if err := c.request(ctx, http.MethodGet, "/tournament/equity", &equities); err != nil {
+		return nil, err
+	}
+	return equities, nil
+}
+
+// ProposeDeal proposes to split the prizes that are left by the given method.
+func (c *Client) ProposeDeal(ctx context.Context, method p2p.DealMethod) (*p2p.State, error) {
+	return c.do(ctx, http.MethodPost, "/deal/propose/"+strings.ToLower(string(method)))
+}
+
+// AcceptDeal accepts the deal that is proposed.
+func (c *Client) AcceptDeal(ctx context.Context) (*p2p.State, error) {
+	return c.do(ctx, http.MethodPost, "/deal/accept")
+}
+
+// DeclineDeal declines the deal that is proposed.
+func (c *Client) DeclineDeal(ctx context.Context) (*p2p.State, error) {
+	return c.do(ctx, http.MethodPost, "/deal/decline")
+}
+
+// BuyIn sits us down with the given amount of chips.
+func (c *Client) BuyIn(ctx context.Context, amount int) (*p2p.State, error) {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/buyin/%d", amount))
+}
+
+// Rebuy buys a new stack after we lost all our chips.
+func (c *Client) Rebuy(ctx context.Context, amount int) (*p2p.State, error) {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/rebuy/%d", amount))
+}
+
+// TopUp adds chips to our stack between hands.
+func (c *Client) TopUp(ctx context.Context, amount int) (*p2p.State, error) {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/topup/%d", amount))
+}
+
+// SitOut keeps our seat, but we are not dealt in from the next hand on.
+func (c *Client) SitOut(ctx context.Context) (*p2p.State, error) {
+	return c.do(ctx, http.MethodPost, "/sitout")
+}
+
+// SitIn deals us in again. When we missed the blinds we post a big blind, or
+// with waitForBB we wait until the big blind reaches our seat.
+func (c *Client) SitIn(ctx context.Context, waitForBB bool) (*p2p.State, error) {
+	if waitForBB {
+		return c.do(ctx, http.MethodPost, "/sitin/wait")
+	}
+	return c.do(ctx, http.MethodPost, "/sitin")
+}
+
+// CashOut takes our chips off the table and frees our seat.
+func (c *Client) CashOut(ctx context.Context) (*p2p.State, error) {
+	return c.do(ctx, http.MethodPost, "/cashout")
+}
+
+// TakeSeat reserves the seat for us until we are ready.
+func (c *Client) TakeSeat(ctx context.Context, seat int) (*p2p.State, error) {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/seat/%d", seat))
+}
+
+func (c *Client) Straddle(ctx context.Context) (*p2p.State, error) {
+	return c.do(ctx, http.MethodPost, "/straddle")
+}
+
+func (c *Client) VoteBombPot(ctx context.Context) (*p2p.State, error) {
+	return c.do(ctx, http.MethodPost, "/bombpot")
+}
+
+// SetRuns sets the number of times we want to run the board when all-in.
+func (c *Client) SetRuns(ctx context.Context, runs int) (*p2p.State, error) {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/runs/%d", runs))
+}
+
+// Draw discards the hole cards at the given indexes in a draw game, without
+// cards we stand pat.
+func (c *Client) Draw(ctx context.Context, cards ...int) (*p2p.State, error) {
+	if len(cards) == 0 {
+		return c.do(ctx, http.MethodPost, "/draw")
+	}
+
+	indexes := make([]string, len(cards))
+	for i, card := range cards {
+		indexes[i] = strconv.Itoa(card)
+	}
+	return c.do(ctx, http.MethodPost, "/draw/"+strings.Join(indexes, ","))
+}
+
+func (c *Client) do(ctx context.Context, method, path string) (*p2p.State, error) {
+	state := &p2p.State{}
+	if err := c.request(ctx, method, path, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// request sends the request and decodes the response into v.
+func (c *Client) request(ctx context.Context, method, path string, v any) error {
+	return c.send(ctx, method, path, nil, v)
+}
+
+// send sends the request with the body encoded as JSON, when it is not nil,
+// and decodes the response into v.
+func (c *Client) send(ctx context.Context, method, path string, body, v any) error {
+	var b []byte
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		b = encoded
+	}
+
+	// Every action gets its own key, so retrying it is safe.
+	key := ""
+	if method == http.MethodPost {
+		k, err := newIdempotencyKey()
+		if err != nil {
+			return err
+		}
+		key = k
+	}
+
+	var (
+		resp *http.Response
+		err  error
+	)
+	for i := 0; i <= c.Retries; i++ {
+		req, reqErr := http.NewRequestWithContext(ctx, method, c.baseURL+c.prefix+path, bytes.NewReader(b))
+		if reqErr != nil {
+			return reqErr
+		}
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+
+		resp, err = c.http.Do(req)
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp p2p.ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+			return &Error{StatusCode: resp.StatusCode, Code: p2p.ErrCodeInternal, Message: resp.Status}
+		}
+		return &Error{StatusCode: resp.StatusCode, Code: errResp.Code, Message: errResp.Error}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Events streams the events of the game until the context is done. The first
+// event is a snapshot of the State. The Data of every event holds the typed
+// payload, like a *p2p.ActionEvent for an action that was taken.
+func (c *Client) Events(ctx context.Context) (<-chan p2p.Event, error) {
+	url := "ws" + strings.TrimPrefix(c.baseURL, "http") + c.prefix + "/ws"
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+c.token)
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+	if err != nil {
+		if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			return nil, &Error{StatusCode: resp.StatusCode, Code: p2p.ErrCodeUnauthorized, Message: err.Error()}
+		}
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	events := make(chan p2p.Event)
+	go func() {
+		defer close(events)
+		for {
+			var raw struct {
+				Type p2p.EventType   `json:"type"`
+				Data json.RawMessage `json:"data"`
+			}
+			if err := conn.ReadJSON(&raw); err != nil {
+				return
+			}
+
+			event, err := decodeEvent(raw.Type, raw.Data)
+			if err != nil {
+				continue
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+func decodeEvent(t p2p.EventType, data json.RawMessage) (p2p.Event, error) {
+	var v any
+	switch t {
+	case p2p.EventSnapshot:
+		v = &p2p.State{}
+	case p2p.EventPlayerJoined, p2p.EventPlayerLeft, p2p.EventPlayerReady, p2p.EventPlayerSatOut, p2p.EventPlayerSatIn,
+		p2p.EventDealAccepted, p2p.EventDealDeclined:
+		v = &p2p.PlayerEvent{}
+	case p2p.EventSeatReserved, p2p.EventSeatReleased:
+		v = &p2p.SeatEvent{}
+	case p2p.EventLedgerEntry:
+		v = &p2p.LedgerEntry{}
+	case p2p.EventPlayerEliminated:
+		v = &p2p.TournamentResult{}
+	case p2p.EventTournamentFinished:
+		v = &p2p.TournamentFinishedEvent{}
+	case p2p.EventPlayerMoved:
+		v = &p2p.PlayerMove{}
+	case p2p.EventDealProposed, p2p.EventDealWithdrawn:
+		v = &p2p.Deal{}
+	case p2p.EventCardsDealt:
+		v = &p2p.CardsDealtEvent{}
+	case p2p.EventActionTaken:
+		v = &p2p.ActionEvent{}
+	case p2p.EventStreetAdvanced:
+		v = &p2p.StreetEvent{}
+	case p2p.EventShowdown:
+		v = &p2p.ShowdownEvent{}
+	case p2p.EventPotAwarded:
+		v = &p2p.PotAwardedEvent{}
+	default:
+		return p2p.Event{}, fmt.Errorf("unknown event type (%s)", t)
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, v); err != nil {
+			return p2p.Event{}, err
+		}
+	}
+	return p2p.Event{Type: t, Data: v}, nil
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anthdm/ggpoker/p2p"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	cfg := p2p.ServerConfig{
+		ListenAddr: ":3000",
+		TableID:    "main",
+		Rotation:   p2p.Rotation{Games: []p2p.Game{{GameVariant: p2p.TexasHoldem}}},
+		TableOptions: p2p.TableOptions{
+			StartingStack: 1000,
+			MaxBuyIn:      1000,
+		},
+	}
+	game := p2p.NewGame(cfg, make(chan p2p.BroadcastTo, 10))
+	srv := httptest.NewServer(p2p.NewAPIServer(":3001", "token", game).Handler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClientActions(t *testing.T) {
+	srv := newTestServer(t)
+	c := New(srv.URL, "token")
+	ctx := context.Background()
+
+	state, err := c.State(ctx)
+	assert.Nil(t, err)
+	assert.Contains(t, state.Game, "TEXAS HOLDEM")
+
+	_, err = c.Bet(ctx, 200)
+	var apiErr *Error
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+	assert.Equal(t, p2p.ErrCodeWrongGameStatus, apiErr.Code)
+
+	_, err = New(srv.URL, "wrong").State(ctx)
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, p2p.ErrCodeUnauthorized, apiErr.Code)
+}
+
+func TestClientTables(t *testing.T) {
+	srv := newTestServer(t)
+	c := New(srv.URL, "token")
+	ctx := context.Background()
+
+	tables, err := c.Table("main").Tables(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(tables))
+	assert.Equal(t, "main", tables[0].ID)
+
+	state, err := c.Table("main").State(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "main", state.Table)
+
+	_, err = c.Table("vip").State(ctx)
+	var apiErr *Error
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, p2p.ErrCodeUnknownTable, apiErr.Code)
+}
+
+func TestClientEvents(t *testing.T) {
+	srv := newTestServer(t)
+	c := New(srv.URL, "token")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	events, err := c.Events(ctx)
+	assert.Nil(t, err)
+
+	snapshot := <-events
+	assert.Equal(t, p2p.EventSnapshot, snapshot.Type)
+	assert.IsType(t, &p2p.State{}, snapshot.Data)
+
+	_, err = c.Ready(ctx)
+	assert.Nil(t, err)
+
+	buyIn := <-events
+	assert.Equal(t, p2p.EventLedgerEntry, buyIn.Type)
+	assert.Equal(t, 1000, buyIn.Data.(*p2p.LedgerEntry).Amount)
+
+	ready := <-events
+	assert.Equal(t, p2p.EventPlayerReady, ready.Type)
+	assert.Equal(t, ":3000", ready.Data.(*p2p.PlayerEvent).Addr)
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/anthdm/ggpoker/p2p"
+)
+
+// Lobby finds, creates and joins tables through the API of a lobby.
+type Lobby struct {
+	c *Client
+}
+
+// NewLobby returns a client for the lobby at the given address, like :3100 or
+// http://lobby.example.com. The token is the API token of the node the lobby
+// is embedded in, it is only needed to create and join tables.
+func NewLobby(addr, token string) *Lobby {
+	return &Lobby{c: New(addr, token)}
+}
+
+// Tables returns the listed tables that match the filter.
+func (l *Lobby) Tables(ctx context.Context, f p2p.LobbyFilter) ([]p2p.Listing, error) {
+	q := url.Values{}
+	if f.Variant != nil {
+		q.Set("game", f.Variant.String())
+	}
+	if f.Limit != nil {
+		q.Set("limit", f.Limit.String())
+	}
+	if f.MinBigBlind > 0 {
+		q.Set("minBigBlind", strconv.Itoa(f.MinBigBlind))
+	}
+	if f.MaxBigBlind > 0 {
+		q.Set("maxBigBlind", strconv.Itoa(f.MaxBigBlind))
+	}
+	if f.Open {
+		q.Set("open", "true")
+	}
+
+	path := "/tables"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	tables := []p2p.Listing{}
+	if err := l.c.request(ctx, http.MethodGet, path, &tables); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// CreateTable starts a new table on the node of the lobby.
+func (l *Lobby) CreateTable(ctx context.Context, cfg p2p.ServerConfig) (*p2p.Listing, error) {
+	listing := &p2p.Listing{}
+	if err := l.c.send(ctx, http.MethodPost, "/tables", cfg, listing); err != nil {
+		return nil, err
+	}
+	return listing, nil
+}
+
+// JoinTable joins the listed table with the node of the lobby.
+func (l *Lobby) JoinTable(ctx context.Context, host, id string) (*p2p.Listing, error) {
+	listing := &p2p.Listing{}
+	path := "/tables/" + url.PathEscape(host) + "/" + url.PathEscape(id) + "/join"
+	if err := l.c.request(ctx, http.MethodPost, path, listing); err != nil {
+		return nil, err
+	}
+	return listing, nil
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anthdm/ggpoker/p2p"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLobby(t *testing.T) {
+	lobby := p2p.NewLobby("token", nil)
+	srv := httptest.NewServer(lobby.Handler())
+	t.Cleanup(srv.Close)
+
+	lobby.Announce(p2p.Listing{
+		ID:       "plo",
+		Host:     "a.example.com:3000",
+		BigBlind: 20,
+		Config: p2p.ServerConfig{
+			TableID:  "plo",
+			Rotation: p2p.Rotation{Games: []p2p.Game{{GameVariant: p2p.Omaha}}},
+		},
+	})
+
+	l := NewLobby(srv.URL, "token")
+	ctx := context.Background()
+	omaha, razz := p2p.Omaha, p2p.Razz
+
+	tables, err := l.Tables(ctx, p2p.LobbyFilter{Variant: &omaha, MinBigBlind: 20})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(tables))
+	assert.Equal(t, p2p.Omaha, tables[0].Config.Rotation.Games[0].GameVariant)
+
+	tables, err = l.Tables(ctx, p2p.LobbyFilter{Variant: &razz})
+	assert.Nil(t, err)
+	assert.Empty(t, tables)
+
+	// A standalone lobby does not host tables.
+	_, err = l.CreateTable(ctx, p2p.ServerConfig{TableID: "nlh"})
+	var apiErr *Error
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+
+	_, err = l.JoinTable(ctx, "a.example.com:3000", "plo")
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, p2p.ErrCodeNotAllowed, apiErr.Code)
+}
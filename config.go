@@ -0,0 +1,376 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anthdm/ggpoker/p2p"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultVersion = "GGPOKER V0.2-alpha"
+
+// Config is the configuration of a node. It is read from a YAML file and every
+// field can be overridden with a flag.
+type Config struct {
+	Version       string          `yaml:"version"`
+	ListenAddr    string          `yaml:"listenAddr"`
+	AdvertiseAddr string          `yaml:"advertiseAddr"`
+	APIListenAddr string          `yaml:"apiListenAddr"`
+	APIToken      string          `yaml:"apiToken"`
+	MaxPlayers    int             `yaml:"maxPlayers"`
+	MaxPeers      int             `yaml:"maxPeers"`
+	TableID       string          `yaml:"tableId"`
+	Game          p2p.GameVariant `yaml:"game"`
+	Betting       BettingConfig   `yaml:"betting"`
+	Rotation      RotationConfig  `yaml:"rotation"`
+	Table         TableConfig     `yaml:"table"`
+	// Tournament plays a sit-and-go instead of a cash game when it is
+	// enabled.
+	Tournament TournamentConfig `yaml:"tournament"`
+	// Bootstrap holds the addresses of the players the node connects to when
+	// it starts.
+	Bootstrap []string    `yaml:"bootstrap"`
+	Lobby     LobbyConfig `yaml:"lobby"`
+	// Coordinator enters the node in a multi-table tournament.
+	Coordinator CoordinatorConfig `yaml:"coordinator"`
+	// Tables are the other tables the node plays at. The listen addresses,
+	// the API token and the version of a table are the ones of the node.
+	Tables []Config `yaml:"tables"`
+}
+
+type LobbyConfig struct {
+	// Addr is the lobby the node announces its tables to.
+	Addr string `yaml:"addr"`
+	// ListenAddr serves a lobby embedded in the node when it is set.
+	ListenAddr string `yaml:"listenAddr"`
+}
+
+type CoordinatorConfig struct {
+	// Addr is the coordinator of the tournament the node registers for, the
+	// coordinator seats it at a table of the tournament.
+	Addr  string `yaml:"addr"`
+	Token string `yaml:"token"`
+	// ListenAddr is the address a coordinator started with coordinator run
+	// listens on.
+	ListenAddr string `yaml:"listenAddr"`
+}
+
+type BettingConfig struct {
+	Limit    p2p.Limit `yaml:"limit"`
+	SmallBet int       `yaml:"smallBet"`
+	BigBet   int       `yaml:"bigBet"`
+	RaiseCap int       `yaml:"raiseCap"`
+	BringIn  int       `yaml:"bringIn"`
+}
+
+func (c BettingConfig) structure() p2p.BettingStructure {
+	return p2p.BettingStructure{
+		Limit:    c.Limit,
+		SmallBet: c.SmallBet,
+		BigBet:   c.BigBet,
+		RaiseCap: c.RaiseCap,
+		BringIn:  c.BringIn,
+	}
+}
+
+type RotationConfig struct {
+	// Preset is a known rotation like horse or 8-game. It is played instead
+	// of the Games when it is set.
+	Preset   string        `yaml:"preset"`
+	Games    []GameConfig  `yaml:"games"`
+	Hands    int           `yaml:"hands"`
+	Duration time.Duration `yaml:"duration"`
+}
+
+type GameConfig struct {
+	Game    p2p.GameVariant `yaml:"game"`
+	Betting BettingConfig   `yaml:"betting"`
+}
+
+type TableConfig struct {
+	Ante            int           `yaml:"ante"`
+	Straddle        bool          `yaml:"straddle"`
+	BombPotAnte     int           `yaml:"bombPotAnte"`
+	StartingStack   int           `yaml:"startingStack"`
+	MinBuyIn        int           `yaml:"minBuyIn"`
+	MaxBuyIn        int           `yaml:"maxBuyIn"`
+	ActionTime      time.Duration `yaml:"actionTime"`
+	SeatReservation time.Duration `yaml:"seatReservation"`
+	MaxSitOutOrbits int           `yaml:"maxSitOutOrbits"`
+}
+
+type TournamentConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	BuyIn         int           `yaml:"buyIn"`
+	StartingStack int           `yaml:"startingStack"`
+	Players       int           `yaml:"players"`
+	Levels        []LevelConfig `yaml:"levels"`
+	LevelHands    int           `yaml:"levelHands"`
+	LevelDuration time.Duration `yaml:"levelDuration"`
+	Payouts       []int         `yaml:"payouts"`
+}
+
+type LevelConfig struct {
+	BigBlind int `yaml:"bigBlind"`
+	Ante     int `yaml:"ante"`
+}
+
+func (c TournamentConfig) tournament() *p2p.Tournament {
+	if !c.Enabled {
+		return nil
+	}
+
+	t := &p2p.Tournament{
+		BuyIn:         c.BuyIn,
+		StartingStack: c.StartingStack,
+		Players:       c.Players,
+		LevelHands:    c.LevelHands,
+		LevelDuration: c.LevelDuration,
+		Payouts:       c.Payouts,
+	}
+	for _, lc := range c.Levels {
+		t.Levels = append(t.Levels, p2p.BlindLevel{BigBlind: lc.BigBlind, Ante: lc.Ante})
+	}
+	return t
+}
+
+// parseLevels parses a blind schedule like 20,40,60:5,100:10 where a level is
+// the big blind, optionally followed by the ante.
+func parseLevels(s string) ([]LevelConfig, error) {
+	levels := []LevelConfig{}
+	for _, part := range strings.Split(s, ",") {
+		bb, ante, hasAnte := strings.Cut(strings.TrimSpace(part), ":")
+		level := LevelConfig{}
+		if _, err := fmt.Sscan(bb, &level.BigBlind); err != nil {
+			return nil, fmt.Errorf("invalid big blind (%s)", bb)
+		}
+		if hasAnte {
+			if _, err := fmt.Sscan(ante, &level.Ante); err != nil {
+				return nil, fmt.Errorf("invalid ante (%s)", ante)
+			}
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+// parsePayouts parses percentages of the prize pool like 50,30,20.
+func parsePayouts(s string) ([]int, error) {
+	payouts := []int{}
+	for _, part := range strings.Split(s, ",") {
+		var pct int
+		if _, err := fmt.Sscan(strings.TrimSpace(part), &pct); err != nil {
+			return nil, fmt.Errorf("invalid payout (%s)", part)
+		}
+		payouts = append(payouts, pct)
+	}
+	if err := p2p.ValidatePayouts(payouts); err != nil {
+		return nil, err
+	}
+	return payouts, nil
+}
+
+func defaultConfig() Config {
+	return Config{
+		Version:       defaultVersion,
+		ListenAddr:    ":3000",
+		APIListenAddr: ":3001",
+	}
+}
+
+// loadConfig reads the YAML config file at the given path.
+func loadConfig(path string, cfg *Config) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(b, cfg); err != nil {
+		return fmt.Errorf("invalid config file (%s): %w", path, err)
+	}
+	return nil
+}
+
+// ServerConfig returns the config the server of the node is started with.
+func (c Config) ServerConfig() (p2p.ServerConfig, error) {
+	if c.Tournament.Enabled {
+		if err := p2p.ValidatePayouts(c.Tournament.Payouts); err != nil {
+			return p2p.ServerConfig{}, fmt.Errorf("tournament: %w", err)
+		}
+	}
+
+	cfg := p2p.ServerConfig{
+		Version:          c.Version,
+		ListenAddr:       c.ListenAddr,
+		AdvertiseAddr:    c.AdvertiseAddr,
+		APIListenAddr:    c.APIListenAddr,
+		APIToken:         c.APIToken,
+		GameVariant:      c.Game,
+		BettingStructure: c.Betting.structure(),
+		MaxPlayers:       c.MaxPlayers,
+		MaxPeers:         c.MaxPeers,
+		TableID:          c.TableID,
+		BootstrapPeers:   c.Bootstrap,
+		LobbyAddr:        c.Lobby.Addr,
+		LobbyListenAddr:  c.Lobby.ListenAddr,
+		Tournament:       c.Tournament.tournament(),
+		TableOptions: p2p.TableOptions{
+			Ante:            c.Table.Ante,
+			Straddle:        c.Table.Straddle,
+			BombPotAnte:     c.Table.BombPotAnte,
+			StartingStack:   c.Table.StartingStack,
+			MinBuyIn:        c.Table.MinBuyIn,
+			MaxBuyIn:        c.Table.MaxBuyIn,
+			ActionTime:      c.Table.ActionTime,
+			SeatReservation: c.Table.SeatReservation,
+			MaxSitOutOrbits: c.Table.MaxSitOutOrbits,
+		},
+	}
+
+	if c.Coordinator.Addr != "" {
+		cfg.Coordinator = p2p.NewRemoteCoordinator(c.Coordinator.Addr, c.Coordinator.Token)
+	}
+
+	for _, tc := range c.Tables {
+		table, err := tc.ServerConfig()
+		if err != nil {
+			return cfg, fmt.Errorf("table (%s): %w", tc.TableID, err)
+		}
+		cfg.Tables = append(cfg.Tables, table)
+	}
+
+	if c.Rotation.Preset != "" {
+		rotation, err := p2p.ParseRotation(c.Rotation.Preset, c.Rotation.Hands)
+		if err != nil {
+			return cfg, err
+		}
+		rotation.Duration = c.Rotation.Duration
+		cfg.Rotation = rotation
+		return cfg, nil
+	}
+
+	for _, gc := range c.Rotation.Games {
+		cfg.Rotation.Games = append(cfg.Rotation.Games, p2p.Game{
+			GameVariant:      gc.Game,
+			BettingStructure: gc.Betting.structure(),
+		})
+	}
+	cfg.Rotation.Hands = c.Rotation.Hands
+	cfg.Rotation.Duration = c.Rotation.Duration
+
+	return cfg, nil
+}
+
+// nodeFlags loads the config file given with the -config flag and registers
+// the flags of every field of the config on the flag set. Flags override the
+// values of the config file.
+func nodeFlags(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := defaultConfig()
+	fs.String("config", "", "the YAML config file of the node")
+	if path := configPath(args); path != "" {
+		if err := loadConfig(path, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	fs.StringVar(&cfg.Version, "version", cfg.Version, "the protocol version, every player at the table needs the same")
+	fs.StringVar(&cfg.ListenAddr, "listen", cfg.ListenAddr, "the address the node listens on for other players")
+	fs.StringVar(&cfg.AdvertiseAddr, "advertise", cfg.AdvertiseAddr, "the address the other players reach the node on, like poker.example.com:3000, defaults to the listen address")
+	fs.StringVar(&cfg.APIListenAddr, "api", cfg.APIListenAddr, "the address of the player API")
+	fs.StringVar(&cfg.APIToken, "token", cfg.APIToken, "the API token, a random token is generated when empty")
+	fs.IntVar(&cfg.MaxPlayers, "max-players", cfg.MaxPlayers, "the number of seats at the table, at most 5 for 2-7 triple draw")
+	fs.IntVar(&cfg.MaxPeers, "max-peers", cfg.MaxPeers, "the maximum number of players to connect to, defaults to the other seats")
+	fs.StringVar(&cfg.TableID, "table-id", cfg.TableID, "the ID of the table, every player at the table needs the same, defaults to main")
+	fs.Func("game", "the game variant, like texas-holdem, omaha or razz", func(s string) error {
+		return cfg.Game.UnmarshalText([]byte(s))
+	})
+	fs.Func("limit", "the betting limit: no-limit, pot-limit or fixed-limit", func(s string) error {
+		return cfg.Betting.Limit.UnmarshalText([]byte(s))
+	})
+	fs.IntVar(&cfg.Betting.SmallBet, "small-bet", cfg.Betting.SmallBet, "the small bet, this is the big blind in no limit and pot limit")
+	fs.IntVar(&cfg.Betting.BigBet, "big-bet", cfg.Betting.BigBet, "the big bet of the later streets in fixed limit")
+	fs.IntVar(&cfg.Betting.RaiseCap, "raise-cap", cfg.Betting.RaiseCap, "the maximum number of raises in a street, zero means no cap")
+	fs.IntVar(&cfg.Betting.BringIn, "bring-in", cfg.Betting.BringIn, "the bring-in in stud games")
+	fs.Func("rotation", "a mixed game rotation: horse, 8-game or a comma separated list of games", func(s string) error {
+		if _, err := p2p.ParseRotation(s, 0); err == nil {
+			cfg.Rotation.Preset = s
+			return nil
+		}
+
+		cfg.Rotation.Preset = ""
+		cfg.Rotation.Games = nil
+		for _, name := range strings.Split(s, ",") {
+			gv, err := p2p.ParseGameVariant(name)
+			if err != nil {
+				return err
+			}
+			cfg.Rotation.Games = append(cfg.Rotation.Games, GameConfig{Game: gv})
+		}
+		return nil
+	})
+	fs.IntVar(&cfg.Rotation.Hands, "rotation-hands", cfg.Rotation.Hands, "the number of hands of each game in the rotation")
+	fs.DurationVar(&cfg.Rotation.Duration, "rotation-duration", cfg.Rotation.Duration, "rotate the games on a timer instead of after a number of hands")
+	fs.IntVar(&cfg.Table.Ante, "ante", cfg.Table.Ante, "the ante, zero means no ante")
+	fs.BoolVar(&cfg.Table.Straddle, "straddle", cfg.Table.Straddle, "allow straddles")
+	fs.IntVar(&cfg.Table.BombPotAnte, "bomb-pot-ante", cfg.Table.BombPotAnte, "the ante of a bomb pot, zero means no bomb pots")
+	fs.IntVar(&cfg.Table.StartingStack, "starting-stack", cfg.Table.StartingStack, "the chips a player buys in with when he does not choose an amount")
+	fs.IntVar(&cfg.Table.MinBuyIn, "min-buy-in", cfg.Table.MinBuyIn, "the smallest buy-in or rebuy, defaults to 20 big blinds")
+	fs.IntVar(&cfg.Table.MaxBuyIn, "max-buy-in", cfg.Table.MaxBuyIn, "the biggest buy-in or rebuy, defaults to the starting stack")
+	fs.DurationVar(&cfg.Table.ActionTime, "action-time", cfg.Table.ActionTime, "the time a player has to act, zero means no limit")
+	fs.DurationVar(&cfg.Table.SeatReservation, "seat-reservation", cfg.Table.SeatReservation, "the time a player that took a seat has to sit down, zero means reservations do not expire")
+	fs.IntVar(&cfg.Table.MaxSitOutOrbits, "max-sit-out-orbits", cfg.Table.MaxSitOutOrbits, "the orbits a player can sit out before he loses his seat, defaults to 3")
+	fs.BoolVar(&cfg.Tournament.Enabled, "tournament", cfg.Tournament.Enabled, "play a sit-and-go tournament instead of a cash game")
+	fs.IntVar(&cfg.Tournament.BuyIn, "tournament-buy-in", cfg.Tournament.BuyIn, "the buy-in of the tournament, defaults to the starting stack")
+	fs.IntVar(&cfg.Tournament.StartingStack, "tournament-stack", cfg.Tournament.StartingStack, "the chips every player starts the tournament with, defaults to 100 big blinds")
+	fs.IntVar(&cfg.Tournament.Players, "tournament-players", cfg.Tournament.Players, "the number of players the tournament starts with, defaults to the seats")
+	fs.Func("blind-levels", "the blind schedule of the tournament, big blinds with an optional ante like 20,40,60:5", func(s string) error {
+		levels, err := parseLevels(s)
+		if err != nil {
+			return err
+		}
+		cfg.Tournament.Levels = levels
+		return nil
+	})
+	fs.IntVar(&cfg.Tournament.LevelHands, "level-hands", cfg.Tournament.LevelHands, "the number of hands of each blind level, defaults to 10")
+	fs.DurationVar(&cfg.Tournament.LevelDuration, "level-duration", cfg.Tournament.LevelDuration, "raise the blinds on a timer instead of after a number of hands")
+	fs.Func("payouts", "the percentages of the prize pool paid to each place, like 50,30,20", func(s string) error {
+		payouts, err := parsePayouts(s)
+		if err != nil {
+			return err
+		}
+		cfg.Tournament.Payouts = payouts
+		return nil
+	})
+	fs.StringVar(&cfg.Lobby.Addr, "lobby", cfg.Lobby.Addr, "the address of the lobby to announce the tables of the node to, like lobby.example.com:3100")
+	fs.StringVar(&cfg.Lobby.ListenAddr, "lobby-listen", cfg.Lobby.ListenAddr, "serve a lobby in the node on this address, it can create and join tables for the node")
+	fs.StringVar(&cfg.Coordinator.Addr, "coordinator", cfg.Coordinator.Addr, "the address of the coordinator of a multi-table tournament to register for, like coordinator.example.com:3200")
+	fs.StringVar(&cfg.Coordinator.Token, "coordinator-token", cfg.Coordinator.Token, "the token of the coordinator")
+	fs.StringVar(&cfg.Coordinator.ListenAddr, "coordinator-listen", cfg.Coordinator.ListenAddr, "the address a coordinator started with coordinator run listens on, defaults to :3200")
+	fs.Func("bootstrap", "comma separated addresses of players to connect to, the others are discovered through them", func(s string) error {
+		cfg.Bootstrap = strings.Split(s, ",")
+		return nil
+	})
+
+	return &cfg, nil
+}
+
+// configPath returns the value of the -config flag in the arguments, it is
+// needed before the other flags are parsed.
+func configPath(args []string) string {
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anthdm/ggpoker/p2p"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigFileAndFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node.yaml")
+	err := os.WriteFile(path, []byte(`
+listenAddr: ":4000"
+game: omaha
+betting:
+  limit: pot-limit
+  smallBet: 20
+rotation:
+  games:
+    - game: razz
+    - game: omaha-hi-lo
+      betting:
+        limit: fixed-limit
+        smallBet: 40
+  hands: 3
+table:
+  ante: 5
+  actionTime: 15s
+`), 0o600)
+	assert.Nil(t, err)
+
+	args := []string{"-config", path, "-api", ":4001", "-ante", "10", "-straddle"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := nodeFlags(fs, args)
+	assert.Nil(t, err)
+	assert.Nil(t, fs.Parse(args))
+
+	serverCfg, err := cfg.ServerConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, defaultVersion, serverCfg.Version)
+	assert.Equal(t, ":4000", serverCfg.ListenAddr)
+	assert.Equal(t, ":4001", serverCfg.APIListenAddr)
+	assert.Equal(t, p2p.Omaha, serverCfg.GameVariant)
+	assert.Equal(t, p2p.BettingStructure{Limit: p2p.PotLimit, SmallBet: 20}, serverCfg.BettingStructure)
+	assert.Equal(t, p2p.TableOptions{Ante: 10, Straddle: true, ActionTime: 15 * time.Second}, serverCfg.TableOptions)
+	assert.Equal(t, 3, serverCfg.Rotation.Hands)
+	assert.Equal(t, []p2p.Game{
+		{GameVariant: p2p.Razz},
+		{GameVariant: p2p.OmahaHiLo, BettingStructure: p2p.BettingStructure{Limit: p2p.FixedLimit, SmallBet: 40}},
+	}, serverCfg.Rotation.Games)
+}
+
+func TestMaxPlayersFlag(t *testing.T) {
+	seats := func(args ...string) int {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		cfg, err := nodeFlags(fs, args)
+		assert.Nil(t, err)
+		assert.Nil(t, fs.Parse(args))
+		serverCfg, err := cfg.ServerConfig()
+		assert.Nil(t, err)
+		return serverCfg.Seats()
+	}
+	assert.Equal(t, 6, seats())
+	assert.Equal(t, 9, seats("-max-players", "9"))
+	assert.Equal(t, 5, seats("-max-players", "9", "-game", "2-7-triple-draw"))
+
+	// The players of a local cluster need to fit at the table.
+	assert.NotNil(t, runLocalCluster([]string{"-max-players", "3", "4"}))
+	assert.NotNil(t, runLocalCluster([]string{"-game", "2-7-triple-draw", "6"}))
+}
+
+func TestRotationFlag(t *testing.T) {
+	args := []string{"-rotation", "horse", "-rotation-hands", "8"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := nodeFlags(fs, args)
+	assert.Nil(t, err)
+	assert.Nil(t, fs.Parse(args))
+
+	serverCfg, err := cfg.ServerConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, p2p.HORSE(8), serverCfg.Rotation)
+
+	args = []string{"-rotation", "razz,2-7-triple-draw", "-game", "bridge"}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg, err = nodeFlags(fs, args)
+	assert.Nil(t, err)
+	assert.NotNil(t, fs.Parse(args))
+	assert.Equal(t, []GameConfig{{Game: p2p.Razz}, {Game: p2p.DeuceToSevenTripleDraw}}, cfg.Rotation.Games)
+}
+
+func TestExampleConfig(t *testing.T) {
+	cfg := defaultConfig()
+	assert.Nil(t, loadConfig("config.example.yaml", &cfg))
+
+	_, err := cfg.ServerConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, 30*time.Second, cfg.Table.ActionTime)
+}
+
+func TestTournamentFlags(t *testing.T) {
+	args := []string{"-tournament", "-tournament-buy-in", "500", "-blind-levels", "20,40,60:5", "-level-duration", "5m", "-payouts", "70,30"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := nodeFlags(fs, args)
+	assert.Nil(t, err)
+	assert.Nil(t, fs.Parse(args))
+
+	serverCfg, err := cfg.ServerConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, &p2p.Tournament{
+		BuyIn:         500,
+		Levels:        []p2p.BlindLevel{{BigBlind: 20}, {BigBlind: 40}, {BigBlind: 60, Ante: 5}},
+		LevelDuration: 5 * time.Minute,
+		Payouts:       []int{70, 30},
+	}, serverCfg.Tournament)
+
+	args = []string{"-blind-levels", "20,big"}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	_, err = nodeFlags(fs, args)
+	assert.Nil(t, err)
+	assert.NotNil(t, fs.Parse(args))
+
+	// Every place needs a share and together they can not pay more than the
+	// prize pool.
+	for _, bad := range []string{"70,40", "100,0", "110,-10"} {
+		args = []string{"-payouts", bad}
+		fs = flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		_, err = nodeFlags(fs, args)
+		assert.Nil(t, err)
+		assert.NotNil(t, fs.Parse(args), bad)
+	}
+
+	cfg.Tournament.Payouts = []int{60, 50}
+	_, err = cfg.ServerConfig()
+	assert.NotNil(t, err)
+}
+
+func TestTablesConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node.yaml")
+	err := os.WriteFile(path, []byte(`
+game: texas-holdem
+tables:
+  - tableId: plo
+    game: omaha
+    maxPlayers: 9
+    bootstrap: ["b.example.com:3000"]
+  - tableId: horse
+    rotation:
+      preset: horse
+`), 0o600)
+	assert.Nil(t, err)
+
+	args := []string{"-config", path, "-table-id", "nlh", "-lobby", "lobby.example.com:3100", "-coordinator", "coordinator.example.com:3200"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := nodeFlags(fs, args)
+	assert.Nil(t, err)
+	assert.Nil(t, fs.Parse(args))
+
+	serverCfg, err := cfg.ServerConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, "nlh", serverCfg.TableID)
+	assert.Equal(t, "lobby.example.com:3100", serverCfg.LobbyAddr)
+	assert.Equal(t, p2p.NewRemoteCoordinator("coordinator.example.com:3200", ""), serverCfg.Coordinator)
+	assert.Equal(t, 2, len(serverCfg.Tables))
+	assert.Equal(t, "plo", serverCfg.Tables[0].TableID)
+	assert.Equal(t, p2p.Omaha, serverCfg.Tables[0].GameVariant)
+	assert.Equal(t, 9, serverCfg.Tables[0].MaxPlayers)
+	assert.Equal(t, []string{"b.example.com:3000"}, serverCfg.Tables[0].BootstrapPeers)
+	assert.Equal(t, p2p.HORSE(0), serverCfg.Tables[1].Rotation)
+}
@@ -23,6 +23,16 @@ func (s Suit) String() string {
 	}
 }
 
+// ParseSuit returns the suit with the given name, like SPADES.
+func ParseSuit(name string) (Suit, error) {
+	for _, s := range []Suit{Spades, Harts, Diamonds, Clubs} {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid card suit (%s)", name)
+}
+
 const (
 	Spades   Suit = iota // 0
 	Harts                // 1
@@ -44,6 +54,33 @@ func (c Card) String() string {
 	return fmt.Sprintf("%s of %s %s", value, c.Suit, suitToUnicode(c.Suit))
 }
 
+// Short returns the card the way it is written on a table, like A♠ or T♥.
+func (c Card) Short() string {
+	value := strconv.Itoa(c.Value)
+	switch c.Value {
+	case 1:
+		value = "A"
+	case 10:
+		value = "T"
+	case 11:
+		value = "J"
+	case 12:
+		value = "Q"
+	case 13:
+		value = "K"
+	}
+
+	return value + suitToUnicode(c.Suit)
+}
+
+// Rank returns the value of the card where an ace plays high.
+func (c Card) Rank() int {
+	if c.Value == 1 {
+		return 14
+	}
+	return c.Value
+}
+
 func NewCard(s Suit, v int) Card {
 	if v > 13 {
 		panic("the value of the card cannot be higher then 13")
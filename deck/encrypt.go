@@ -1,40 +1,191 @@
 package deck
 
 import (
-	"bytes"
-	"encoding/gob"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
 )
 
-func DecryptCard(key, encCard []byte) (Card, error) {
-	card := Card{}
+// The deck is encrypted with the SRA cipher: a card m is encrypted to m^e mod
+// p and decrypted with the inverse exponent d. Every player encrypts with his
+// own exponents modulo the same prime, so the layers of encryption commute
+// and can be removed in any order. Unlike a stream cipher, knowing the
+// plaintext of one card tells nothing about the key, finding it is a discrete
+// logarithm.
+//
+// prime is the 2048-bit MODP group of RFC 3526. It is a safe prime, p = 2q+1
+// with q prime.
+var prime, _ = new(big.Int).SetString(
+	"FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD1"+
+		"29024E088A67CC74020BBEA63B139B22514A08798E3404DD"+
+		"EF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245"+
+		"E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED"+
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3D"+
+		"C2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F"+
+		"83655D23DCA3AD961C62F356208552BB9ED529077096966D"+
+		"670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B"+
+		"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9"+
+		"DE2BCBF6955817183995497CEA956AE515D2261898FA0510"+
+		"15728E5A8AACAA68FFFFFFFFFFFFFFFF", 16)
 
-	b, err := Encrypt(key, encCard)
-	if err != nil {
-		return card, err
+// cipherSize is the size of an encrypted card, every card has the same size
+// so the size does not tell the cards apart.
+var cipherSize = (prime.BitLen() + 7) / 8
+
+// Key is the secret of a player to encrypt and decrypt the deck with.
+type Key struct {
+	e *big.Int
+	d *big.Int
+}
+
+// NewKey generates a new random key that can be used to encrypt a deck.
+func NewKey() (*Key, error) {
+	var (
+		one   = big.NewInt(1)
+		order = new(big.Int).Sub(prime, one)
+	)
+	for {
+		e, err := rand.Int(rand.Reader, order)
+		if err != nil {
+			return nil, err
+		}
+		if e.Cmp(one) <= 0 {
+			continue
+		}
+		// e needs to be invertible modulo p-1 to decrypt.
+		if new(big.Int).GCD(nil, nil, e, order).Cmp(one) != 0 {
+			continue
+		}
+		return &Key{e: e, d: new(big.Int).ModInverse(e, order)}, nil
+	}
+}
+
+// cardCodes holds the numbers the cards are encoded as, the squares of the
+// first 52 primes. The cipher keeps whether a number is a quadratic residue,
+// so every code needs to be one or the encrypted cards would leak it. The
+// cipher is multiplicative as well, the encryption of a product is the
+// product of the encryptions, and no code is a product of the others.
+var cardCodes = func() []*big.Int {
+	codes := []*big.Int{}
+	for n := int64(2); len(codes) < 52; n++ {
+		if big.NewInt(n).ProbablyPrime(0) {
+			codes = append(codes, big.NewInt(n*n))
+		}
+	}
+	return codes
+}()
+
+func cardIndex(card Card) int {
+	return int(card.Suit)*13 + card.Value - 1
+}
+
+// encode maps the card to its code.
+func encode(card Card) *big.Int {
+	return new(big.Int).Set(cardCodes[cardIndex(card)])
+}
+
+// decode returns the card that is encoded as m.
+func decode(m *big.Int) (Card, error) {
+	for i, code := range cardCodes {
+		if code.Cmp(m) == 0 {
+			return NewCard(Suit(i/13), i%13+1), nil
+		}
 	}
+	return Card{}, errors.New("decrypted value is not a card")
+}
 
-	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&card); err != nil {
-		return card, err
+func toInt(b []byte) (*big.Int, error) {
+	if len(b) != cipherSize {
+		return nil, fmt.Errorf("invalid encrypted card of %d bytes", len(b))
 	}
+	n := new(big.Int).SetBytes(b)
+	if n.Sign() <= 0 || n.Cmp(prime) >= 0 {
+		return nil, errors.New("invalid encrypted card")
+	}
+	return n, nil
+}
 
-	return card, nil
+func toBytes(n *big.Int) []byte {
+	return n.FillBytes(make([]byte, cipherSize))
 }
 
-func EncryptCard(key []byte, card Card) ([]byte, error) {
-	buf := new(bytes.Buffer)
-	if err := gob.NewEncoder(buf).Encode(card); err != nil {
+// Encrypt adds our layer of encryption to an encrypted card.
+func Encrypt(key *Key, encCard []byte) ([]byte, error) {
+	n, err := toInt(encCard)
+	if err != nil {
 		return nil, err
 	}
-	payload := buf.Bytes()
+	return toBytes(n.Exp(n, key.e, prime)), nil
+}
 
-	return Encrypt(key, payload)
+// Decrypt removes our layer of encryption from an encrypted card, the other
+// layers stay on it.
+func Decrypt(key *Key, encCard []byte) ([]byte, error) {
+	n, err := toInt(encCard)
+	if err != nil {
+		return nil, err
+	}
+	return toBytes(n.Exp(n, key.d, prime)), nil
 }
 
-func Encrypt(key, payload []byte) ([]byte, error) {
-	encOutput := make([]byte, len(payload))
-	for i := 0; i < len(payload); i++ {
-		encOutput[i] = payload[i] ^ key[i%len(key)]
+func EncryptCard(key *Key, card Card) ([]byte, error) {
+	if card.Suit < Spades || card.Suit > Clubs || card.Value < 1 || card.Value > 13 {
+		return nil, fmt.Errorf("invalid card (%d of suit %d)", card.Value, card.Suit)
 	}
+	return Encrypt(key, toBytes(encode(card)))
+}
 
-	return encOutput, nil
+// DecryptCard removes the last layer of encryption and returns the card.
+func DecryptCard(key *Key, encCard []byte) (Card, error) {
+	b, err := Decrypt(key, encCard)
+	if err != nil {
+		return Card{}, err
+	}
+	return decode(new(big.Int).SetBytes(b))
+}
+
+// EncryptDeck encrypts every card of the deck with the given key and
+// shuffles the encrypted cards.
+func EncryptDeck(key *Key, d Deck) ([][]byte, error) {
+	encDeck := make([][]byte, len(d))
+	for i, card := range d {
+		encCard, err := EncryptCard(key, card)
+		if err != nil {
+			return nil, err
+		}
+		encDeck[i] = encCard
+	}
+
+	return shuffleEncrypted(encDeck)
+}
+
+// EncryptAndShuffle adds our own layer of encryption on top of an already
+// encrypted deck and shuffles it. Because the encryption is commutative the
+// layers can later be removed in any order.
+func EncryptAndShuffle(key *Key, encDeck [][]byte) ([][]byte, error) {
+	out := make([][]byte, len(encDeck))
+	for i, encCard := range encDeck {
+		b, err := Encrypt(key, encCard)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = b
+	}
+
+	return shuffleEncrypted(out)
+}
+
+// shuffleEncrypted shuffles the deck with the Fisher-Yates shuffle. The
+// positions are drawn from crypto/rand, the order of a deck shuffled with
+// math/rand can be predicted from the seed.
+func shuffleEncrypted(encDeck [][]byte) ([][]byte, error) {
+	for i := len(encDeck) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return nil, err
+		}
+		encDeck[i], encDeck[j.Int64()] = encDeck[j.Int64()], encDeck[i]
+	}
+	return encDeck, nil
 }
@@ -1,12 +1,17 @@
 package deck
 
 import (
+	"bytes"
+	"math/big"
 	"reflect"
 	"testing"
 )
 
 func TestEncryptCard(t *testing.T) {
-	key := []byte("foobarbazfoobarbazfoobarbazfoobarbaz")
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
 	card := Card{
 		Suit:  Spades,
 		Value: 1,
@@ -25,4 +30,107 @@ func TestEncryptCard(t *testing.T) {
 	if !reflect.DeepEqual(card, decCard) {
 		t.Errorf("got %+v but want %+v", decCard, card)
 	}
+
+	if _, err := DecryptCard(key, []byte{1, 2, 3}); err == nil {
+		t.Errorf("decrypted a card of the wrong size")
+	}
+}
+
+func TestEncryptionCommutes(t *testing.T) {
+	a, _ := NewKey()
+	b, _ := NewKey()
+
+	encDeck, err := EncryptDeck(a, New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	encDeck, err = EncryptAndShuffle(b, encDeck)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first player removes his layer before the second one.
+	seen := map[Card]bool{}
+	for _, encCard := range encDeck {
+		b1, err := Decrypt(a, encCard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		card, err := DecryptCard(b, b1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[card] = true
+	}
+	if len(seen) != 52 {
+		t.Errorf("got %d different cards but want 52", len(seen))
+	}
+}
+
+// A player that knows one of his own cards can not read the other cards that
+// are encrypted with the same key.
+func TestKnownPlaintext(t *testing.T) {
+	key, _ := NewKey()
+	known := NewCard(Spades, 1)
+	knownEnc, _ := EncryptCard(key, known)
+	knownCipher := new(big.Int).SetBytes(knownEnc)
+
+	// The keystream of a stream cipher.
+	stream := make([]byte, len(knownEnc))
+	for i, b := range toBytes(encode(known)) {
+		stream[i] = b ^ knownEnc[i]
+	}
+
+	for _, card := range New() {
+		if card == known {
+			continue
+		}
+		encCard, _ := EncryptCard(key, card)
+		if bytes.Equal(encCard, knownEnc) {
+			t.Fatalf("%s is encrypted like the known card", card)
+		}
+
+		xored := make([]byte, len(encCard))
+		for i := range encCard {
+			xored[i] = encCard[i] ^ stream[i]
+		}
+		if _, err := decode(new(big.Int).SetBytes(xored)); err == nil {
+			t.Errorf("%s is revealed by the keystream of the known card", card)
+		}
+
+		// Powers of the known card are the encryptions of powers of its
+		// code, which are no cards.
+		for k := int64(2); k <= 8; k++ {
+			power := new(big.Int).Exp(knownCipher, big.NewInt(k), prime)
+			if bytes.Equal(toBytes(power), encCard) {
+				t.Errorf("%s is revealed by a power of the known card", card)
+			}
+		}
+	}
+}
+
+func TestShuffleEncrypted(t *testing.T) {
+	encDeck := make([][]byte, 52)
+	for i := range encDeck {
+		encDeck[i] = []byte{byte(i)}
+	}
+	shuffled, err := shuffleEncrypted(append([][]byte{}, encDeck...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[byte]bool{}
+	moved := 0
+	for i, b := range shuffled {
+		seen[b[0]] = true
+		if !bytes.Equal(b, encDeck[i]) {
+			moved++
+		}
+	}
+	if len(seen) != 52 {
+		t.Errorf("got %d different cards but want 52", len(seen))
+	}
+	if moved == 0 {
+		t.Errorf("the deck is not shuffled")
+	}
 }
@@ -0,0 +1,304 @@
+package deck
+
+import (
+	"fmt"
+	"sort"
+)
+
+type HandCategory int
+
+func (hc HandCategory) String() string {
+	switch hc {
+	case HighCard:
+		return "HIGH CARD"
+	case OnePair:
+		return "ONE PAIR"
+	case TwoPair:
+		return "TWO PAIR"
+	case ThreeOfAKind:
+		return "THREE OF A KIND"
+	case Straight:
+		return "STRAIGHT"
+	case Flush:
+		return "FLUSH"
+	case FullHouse:
+		return "FULL HOUSE"
+	case FourOfAKind:
+		return "FOUR OF A KIND"
+	case StraightFlush:
+		return "STRAIGHT FLUSH"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	HighCard HandCategory = iota
+	OnePair
+	TwoPair
+	ThreeOfAKind
+	Straight
+	Flush
+	FullHouse
+	FourOfAKind
+	StraightFlush
+)
+
+// HandValue is the score of a poker hand. A higher value always beats a
+// lower one, equal values split the pot.
+type HandValue uint32
+
+func (hv HandValue) Category() HandCategory {
+	return HandCategory(hv >> 20)
+}
+
+func (hv HandValue) String() string {
+	return fmt.Sprintf("%s (%d)", hv.Category(), uint32(hv))
+}
+
+// scoring holds the rules a hand is scored with.
+type scoring struct {
+	// aceLow makes the ace the lowest card instead of the highest.
+	aceLow bool
+	// straights reports whether straights and flushes count.
+	straights bool
+	// wheel reports whether A-2-3-4-5 counts as a straight.
+	wheel bool
+}
+
+var (
+	highScoring         = scoring{straights: true, wheel: true}
+	aceToFiveScoring    = scoring{aceLow: true}
+	deuceToSevenScoring = scoring{straights: true}
+)
+
+// Evaluate scores a hand of at most five cards. Hands with less than five
+// cards (like the visible cards in stud) can only make pairs, trips and
+// quads. Straights and flushes need all five cards.
+func Evaluate(cards []Card) HandValue {
+	return evaluate(cards, highScoring)
+}
+
+// EvaluateAceToFive scores a low hand of at most five cards where a lower
+// value wins. Aces are low and straights and flushes do not count, so the
+// best hand is A-2-3-4-5.
+func EvaluateAceToFive(cards []Card) HandValue {
+	return evaluate(cards, aceToFiveScoring)
+}
+
+// EvaluateDeuceToSeven scores a low hand of at most five cards where a lower
+// value wins. Aces are high and straights and flushes count against the
+// hand, so the best hand is 7-5-4-3-2 of more than one suit.
+func EvaluateDeuceToSeven(cards []Card) HandValue {
+	return evaluate(cards, deuceToSevenScoring)
+}
+
+func evaluate(cards []Card, s scoring) HandValue {
+	if len(cards) > 5 {
+		panic("cannot evaluate more than 5 cards, use BestHand instead")
+	}
+	if len(cards) == 0 {
+		return 0
+	}
+
+	counts := map[int]int{}
+	for _, c := range cards {
+		if s.aceLow {
+			counts[c.Value]++
+		} else {
+			counts[c.Rank()]++
+		}
+	}
+
+	// Order the ranks by how many times they occur and by their height,
+	// so the kickers are compared in the right order.
+	ranks := make([]int, 0, len(counts))
+	for r := range counts {
+		ranks = append(ranks, r)
+	}
+	sort.Slice(ranks, func(i, j int) bool {
+		if counts[ranks[i]] != counts[ranks[j]] {
+			return counts[ranks[i]] > counts[ranks[j]]
+		}
+		return ranks[i] > ranks[j]
+	})
+
+	var category HandCategory
+	switch {
+	case counts[ranks[0]] == 4:
+		category = FourOfAKind
+	case counts[ranks[0]] == 3 && len(ranks) > 1 && counts[ranks[1]] == 2:
+		category = FullHouse
+	case counts[ranks[0]] == 3:
+		category = ThreeOfAKind
+	case counts[ranks[0]] == 2 && len(ranks) > 1 && counts[ranks[1]] == 2:
+		category = TwoPair
+	case counts[ranks[0]] == 2:
+		category = OnePair
+	default:
+		category = HighCard
+	}
+
+	if s.straights && len(cards) == 5 && len(ranks) == 5 {
+		flush := true
+		for _, c := range cards[1:] {
+			if c.Suit != cards[0].Suit {
+				flush = false
+				break
+			}
+		}
+
+		straight := ranks[0]-ranks[4] == 4
+		// The wheel (A-2-3-4-5) is the lowest straight, the ace plays low.
+		if s.wheel && ranks[0] == 14 && ranks[1] == 5 {
+			straight = true
+			ranks = []int{5, 4, 3, 2, 1}
+		}
+
+		switch {
+		case straight && flush:
+			category = StraightFlush
+		case flush:
+			category = Flush
+		case straight:
+			category = Straight
+		}
+	}
+
+	value := HandValue(category) << 20
+	for i, r := range ranks {
+		value |= HandValue(r) << (16 - 4*i)
+	}
+
+	return value
+}
+
+// BestHand returns the best five card hand that can be made out of the
+// given cards.
+func BestHand(cards []Card) (HandValue, []Card) {
+	if len(cards) <= 5 {
+		return Evaluate(cards), cards
+	}
+
+	var (
+		best     HandValue
+		bestHand []Card
+	)
+	for _, combo := range combinations(cards, 5) {
+		if value := Evaluate(combo); bestHand == nil || value > best {
+			best = value
+			bestHand = combo
+		}
+	}
+
+	return best, bestHand
+}
+
+// BestAceToFiveLow returns the best ace to five low hand that can be made out
+// of the given cards.
+func BestAceToFiveLow(cards []Card) (HandValue, []Card) {
+	return bestLow(cards, aceToFiveScoring)
+}
+
+// BestDeuceToSevenLow returns the best deuce to seven low hand that can be
+// made out of the given cards.
+func BestDeuceToSevenLow(cards []Card) (HandValue, []Card) {
+	return bestLow(cards, deuceToSevenScoring)
+}
+
+func bestLow(cards []Card, s scoring) (HandValue, []Card) {
+	if len(cards) <= 5 {
+		return evaluate(cards, s), cards
+	}
+	return lowest(combinations(cards, 5), s)
+}
+
+// lowest returns the lowest scoring hand of the given hands.
+func lowest(hands [][]Card, s scoring) (HandValue, []Card) {
+	var (
+		best     HandValue
+		bestHand []Card
+	)
+	for _, hand := range hands {
+		if value := evaluate(hand, s); bestHand == nil || value < best {
+			best = value
+			bestHand = hand
+		}
+	}
+
+	return best, bestHand
+}
+
+// BestEightOrBetterLow returns the best ace to five low hand that can be made
+// out of the given cards for the low half of a hi-lo pot. It returns false
+// when there is no qualifying low, being five different cards of eight or
+// lower.
+func BestEightOrBetterLow(cards []Card) (HandValue, []Card, bool) {
+	if len(cards) < 5 {
+		return 0, nil, false
+	}
+	value, hand := bestLow(cards, aceToFiveScoring)
+	return value, hand, isEightOrBetter(value)
+}
+
+// BestOmahaEightOrBetterLow is like BestEightOrBetterLow, but the low needs
+// to use exactly two of the hole cards and exactly three of the board cards.
+func BestOmahaEightOrBetterLow(hole, board []Card) (HandValue, []Card, bool) {
+	hands := [][]Card{}
+	for _, h := range combinations(hole, 2) {
+		for _, b := range combinations(board, 3) {
+			hands = append(hands, append(append([]Card{}, h...), b...))
+		}
+	}
+	if len(hands) == 0 {
+		return 0, nil, false
+	}
+	value, hand := lowest(hands, aceToFiveScoring)
+	return value, hand, isEightOrBetter(value)
+}
+
+// isEightOrBetter reports whether the ace to five low has no pairs and its
+// highest card is an eight or lower.
+func isEightOrBetter(value HandValue) bool {
+	highest := int(value>>16) & 0xf
+	return value.Category() == HighCard && highest <= 8
+}
+
+// BestOmahaHand returns the best hand that uses exactly two of the hole
+// cards and exactly three of the board cards.
+func BestOmahaHand(hole, board []Card) (HandValue, []Card) {
+	var (
+		best     HandValue
+		bestHand []Card
+	)
+	for _, h := range combinations(hole, 2) {
+		for _, b := range combinations(board, 3) {
+			hand := append(append([]Card{}, h...), b...)
+			if value := Evaluate(hand); bestHand == nil || value > best {
+				best = value
+				bestHand = hand
+			}
+		}
+	}
+
+	return best, bestHand
+}
+
+// combinations returns every subset of k cards.
+func combinations(cards []Card, k int) [][]Card {
+	if k > len(cards) {
+		return nil
+	}
+	if k == 0 {
+		return [][]Card{{}}
+	}
+
+	combos := [][]Card{}
+	for i := 0; i <= len(cards)-k; i++ {
+		for _, rest := range combinations(cards[i+1:], k-1) {
+			combos = append(combos, append([]Card{cards[i]}, rest...))
+		}
+	}
+
+	return combos
+}
@@ -0,0 +1,129 @@
+package deck
+
+import "testing"
+
+func TestEvaluateCategory(t *testing.T) {
+	tests := []struct {
+		cards []Card
+		want  HandCategory
+	}{
+		{[]Card{{Spades, 2}, {Harts, 5}, {Clubs, 9}, {Spades, 11}, {Diamonds, 13}}, HighCard},
+		{[]Card{{Spades, 2}, {Harts, 2}, {Clubs, 9}, {Spades, 11}, {Diamonds, 13}}, OnePair},
+		{[]Card{{Spades, 2}, {Harts, 2}, {Clubs, 9}, {Spades, 9}, {Diamonds, 13}}, TwoPair},
+		{[]Card{{Spades, 2}, {Harts, 2}, {Clubs, 2}, {Spades, 9}, {Diamonds, 13}}, ThreeOfAKind},
+		{[]Card{{Spades, 1}, {Harts, 2}, {Clubs, 3}, {Spades, 4}, {Diamonds, 5}}, Straight},
+		{[]Card{{Spades, 10}, {Harts, 11}, {Clubs, 12}, {Spades, 13}, {Diamonds, 1}}, Straight},
+		{[]Card{{Spades, 2}, {Spades, 5}, {Spades, 9}, {Spades, 11}, {Spades, 13}}, Flush},
+		{[]Card{{Spades, 2}, {Harts, 2}, {Clubs, 2}, {Spades, 9}, {Diamonds, 9}}, FullHouse},
+		{[]Card{{Spades, 2}, {Harts, 2}, {Clubs, 2}, {Diamonds, 2}, {Diamonds, 9}}, FourOfAKind},
+		{[]Card{{Harts, 9}, {Harts, 10}, {Harts, 11}, {Harts, 12}, {Harts, 13}}, StraightFlush},
+		{[]Card{{Harts, 9}, {Spades, 9}}, OnePair},
+	}
+
+	for _, test := range tests {
+		if got := Evaluate(test.cards).Category(); got != test.want {
+			t.Errorf("%v: got %s but want %s", test.cards, got, test.want)
+		}
+	}
+}
+
+func TestEvaluateKickers(t *testing.T) {
+	wheel := Evaluate([]Card{{Spades, 1}, {Harts, 2}, {Clubs, 3}, {Spades, 4}, {Diamonds, 5}})
+	sixHigh := Evaluate([]Card{{Spades, 6}, {Harts, 2}, {Clubs, 3}, {Spades, 4}, {Diamonds, 5}})
+	if wheel >= sixHigh {
+		t.Errorf("the wheel should lose against a six high straight")
+	}
+
+	acesKingKicker := Evaluate([]Card{{Spades, 1}, {Harts, 1}, {Clubs, 13}, {Spades, 4}, {Diamonds, 5}})
+	acesQueenKicker := Evaluate([]Card{{Clubs, 1}, {Diamonds, 1}, {Clubs, 12}, {Harts, 4}, {Harts, 5}})
+	if acesKingKicker <= acesQueenKicker {
+		t.Errorf("the king kicker should win")
+	}
+
+	split := Evaluate([]Card{{Clubs, 1}, {Diamonds, 1}, {Clubs, 13}, {Harts, 4}, {Harts, 5}})
+	if acesKingKicker != split {
+		t.Errorf("equal hands should have the same value")
+	}
+}
+
+func TestBestOmahaHand(t *testing.T) {
+	// Four hearts on the board make a flush in hold'em, but in omaha we
+	// need two hearts in our hand.
+	board := []Card{{Harts, 2}, {Harts, 7}, {Harts, 9}, {Harts, 13}, {Spades, 4}}
+	hole := []Card{{Harts, 1}, {Clubs, 1}, {Diamonds, 12}, {Spades, 12}}
+
+	holdemValue, _ := BestHand(append(append([]Card{}, hole...), board...))
+	if holdemValue.Category() != Flush {
+		t.Errorf("got %s but want a flush", holdemValue.Category())
+	}
+
+	omahaValue, hand := BestOmahaHand(hole, board)
+	if omahaValue.Category() != OnePair {
+		t.Errorf("got %s but want one pair", omahaValue.Category())
+	}
+	if len(hand) != 5 {
+		t.Errorf("got %d cards but want 5", len(hand))
+	}
+}
+
+func TestLowHands(t *testing.T) {
+	wheel := []Card{{Spades, 1}, {Harts, 2}, {Clubs, 3}, {Spades, 4}, {Diamonds, 5}}
+	sevenLow := []Card{{Spades, 7}, {Harts, 2}, {Clubs, 3}, {Spades, 4}, {Diamonds, 5}}
+	sevenFlush := []Card{{Spades, 7}, {Spades, 2}, {Spades, 3}, {Spades, 4}, {Spades, 5}}
+	pairedLow := []Card{{Spades, 2}, {Harts, 2}, {Clubs, 3}, {Spades, 4}, {Diamonds, 5}}
+
+	// In ace to five the wheel is the nuts and flushes do not count.
+	if EvaluateAceToFive(wheel) >= EvaluateAceToFive(sevenLow) {
+		t.Errorf("the wheel should be the best ace to five low")
+	}
+	if EvaluateAceToFive(sevenFlush) != EvaluateAceToFive(sevenLow) {
+		t.Errorf("flushes should not count in ace to five")
+	}
+	if EvaluateAceToFive(pairedLow) <= EvaluateAceToFive(sevenLow) {
+		t.Errorf("a pair should lose against a seven low")
+	}
+
+	// In deuce to seven the ace is high and flushes count against the hand.
+	if EvaluateDeuceToSeven(sevenLow) >= EvaluateDeuceToSeven(wheel) {
+		t.Errorf("the seven low should beat ace high")
+	}
+	if EvaluateDeuceToSeven(sevenFlush) <= EvaluateDeuceToSeven(sevenLow) {
+		t.Errorf("the flush should lose in deuce to seven")
+	}
+	if got := EvaluateDeuceToSeven(wheel).Category(); got != HighCard {
+		t.Errorf("A-2-3-4-5 should not be a straight in deuce to seven but got %s", got)
+	}
+
+	// Razz plays the best five out of seven cards.
+	value, _ := BestAceToFiveLow(append(sevenLow, Card{Clubs, 1}, Card{Harts, 13}))
+	if value != EvaluateAceToFive(wheel) {
+		t.Errorf("the best razz hand should be the wheel")
+	}
+}
+
+func TestEightOrBetterLow(t *testing.T) {
+	// The pair of deuces does not matter, there are enough other low cards.
+	_, _, ok := BestEightOrBetterLow([]Card{{Spades, 1}, {Harts, 2}, {Clubs, 2}, {Spades, 4}, {Diamonds, 5}, {Harts, 8}, {Clubs, 13}})
+	if !ok {
+		t.Errorf("A-2-4-5-8 should qualify for low")
+	}
+
+	_, _, ok = BestEightOrBetterLow([]Card{{Spades, 1}, {Harts, 2}, {Clubs, 3}, {Spades, 4}, {Diamonds, 9}, {Harts, 13}, {Clubs, 13}})
+	if ok {
+		t.Errorf("a nine low should not qualify")
+	}
+
+	// In omaha only two of the hole cards can be used, so the third low card
+	// in the hand does not help.
+	hole := []Card{{Spades, 1}, {Harts, 2}, {Clubs, 3}, {Clubs, 13}}
+	board := []Card{{Diamonds, 4}, {Harts, 9}, {Spades, 10}, {Diamonds, 11}, {Harts, 12}}
+	if _, _, ok := BestOmahaEightOrBetterLow(hole, board); ok {
+		t.Errorf("the omaha low should need three low cards on the board")
+	}
+	board[1] = Card{Harts, 7}
+	board[2] = Card{Spades, 8}
+	value, _, ok := BestOmahaEightOrBetterLow(hole, board)
+	if !ok || value != EvaluateAceToFive([]Card{{Spades, 1}, {Harts, 2}, {Diamonds, 4}, {Harts, 7}, {Spades, 8}}) {
+		t.Errorf("the best omaha low should be 8-7-4-2-A")
+	}
+}
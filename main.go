@@ -1,112 +1,241 @@
 package main
 
 import (
-	"net/http"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
 	"time"
 
+	"github.com/anthdm/ggpoker/client"
 	"github.com/anthdm/ggpoker/p2p"
+	"github.com/anthdm/ggpoker/tui"
 )
 
-func makeServerAndStart(addr, apiAddr string) *p2p.Server {
-	cfg := p2p.ServerConfig{
-		Version:       "GGPOKER V0.2-alpha",
-		ListenAddr:    addr,
-		APIListenAddr: apiAddr,
-		GameVariant:   p2p.TexasHoldem,
-	}
-	server := p2p.NewServer(cfg)
-	go server.Start()
+const usage = `Usage: ggpoker <command> [flags]
 
-	time.Sleep(time.Millisecond * 200)
+Commands:
+  node run [flags]              start a node and wait for players to join
+  node join [flags] <addr>      start a node and join the table of the player at addr
+  local-cluster [flags] <n>     start a demo table with n players in this process
+  lobby run [flags]             start a lobby that lists the tables nodes announce
+  coordinator run [flags]       start the coordinator of a multi-table tournament
+  tui [flags]                   play at a table from the terminal
 
-	return server
-}
+Run a command with -h to see its flags.
+`
 
 func main() {
-	playerA := makeServerAndStart(":3000", ":3001") // dealer
-	playerB := makeServerAndStart(":4000", ":4001") // sb
-	playerC := makeServerAndStart(":5000", ":5001") // bb
-	playerD := makeServerAndStart(":7000", ":7001") // bb + 2
-
-	go func() {
-		time.Sleep(time.Second * 2)
-		http.Get("http://localhost:3001/ready")
-
-		// time.Sleep(time.Second * 2)
-		// http.Get("http://localhost:4001/ready")
-
-		time.Sleep(time.Second * 2)
-		http.Get("http://localhost:5001/ready")
-
-		time.Sleep(time.Second * 2)
-		http.Get("http://localhost:7001/ready")
-
-		// [3000:D, 4000:sb, 5000:bb, 7000]
-		// PREFLOP
-		// time.Sleep(time.Second * 2)
-		// http.Get("http://localhost:4001/fold")
-
-		// time.Sleep(time.Second * 2)
-		// http.Get("http://localhost:5001/fold")
-
-		// time.Sleep(time.Second * 2)
-		// http.Get("http://localhost:7001/fold")
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
 
-		// time.Sleep(time.Second * 2)
-		// http.Get("http://localhost:3001/fold")
+func run(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("no command given")
+	}
 
-		// // FLOP
-		// time.Sleep(time.Second * 2)
-		// http.Get("http://localhost:4001/fold")
+	switch args[0] {
+	case "node":
+		if len(args) < 2 {
+			return fmt.Errorf("node needs a subcommand: run or join")
+		}
+		switch args[1] {
+		case "run":
+			return runNode(args[2:], false)
+		case "join":
+			return runNode(args[2:], true)
+		default:
+			return fmt.Errorf("unknown node command (%s)", args[1])
+		}
+	case "local-cluster":
+		return runLocalCluster(args[1:])
+	case "lobby":
+		if len(args) < 2 || args[1] != "run" {
+			return fmt.Errorf("lobby needs a subcommand: run")
+		}
+		return runLobby(args[2:])
+	case "coordinator":
+		if len(args) < 2 || args[1] != "run" {
+			return fmt.Errorf("coordinator needs a subcommand: run")
+		}
+		return runCoordinator(args[2:])
+	case "tui":
+		return runTUI(args[1:])
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command (%s)", args[0])
+	}
+}
 
-		// time.Sleep(time.Second * 2)
-		// http.Get("http://localhost:5001/fold")
+// runNode starts a node and blocks. When join is set the node connects to
+// the player at the address that is given as argument.
+func runNode(args []string, join bool) error {
+	fs := flag.NewFlagSet("node", flag.ContinueOnError)
+	cfg, err := nodeFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
 
-		// time.Sleep(time.Second * 2)
-		// http.Get("http://localhost:7001/fold")
+	if join {
+		if fs.NArg() != 1 {
+			return fmt.Errorf("node join needs the address of a player at the table")
+		}
+		cfg.Bootstrap = append(cfg.Bootstrap, fs.Arg(0))
+	}
 
-		// time.Sleep(time.Second * 2)
-		// http.Get("http://localhost:3001/fold")
+	serverCfg, err := cfg.ServerConfig()
+	if err != nil {
+		return err
+	}
+	p2p.NewServer(serverCfg).Start()
 
-		// // TURN
-		// time.Sleep(time.Second * 2)
-		// http.Get("http://localhost:4001/fold")
+	return nil
+}
 
-		// time.Sleep(time.Second * 2)
-		// http.Get("http://localhost:5001/fold")
+// runLocalCluster starts a table with n players in this process, every
+// player gets the next two ports for its node and API. Every player only
+// knows the previous one, the others are discovered.
+func runLocalCluster(args []string) error {
+	fs := flag.NewFlagSet("local-cluster", flag.ContinueOnError)
+	cfg, err := nodeFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	port := fs.Int("port", 3000, "the port of the first player")
+	ready := fs.Bool("ready", true, "tell the table every player is ready so the first hand is dealt")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("local-cluster needs the number of players")
+	}
+	n, err := strconv.Atoi(fs.Arg(0))
+	if err != nil || n < 2 {
+		return fmt.Errorf("invalid number of players (%s)", fs.Arg(0))
+	}
+	serverCfg, err := cfg.ServerConfig()
+	if err != nil {
+		return err
+	}
+	if seats := serverCfg.Seats(); n > seats {
+		return fmt.Errorf("%d players do not fit at a table with %d seats", n, seats)
+	}
 
-		// time.Sleep(time.Second * 2)
-		// http.Get("http://localhost:7001/fold")
+	servers := make([]*p2p.Server, n)
+	for i := range servers {
+		cfg.ListenAddr = fmt.Sprintf(":%d", *port+2*i)
+		cfg.APIListenAddr = fmt.Sprintf(":%d", *port+2*i+1)
+		cfg.Bootstrap = nil
+		if i > 0 {
+			cfg.Bootstrap = []string{servers[i-1].ListenAddr}
+		}
+
+		serverCfg, err := cfg.ServerConfig()
+		if err != nil {
+			return err
+		}
+		servers[i] = p2p.NewServer(serverCfg)
+		go servers[i].Start()
+	}
 
-		// time.Sleep(time.Second * 2)
-		// http.Get("http://localhost:3001/fold")
+	if err := waitForMesh(servers, time.Second*30); err != nil {
+		return err
+	}
 
-		// // RIVER
-		// time.Sleep(time.Second * 2)
-		// http.Get("http://localhost:4001/fold")
+	for _, server := range servers {
+		fmt.Printf("player %s: api %s token %s\n", server.ListenAddr, server.APIListenAddr, server.APIToken)
+	}
 
-		// time.Sleep(time.Second * 2)
-		// http.Get("http://localhost:5001/fold")
+	if *ready {
+		ctx := context.Background()
+		for _, server := range servers {
+			if _, err := client.New(server.APIListenAddr, server.APIToken).Ready(ctx); err != nil {
+				return err
+			}
+		}
+	}
 
-		// time.Sleep(time.Second * 2)
-		// http.Get("http://localhost:7001/fold")
+	select {}
+}
 
-		// time.Sleep(time.Second * 2)
-		// http.Get("http://localhost:3001/fold")
+// waitForMesh waits until every player is connected to all the others.
+func waitForMesh(servers []*p2p.Server, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		complete := true
+		for _, server := range servers {
+			if len(server.Peers()) != len(servers)-1 {
+				complete = false
+			}
+		}
+		if complete {
+			return nil
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	return fmt.Errorf("players did not connect to each other within %s", timeout)
+}
 
-	}()
+// runLobby starts a standalone lobby and blocks. It only lists tables, the
+// nodes that play at them create and join tables through their own lobby.
+func runLobby(args []string) error {
+	fs := flag.NewFlagSet("lobby", flag.ContinueOnError)
+	listenAddr := fs.String("listen", ":3100", "the address the lobby listens on")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
 
-	time.Sleep(time.Millisecond * 200)
-	playerB.Connect(playerA.ListenAddr)
+	return p2p.NewLobby("", nil).Run(*listenAddr)
+}
 
-	time.Sleep(time.Millisecond)
-	playerC.Connect(playerB.ListenAddr)
+// runCoordinator starts the coordinator of a multi-table tournament and
+// blocks. The tournament and the tables are configured like the ones of a
+// node, the nodes register with the coordinator token.
+func runCoordinator(args []string) error {
+	fs := flag.NewFlagSet("coordinator", flag.ContinueOnError)
+	cfg, err := nodeFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if cfg.Coordinator.Token == "" {
+		return fmt.Errorf("coordinator run needs a token, the nodes register with it")
+	}
+	if cfg.Coordinator.ListenAddr == "" {
+		cfg.Coordinator.ListenAddr = ":3200"
+	}
 
-	time.Sleep(time.Millisecond * 200)
-	playerD.Connect(playerC.ListenAddr)
+	// The nodes are seated by the coordinator, not by this config.
+	cfg.Coordinator.Addr = ""
+	cfg.Tournament.Enabled = true
+	serverCfg, err := cfg.ServerConfig()
+	if err != nil {
+		return err
+	}
 
-	select {}
+	return p2p.NewTableCoordinator(serverCfg).Run(cfg.Coordinator.ListenAddr, cfg.Coordinator.Token)
 }
 
-func init() {
+func runTUI(args []string) error {
+	fs := flag.NewFlagSet("tui", flag.ContinueOnError)
+	addr := fs.String("api", ":3001", "the address of the API of the node")
+	token := fs.String("token", os.Getenv("GGPOKER_TOKEN"), "the API token of the node, defaults to $GGPOKER_TOKEN")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	return tui.New(client.New(*addr, *token)).Run(context.Background())
 }
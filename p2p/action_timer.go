@@ -0,0 +1,95 @@
+package p2p
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// actionTimerTick is how often we check whether our time to act has run out.
+const actionTimerTick = 100 * time.Millisecond
+
+// setCurrentTurn gives the turn to the player at the given table position and
+// starts the action timer. A position of -1 means nobody can act.
+func (g *GameState) setCurrentTurn(pos int) {
+	g.timerLock.Lock()
+	g.turnStarted = time.Now()
+	g.turnTimedOut = false
+	g.timerLock.Unlock()
+
+	g.currentPlayerTurn.Set(int32(pos))
+}
+
+// timeToAct returns the time the current player has left to act, it is zero
+// when the table plays without an action timer or nobody can act.
+func (g *GameState) timeToAct() time.Duration {
+	if g.options.ActionTime == 0 || g.currentPlayerTurn.Get() < 0 {
+		return 0
+	}
+
+	g.timerLock.Lock()
+	defer g.timerLock.Unlock()
+
+	left := g.options.ActionTime - time.Since(g.turnStarted)
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// actionTimerLoop acts for us when our time runs out. Every player only
+// enforces the timer on its own seat, so the other players never need to
+// agree on when the time of somebody else ran out. For them the timer of
+// another player is only shown, an action that races a timeout taken by
+// somebody else would split the hand.
+func (g *GameState) actionTimerLoop() {
+	ticker := time.NewTicker(actionTimerTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-g.quit:
+			return
+		}
+		if g.ourTimeRanOut() {
+			g.actOnTimeout()
+		}
+	}
+}
+
+// ourTimeRanOut reports whether we did not act in time. It only reports it
+// once per turn.
+func (g *GameState) ourTimeRanOut() bool {
+	g.timerLock.Lock()
+	defer g.timerLock.Unlock()
+
+	if g.turnTimedOut || time.Since(g.turnStarted) < g.options.ActionTime {
+		return false
+	}
+	if !g.canTakeAction(g.listenAddr) {
+		return false
+	}
+
+	g.turnTimedOut = true
+	return true
+}
+
+// actOnTimeout takes the most passive action there is, we stand pat in a draw
+// and check when we can, otherwise we fold.
+func (g *GameState) actOnTimeout() {
+	var err error
+	switch {
+	case g.isDrawing():
+		err = g.Draw([]int{})
+	case g.betting.toCall(g.listenAddr) == 0:
+		err = g.TakeAction(PlayerActionCheck, 0)
+	default:
+		err = g.TakeAction(PlayerActionFold, 0)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"we":  g.listenAddr,
+		"err": err,
+	}).Warn("action time ran out")
+}
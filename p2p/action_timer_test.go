@@ -0,0 +1,39 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActionTimer(t *testing.T) {
+	cfg := ServerConfig{
+		ListenAddr:   ":3000",
+		Rotation:     Rotation{Games: []Game{{GameVariant: TexasHoldem}}}.withDefaults(),
+		TableOptions: TableOptions{ActionTime: 50 * time.Millisecond},
+	}
+	g := NewGame(cfg, make(chan BroadcastTo, 10))
+	players := []string{":3000", ":4000", ":5000"}
+	for i, addr := range players {
+		g.table.AddPlayerOnPosition(addr, i)
+		g.betting.sitDown(addr, 100)
+	}
+	g.betting.reset(players)
+	g.currentStatus.Set(int32(GameStatusPreFlop))
+
+	// Nobody bet, so we check when the time runs out.
+	g.setCurrentTurn(0)
+	assert.InDelta(t, 50, g.State().TimeToAct, 10)
+	assert.Eventually(t, func() bool { return g.currentPlayerTurn.Get() == 1 }, time.Second, 10*time.Millisecond)
+	assert.True(t, g.betting.isActive(":3000"))
+
+	// The time of the other players is enforced by themselves.
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(1), g.currentPlayerTurn.Get())
+
+	// Facing a bet we fold.
+	g.betting.apply(":4000", PlayerActionBet, 10)
+	g.setCurrentTurn(0)
+	assert.Eventually(t, func() bool { return !g.betting.isActive(":3000") }, time.Second, 10*time.Millisecond)
+}
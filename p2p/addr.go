@@ -0,0 +1,32 @@
+package p2p
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// normalizeAddr returns the address in the one form every player uses for it,
+// so it can be compared. Hosts are lower case and IP addresses are in their
+// shortest form, with IPv6 addresses in brackets like [::1]:3000. Addresses
+// without a host, like :3000, are kept as they are.
+func normalizeAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(strings.TrimSpace(addr))
+	if err != nil {
+		return "", fmt.Errorf("invalid address (%s): %w", addr, err)
+	}
+	portNum, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return "", fmt.Errorf("invalid port in address (%s)", addr)
+	}
+	port = strconv.FormatUint(portNum, 10)
+
+	host = strings.ToLower(host)
+	if ip, err := netip.ParseAddr(host); err == nil {
+		host = ip.Unmap().String()
+	}
+
+	return net.JoinHostPort(host, port), nil
+}
@@ -0,0 +1,33 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeAddr(t *testing.T) {
+	tests := map[string]string{
+		":3000":                   ":3000",
+		"LocalHost:3000":          "localhost:3000",
+		"poker.example.com:3000":  "poker.example.com:3000",
+		"127.0.0.1:3000":          "127.0.0.1:3000",
+		"[::1]:3000":              "[::1]:3000",
+		"[0:0:0:0:0:0:0:1]:3000":  "[::1]:3000",
+		"[2001:DB8::1]:3000":      "[2001:db8::1]:3000",
+		"[::ffff:10.0.0.1]:3000":  "10.0.0.1:3000",
+		" 192.168.1.20:3000 ":     "192.168.1.20:3000",
+		"[fe80::1%eth0]:3000":     "[fe80::1%eth0]:3000",
+		"poker.example.com:03000": "poker.example.com:3000",
+	}
+	for addr, want := range tests {
+		got, err := normalizeAddr(addr)
+		assert.Nil(t, err, addr)
+		assert.Equal(t, want, got, addr)
+	}
+
+	for _, addr := range []string{"3000", "::1:3000", "localhost", "localhost:http", "localhost:70000"} {
+		_, err := normalizeAddr(addr)
+		assert.NotNil(t, err, addr)
+	}
+}
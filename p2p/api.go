@@ -2,88 +2,457 @@ package p2p
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
 )
 
-type MyError struct {
-	err error
+// ErrorResponse is the body of every failed API request.
+type ErrorResponse struct {
+	Code  ErrorCode `json:"code"`
+	Error string    `json:"error"`
 }
 
-func (e MyError) Error() string {
-	return e.err.Error()
-}
+// ErrCodeInternal is returned for errors that are not caused by the request.
+const ErrCodeInternal ErrorCode = "INTERNAL"
+
+// ErrCodeMethodNotAllowed is returned when a route is called with the wrong
+// HTTP method, actions can only be taken with a POST.
+const ErrCodeMethodNotAllowed ErrorCode = "METHOD_NOT_ALLOWED"
 
 type apiFunc func(w http.ResponseWriter, r *http.Request) error
 
 func makeHTTPHandleFunc(f apiFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := f(w, r); err != nil {
-			JSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			writeError(w, err)
 		}
 	}
 }
 
+func writeError(w http.ResponseWriter, err error) {
+	var gameErr *GameError
+	if !errors.As(err, &gameErr) {
+		JSON(w, http.StatusInternalServerError, ErrorResponse{Code: ErrCodeInternal, Error: err.Error()})
+		return
+	}
+	JSON(w, statusCode(gameErr.Code), ErrorResponse{Code: gameErr.Code, Error: gameErr.Msg})
+}
+
+// statusCode maps the error code to the HTTP status of the response.
+func statusCode(code ErrorCode) int {
+	switch code {
+	case ErrCodeInvalidRequest:
+		return http.StatusBadRequest
+	case ErrCodeUnauthorized:
+		return http.StatusUnauthorized
+	case ErrCodeNotAllowed:
+		return http.StatusForbidden
+	case ErrCodeUnknownTable:
+		return http.StatusNotFound
+	case ErrCodeMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case ErrCodeNotYourTurn, ErrCodeWrongGameStatus:
+		return http.StatusConflict
+	case ErrCodeIllegalAction, ErrCodeIllegalAmount, ErrCodeIdempotencyKeyReused:
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func JSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	return json.NewEncoder(w).Encode(v)
 }
 
+// ErrCodeUnknownTable is returned when a route is called for a table the node
+// does not play at.
+const ErrCodeUnknownTable ErrorCode = "UNKNOWN_TABLE"
+
+// tablePrefix is the prefix of the routes of a table, every route of a table
+// is served for the first table of the node without it as well.
+const tablePrefix = "/tables/{id}"
+
 type APIServer struct {
 	listenAddr string
-	game       *GameState
+	// token needs to be sent as a bearer token with every request.
+	token string
+	// game returns the first table of the node, tables returns every table
+	// the node plays at.
+	game        func() *GameState
+	tables      func() []*GameState
+	idempotency *idempotencyStore
 }
 
-func NewAPIServer(listenAddr string, game *GameState) *APIServer {
+func NewAPIServer(listenAddr, token string, game *GameState) *APIServer {
 	return &APIServer{
-		game:       game,
-		listenAddr: listenAddr,
+		game:        func() *GameState { return game },
+		tables:      func() []*GameState { return []*GameState{game} },
+		listenAddr:  listenAddr,
+		token:       token,
+		idempotency: newIdempotencyStore(),
 	}
 }
 
 func (s *APIServer) Run() {
+	http.ListenAndServe(s.listenAddr, s.Handler())
+}
+
+// Handler returns the router of the API. Reading the state is done with a GET,
+// every route that changes the game only accepts a POST and responds with
+// the resulting state. Every request needs to be authenticated with the token.
+func (s *APIServer) Handler() http.Handler {
 	r := mux.NewRouter()
+	r.Use(s.authenticate, s.idempotent)
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, newGameError(ErrCodeMethodNotAllowed, "method (%s) is not allowed on (%s)", r.Method, r.URL.Path))
+	})
+
+	r.HandleFunc(openAPIPath, makeHTTPHandleFunc(s.handleOpenAPI)).Methods(http.MethodGet)
+	r.HandleFunc("/tables", makeHTTPHandleFunc(s.handleGetTables)).Methods(http.MethodGet)
+
+	// Every route of a table is served for the first table of the node, and
+	// for every table under its ID.
+	table := func(path string, f apiFunc, method string) {
+		r.HandleFunc(path, makeHTTPHandleFunc(f)).Methods(method)
+		r.HandleFunc(tablePrefix+path, makeHTTPHandleFunc(s.withTable(f))).Methods(method)
+	}
+	table("/state", s.handleGetState, http.MethodGet)
+	table("/ws", s.handleWebSocket, http.MethodGet)
+	table("/ledger", s.handleGetLedger, http.MethodGet)
+	table("/tournament", s.handleGetTournament, http.MethodGet)
+	table("/tournament/equity", s.handleGetEquity, http.MethodGet)
+	table("/deal/propose/{method}", s.handlePlayerProposeDeal, http.MethodPost)
+	table("/deal/accept", s.handlePlayerAcceptDeal, http.MethodPost)
+	table("/deal/decline", s.handlePlayerDeclineDeal, http.MethodPost)
+	table("/seat/{value}", s.handlePlayerTakeSeat, http.MethodPost)
+	table("/buyin/{value}", s.handlePlayerBuyIn, http.MethodPost)
+	table("/ready", s.handlePlayerReady, http.MethodPost)
+	table("/rebuy/{value}", s.handlePlayerRebuy, http.MethodPost)
+	table("/topup/{value}", s.handlePlayerTopUp, http.MethodPost)
+	table("/sitout", s.handlePlayerSitOut, http.MethodPost)
+	table("/sitin", s.handlePlayerSitIn, http.MethodPost)
+	table("/sitin/wait", s.handlePlayerSitIn, http.MethodPost)
+	table("/cashout", s.handlePlayerCashOut, http.MethodPost)
+	table("/fold", s.handlePlayerFold, http.MethodPost)
+	table("/check", s.handlePlayerCheck, http.MethodPost)
+	table("/call", s.handlePlayerCall, http.MethodPost)
+	table("/bet/{value}", s.handlePlayerBet, http.MethodPost)
+	table("/straddle", s.handlePlayerStraddle, http.MethodPost)
+	table("/bombpot", s.handlePlayerBombPot, http.MethodPost)
+	table("/runs/{value}", s.handlePlayerRuns, http.MethodPost)
+	table("/draw", s.handlePlayerDraw, http.MethodPost)
+	table("/draw/{cards}", s.handlePlayerDraw, http.MethodPost)
+
+	return r
+}
+
+// gameOf returns the game of the table the request is for.
+func (s *APIServer) gameOf(r *http.Request) *GameState {
+	if g, ok := s.findTable(mux.Vars(r)["id"]); ok {
+		return g
+	}
+	return s.game()
+}
+
+func (s *APIServer) findTable(id string) (*GameState, bool) {
+	for _, g := range s.tables() {
+		if g.ID() == id {
+			return g, true
+		}
+	}
+	return nil, false
+}
+
+// withTable only handles the request when the node plays at the table.
+func (s *APIServer) withTable(f apiFunc) apiFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		id := mux.Vars(r)["id"]
+		if _, ok := s.findTable(id); !ok {
+			return newGameError(ErrCodeUnknownTable, "we do not play at table (%s)", id)
+		}
+		return f(w, r)
+	}
+}
+
+func (s *APIServer) handleGetTables(w http.ResponseWriter, r *http.Request) error {
+	tables := []TableInfo{}
+	for _, g := range s.tables() {
+		tables = append(tables, g.Info())
+	}
+	return JSON(w, http.StatusOK, tables)
+}
+
+func (s *APIServer) handleGetState(w http.ResponseWriter, r *http.Request) error {
+	return JSON(w, http.StatusOK, s.gameOf(r).State())
+}
+
+var upgrader = websocket.Upgrader{
+	// The API is used by local UI clients that can be served from anywhere.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// handleWebSocket pushes the events of the game to the client. A client
+// first receives a snapshot of the full state, so reconnecting clients do
+// not miss anything.
+func (s *APIServer) handleWebSocket(w http.ResponseWriter, r *http.Request) error {
+	// Subscribe before taking the snapshot, so no event gets lost in between.
+	events, unsubscribe := s.gameOf(r).Subscribe()
+	defer unsubscribe()
 
-	r.HandleFunc("/ready", makeHTTPHandleFunc(s.handlePlayerReady))
-	r.HandleFunc("/fold", makeHTTPHandleFunc(s.handlePlayerFold))
-	r.HandleFunc("/check", makeHTTPHandleFunc(s.handlePlayerCheck))
-	r.HandleFunc("/bet/{value}", makeHTTPHandleFunc(s.handlePlayerBet))
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already replied to the client.
+		logrus.Errorf("websocket upgrade error: %s", err)
+		return nil
+	}
+	defer conn.Close()
 
-	http.ListenAndServe(s.listenAddr, r)
+	// Reading is only needed to notice when the client goes away.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := conn.WriteJSON(Event{Type: EventSnapshot, Data: s.gameOf(r).State()}); err != nil {
+		return nil
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return nil
+			}
+		case <-closed:
+			return nil
+		}
+	}
 }
 
 func (s *APIServer) handlePlayerBet(w http.ResponseWriter, r *http.Request) error {
-	valueStr := mux.Vars(r)["value"]
-	value, err := strconv.Atoi(valueStr)
+	value, err := intVar(r, "value")
 	if err != nil {
 		return err
 	}
 
-	if err := s.game.TakeAction(PlayerActionBet, value); err != nil {
+	if err := s.gameOf(r).TakeAction(PlayerActionBet, value); err != nil {
 		return err
 	}
-
-	return JSON(w, http.StatusOK, fmt.Sprintf("value:%d", value))
+	return s.handleGetState(w, r)
 }
 
 func (s *APIServer) handlePlayerCheck(w http.ResponseWriter, r *http.Request) error {
-	if err := s.game.TakeAction(PlayerActionCheck, 0); err != nil {
+	if err := s.gameOf(r).TakeAction(PlayerActionCheck, 0); err != nil {
 		return err
 	}
-	return JSON(w, http.StatusOK, "CHECKED")
+	return s.handleGetState(w, r)
+}
+
+func (s *APIServer) handlePlayerCall(w http.ResponseWriter, r *http.Request) error {
+	if err := s.gameOf(r).TakeAction(PlayerActionCall, 0); err != nil {
+		return err
+	}
+	return s.handleGetState(w, r)
 }
 
 func (s *APIServer) handlePlayerFold(w http.ResponseWriter, r *http.Request) error {
-	if err := s.game.TakeAction(PlayerActionFold, 0); err != nil {
+	if err := s.gameOf(r).TakeAction(PlayerActionFold, 0); err != nil {
+		return err
+	}
+	return s.handleGetState(w, r)
+}
+
+func (s *APIServer) handlePlayerTakeSeat(w http.ResponseWriter, r *http.Request) error {
+	value, err := intVar(r, "value")
+	if err != nil {
 		return err
 	}
-	return JSON(w, http.StatusOK, "FOLDED")
+
+	if err := s.gameOf(r).TakeSeat(value); err != nil {
+		return err
+	}
+	return s.handleGetState(w, r)
 }
 
 func (s *APIServer) handlePlayerReady(w http.ResponseWriter, r *http.Request) error {
-	s.game.SetReady()
-	return JSON(w, http.StatusOK, "READY")
+	if err := s.gameOf(r).SetReady(); err != nil {
+		return err
+	}
+	return s.handleGetState(w, r)
+}
+
+func (s *APIServer) handleGetLedger(w http.ResponseWriter, r *http.Request) error {
+	return JSON(w, http.StatusOK, s.gameOf(r).Ledger())
+}
+
+func (s *APIServer) handleGetTournament(w http.ResponseWriter, r *http.Request) error {
+	tournament := s.gameOf(r).tournamentProgress()
+	if tournament == nil {
+		return newGameError(ErrCodeNotAllowed, "there is no tournament played at this table")
+	}
+	return JSON(w, http.StatusOK, tournament)
+}
+
+func (s *APIServer) handleGetEquity(w http.ResponseWriter, r *http.Request) error {
+	equities, err := s.gameOf(r).Equities()
+	if err != nil {
+		return err
+	}
+	return JSON(w, http.StatusOK, equities)
+}
+
+// handlePlayerProposeDeal proposes a deal split by the method in the route,
+// like /deal/propose/icm or /deal/propose/chip_ev.
+func (s *APIServer) handlePlayerProposeDeal(w http.ResponseWriter, r *http.Request) error {
+	method := DealMethod(strings.ToUpper(mux.Vars(r)["method"]))
+	if err := s.gameOf(r).ProposeDeal(method); err != nil {
+		return err
+	}
+	return s.handleGetState(w, r)
+}
+
+func (s *APIServer) handlePlayerAcceptDeal(w http.ResponseWriter, r *http.Request) error {
+	if err := s.gameOf(r).AcceptDeal(); err != nil {
+		return err
+	}
+	return s.handleGetState(w, r)
+}
+
+func (s *APIServer) handlePlayerDeclineDeal(w http.ResponseWriter, r *http.Request) error {
+	if err := s.gameOf(r).DeclineDeal(); err != nil {
+		return err
+	}
+	return s.handleGetState(w, r)
+}
+
+func (s *APIServer) handlePlayerBuyIn(w http.ResponseWriter, r *http.Request) error {
+	value, err := intVar(r, "value")
+	if err != nil {
+		return err
+	}
+
+	if err := s.gameOf(r).BuyIn(value); err != nil {
+		return err
+	}
+	return s.handleGetState(w, r)
+}
+
+func (s *APIServer) handlePlayerRebuy(w http.ResponseWriter, r *http.Request) error {
+	value, err := intVar(r, "value")
+	if err != nil {
+		return err
+	}
+
+	if err := s.gameOf(r).Rebuy(value); err != nil {
+		return err
+	}
+	return s.handleGetState(w, r)
+}
+
+func (s *APIServer) handlePlayerTopUp(w http.ResponseWriter, r *http.Request) error {
+	value, err := intVar(r, "value")
+	if err != nil {
+		return err
+	}
+
+	if err := s.gameOf(r).TopUp(value); err != nil {
+		return err
+	}
+	return s.handleGetState(w, r)
+}
+
+func (s *APIServer) handlePlayerSitOut(w http.ResponseWriter, r *http.Request) error {
+	if err := s.gameOf(r).SitOut(); err != nil {
+		return err
+	}
+	return s.handleGetState(w, r)
+}
+
+// handlePlayerSitIn deals us in again. On /sitin we post the blinds we
+// missed, on /sitin/wait we wait for the big blind instead.
+func (s *APIServer) handlePlayerSitIn(w http.ResponseWriter, r *http.Request) error {
+	waitForBB := strings.HasSuffix(r.URL.Path, "/wait")
+	if err := s.gameOf(r).SitIn(waitForBB); err != nil {
+		return err
+	}
+	return s.handleGetState(w, r)
+}
+
+func (s *APIServer) handlePlayerCashOut(w http.ResponseWriter, r *http.Request) error {
+	if err := s.gameOf(r).CashOut(); err != nil {
+		return err
+	}
+	return s.handleGetState(w, r)
+}
+
+func (s *APIServer) handlePlayerStraddle(w http.ResponseWriter, r *http.Request) error {
+	if err := s.gameOf(r).Straddle(); err != nil {
+		return err
+	}
+	return s.handleGetState(w, r)
+}
+
+func (s *APIServer) handlePlayerBombPot(w http.ResponseWriter, r *http.Request) error {
+	if err := s.gameOf(r).VoteBombPot(); err != nil {
+		return err
+	}
+	return s.handleGetState(w, r)
+}
+
+func (s *APIServer) handlePlayerRuns(w http.ResponseWriter, r *http.Request) error {
+	value, err := intVar(r, "value")
+	if err != nil {
+		return err
+	}
+
+	if err := s.gameOf(r).SetRunPreference(value); err != nil {
+		return err
+	}
+	return s.handleGetState(w, r)
+}
+
+// handlePlayerDraw discards the cards given as a comma separated list of
+// indexes of our hole cards, like /draw/0,3. Without cards we stand pat.
+func (s *APIServer) handlePlayerDraw(w http.ResponseWriter, r *http.Request) error {
+	cards := []int{}
+	if cardsStr := mux.Vars(r)["cards"]; cardsStr != "" {
+		for _, str := range strings.Split(cardsStr, ",") {
+			card, err := strconv.Atoi(str)
+			if err != nil {
+				return newGameError(ErrCodeInvalidRequest, "invalid card (%s) to discard", str)
+			}
+			cards = append(cards, card)
+		}
+	}
+
+	if err := s.gameOf(r).Draw(cards); err != nil {
+		return err
+	}
+	return s.handleGetState(w, r)
+}
+
+// intVar parses the route variable with the given name as a number.
+func intVar(r *http.Request, name string) (int, error) {
+	str := mux.Vars(r)[name]
+	value, err := strconv.Atoi(str)
+	if err != nil {
+		return 0, newGameError(ErrCodeInvalidRequest, "invalid %s (%s)", name, str)
+	}
+	return value, nil
 }
@@ -0,0 +1,140 @@
+package p2p
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIErrors(t *testing.T) {
+	cfg := ServerConfig{
+		ListenAddr: ":3000",
+		Rotation:   Rotation{Games: []Game{{GameVariant: TexasHoldem}}}.withDefaults(),
+	}
+	api := NewAPIServer(":3001", "token", NewGame(cfg, make(chan BroadcastTo, 10)))
+	router := api.Handler()
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		code   ErrorCode
+	}{
+		{http.MethodGet, "/fold", http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed},
+		{http.MethodPost, "/bet/abc", http.StatusBadRequest, ErrCodeInvalidRequest},
+		{http.MethodPost, "/fold", http.StatusConflict, ErrCodeWrongGameStatus},
+		{http.MethodPost, "/draw/0", http.StatusConflict, ErrCodeWrongGameStatus},
+		{http.MethodPost, "/runs/0", http.StatusUnprocessableEntity, ErrCodeIllegalAmount},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(test.method, test.path, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		router.ServeHTTP(rec, req)
+
+		var resp ErrorResponse
+		assert.Nil(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, test.status, rec.Code, test.path)
+		assert.Equal(t, test.code, resp.Code, test.path)
+		assert.NotEmpty(t, resp.Error)
+	}
+}
+
+func TestAPIActionReturnsState(t *testing.T) {
+	cfg := ServerConfig{
+		ListenAddr:   ":3000",
+		Rotation:     Rotation{Games: []Game{{GameVariant: TexasHoldem}}}.withDefaults(),
+		TableOptions: TableOptions{StartingStack: 1000},
+	}
+	api := NewAPIServer(":3001", "token", NewGame(cfg, make(chan BroadcastTo, 10)))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/ready", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	api.Handler().ServeHTTP(rec, req)
+
+	var state State
+	assert.Nil(t, json.NewDecoder(rec.Body).Decode(&state))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+}
+
+func TestAPIAuthentication(t *testing.T) {
+	cfg := ServerConfig{
+		ListenAddr: ":3000",
+		TableID:    DefaultTableID,
+		Rotation:   Rotation{Games: []Game{{GameVariant: TexasHoldem}}}.withDefaults(),
+	}
+	router := NewAPIServer(":3001", "token", NewGame(cfg, make(chan BroadcastTo, 10))).Handler()
+
+	for _, auth := range []string{"", "Bearer wrong", "token", "Basic token"} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/state", nil)
+		req.Header.Set("Authorization", auth)
+		router.ServeHTTP(rec, req)
+
+		var resp ErrorResponse
+		assert.Nil(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, auth)
+		assert.Equal(t, ErrCodeUnauthorized, resp.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/state", nil)
+	req.Header.Set("Authorization", "bearer token")
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Only the WebSocket of a table takes the token from the query.
+	for path, code := range map[string]int{
+		"/state?access_token=token":          http.StatusUnauthorized,
+		"/ws?access_token=wrong":             http.StatusUnauthorized,
+		"/tables/main/ws?access_token=wrong": http.StatusUnauthorized,
+		"/tables/main/ws?access_token=token": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, code, rec.Code, path)
+	}
+}
+
+func TestAPIIdempotencyKey(t *testing.T) {
+	cfg := ServerConfig{
+		ListenAddr: ":3000",
+		Rotation:   Rotation{Games: []Game{{GameVariant: TexasHoldem}}}.withDefaults(),
+	}
+	g := NewGame(cfg, make(chan BroadcastTo, 10))
+	router := NewAPIServer(":3001", "token", g).Handler()
+
+	post := func(path, key string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set(idempotencyKeyHeader, key)
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := post("/runs/2", "abc")
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, 2, g.runPreference)
+
+	// A retry is not applied again, even when the state has changed since.
+	g.runPreference = 1
+	retry := post("/runs/2", "abc")
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, 1, g.runPreference)
+
+	reused := post("/runs/3", "abc")
+	assert.Equal(t, http.StatusUnprocessableEntity, reused.Code)
+	assert.Equal(t, 1, g.runPreference)
+
+	assert.Equal(t, http.StatusOK, post("/runs/3", "def").Code)
+	assert.Equal(t, 3, g.runPreference)
+}
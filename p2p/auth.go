@@ -0,0 +1,60 @@
+package p2p
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// ErrCodeUnauthorized is returned when a request does not carry the token of
+// the API.
+const ErrCodeUnauthorized ErrorCode = "UNAUTHORIZED"
+
+// apiTokenSize is the number of random bytes of a generated API token.
+const apiTokenSize = 32
+
+// NewAPIToken returns a random token to authenticate the API with.
+func NewAPIToken() (string, error) {
+	b := make([]byte, apiTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// authenticate only lets requests through that carry the token as a bearer
+// token in the Authorization header. Browsers cannot set headers on a
+// WebSocket, so the WebSocket of every table can pass the token as the
+// access_token query parameter instead.
+func (s *APIServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == openAPIPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := bearerToken(r)
+		if !ok && strings.HasSuffix(r.URL.Path, "/ws") {
+			token, ok = r.URL.Query().Get("access_token"), true
+		}
+
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ggpoker"`)
+			writeError(w, newGameError(ErrCodeUnauthorized, "missing or invalid bearer token"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(auth, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
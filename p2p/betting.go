@@ -0,0 +1,591 @@
+package p2p
+
+import (
+	"fmt"
+	"sync"
+)
+
+const defaultSmallBet = 10
+
+type Limit uint8
+
+func (l Limit) String() string {
+	switch l {
+	case NoLimit:
+		return "NO LIMIT"
+	case PotLimit:
+		return "POT LIMIT"
+	case FixedLimit:
+		return "FIXED LIMIT"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	NoLimit Limit = iota
+	PotLimit
+	FixedLimit
+)
+
+// ParseLimit returns the limit with the given name, like no-limit.
+func ParseLimit(name string) (Limit, error) {
+	for l := NoLimit; l <= FixedLimit; l++ {
+		if normalizeName(l.String()) == normalizeName(name) {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown betting limit (%s)", name)
+}
+
+// MarshalText encodes the limit by its name, so it can be read back with
+// UnmarshalText.
+func (l Limit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Limit) UnmarshalText(text []byte) error {
+	v, err := ParseLimit(string(text))
+	if err != nil {
+		return err
+	}
+	*l = v
+	return nil
+}
+
+// BettingStructure holds the rules for the size of the bets at the table.
+// Every player at the table needs to play with the exact same structure.
+type BettingStructure struct {
+	Limit Limit
+	// SmallBet is the size of a bet on the early streets in fixed limit. In no
+	// limit and pot limit this is the big blind and the minimum bet.
+	SmallBet int
+	// BigBet is the size of a bet on the later streets in fixed limit.
+	BigBet int
+	// RaiseCap is the maximum number of raises in a single street, the
+	// opening bet is not counted. Zero means there is no cap.
+	RaiseCap int
+	// BringIn is the forced bet of the player with the lowest up card on
+	// third street in stud games.
+	BringIn int
+}
+
+func (bs BettingStructure) String() string {
+	return fmt.Sprintf("%s %d/%d", bs.Limit, bs.SmallBet, bs.BigBet)
+}
+
+func (bs BettingStructure) smallBlind() int {
+	return bs.SmallBet / 2
+}
+
+func (bs BettingStructure) bigBlind() int {
+	return bs.SmallBet
+}
+
+// betSize returns the size of a single bet in the current street.
+func (bs BettingStructure) betSize(bigBetStreet bool) int {
+	if bigBetStreet {
+		return bs.BigBet
+	}
+	return bs.SmallBet
+}
+
+// bettingState keeps track of the chips that are put in the pot during a hand.
+type bettingState struct {
+	lock sync.RWMutex
+	// pot holds all the chips that are collected in the previous streets.
+	pot int
+	// currentBet is the highest amount a player has bet in this street.
+	currentBet int
+	// lastRaise is the size of the last bet or raise in this street. The next
+	// raise needs to be at least this big.
+	lastRaise int
+	// raises is the number of raises in this street.
+	raises int
+	// bringIn is set when the current bet is only a bring-in, the first
+	// full bet completes it and does not count as a raise.
+	bringIn bool
+	// bets holds the total amount each player has bet in this street.
+	bets map[string]int
+	// acted holds the players that acted since the last bet or raise.
+	acted map[string]bool
+	// players are the players that were dealt in the current hand.
+	players []string
+	folded  map[string]bool
+	// stacks holds the chips each player has left behind, these are kept
+	// between hands.
+	stacks map[string]int
+	// contributed holds the total amount each player put in the pot this hand.
+	contributed map[string]int
+}
+
+func newBettingState() *bettingState {
+	return &bettingState{
+		bets:        make(map[string]int),
+		acted:       make(map[string]bool),
+		players:     []string{},
+		folded:      make(map[string]bool),
+		stacks:      make(map[string]int),
+		contributed: make(map[string]int),
+	}
+}
+
+// sitDown gives the player the given stack, unless he already has chips at
+// the table.
+func (b *bettingState) sitDown(addr string, stack int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if _, ok := b.stacks[addr]; !ok {
+		b.stacks[addr] = stack
+	}
+}
+
+func (b *bettingState) stack(addr string) int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.stacks[addr]
+}
+
+// hasStack reports whether the player bought in and did not cash out yet,
+// a player that lost all his chips still has an empty stack.
+func (b *bettingState) hasStack(addr string) bool {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	_, ok := b.stacks[addr]
+	return ok
+}
+
+// stackSnapshot returns a copy of the stacks of all players.
+func (b *bettingState) stackSnapshot() map[string]int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	stacks := make(map[string]int, len(b.stacks))
+	for addr, stack := range b.stacks {
+		stacks[addr] = stack
+	}
+	return stacks
+}
+
+// addChips adds the chips the player bought to his stack and returns the new
+// stack.
+func (b *bettingState) addChips(addr string, amount int) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.stacks[addr] += amount
+	return b.stacks[addr]
+}
+
+// standUp removes the player his stack and returns the chips he had left.
+func (b *bettingState) standUp(addr string) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	stack := b.stacks[addr]
+	delete(b.stacks, addr)
+	return stack
+}
+
+// busted returns the players dealt in the hand that lost all their chips,
+// with the chips each of them put in the pot.
+func (b *bettingState) busted() map[string]int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	busted := map[string]int{}
+	for _, addr := range b.players {
+		if stack, ok := b.stacks[addr]; ok && stack == 0 {
+			busted[addr] = b.contributed[addr]
+		}
+	}
+	return busted
+}
+
+// award adds the chips won in the hand to the stack of the player.
+func (b *bettingState) award(addr string, amount int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.stacks[addr] += amount
+}
+
+// takeLocked moves chips from the stack of the player into the pot. A player
+// that does not have enough chips is all-in for less.
+func (b *bettingState) takeLocked(addr string, amount int) int {
+	if amount > b.stacks[addr] {
+		amount = b.stacks[addr]
+	}
+	b.stacks[addr] -= amount
+	b.contributed[addr] += amount
+	return amount
+}
+
+// reset clears the pot for a new hand between the given players.
+func (b *bettingState) reset(players []string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.pot = 0
+	b.currentBet = 0
+	b.lastRaise = 0
+	b.raises = 0
+	b.bringIn = false
+	b.bets = make(map[string]int)
+	b.acted = make(map[string]bool)
+	b.players = players
+	b.folded = make(map[string]bool)
+	b.contributed = make(map[string]int)
+}
+
+// nextStreet collects the bets of the current street into the pot.
+func (b *bettingState) nextStreet() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.pot = b.totalLocked()
+	b.currentBet = 0
+	b.lastRaise = 0
+	b.raises = 0
+	b.bringIn = false
+	b.bets = make(map[string]int)
+	b.acted = make(map[string]bool)
+}
+
+// postBlind puts a forced bet in the pot. Posting a blind does not count as
+// acting, the player still gets his turn in this street.
+func (b *bettingState) postBlind(addr string, value int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.bets[addr] += b.takeLocked(addr, value)
+	if b.bets[addr] > b.currentBet {
+		b.lastRaise = b.bets[addr]
+		b.currentBet = b.bets[addr]
+	}
+}
+
+// postBringIn puts the bring-in of a stud game in the pot. The bring-in is
+// smaller than a full bet, so the next bet only completes it.
+func (b *bettingState) postBringIn(addr string, value int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.bets[addr] += b.takeLocked(addr, value)
+	b.currentBet = b.bets[addr]
+	b.bringIn = true
+}
+
+// postAnte puts an ante directly in the pot.
+func (b *bettingState) postAnte(addr string, value int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.pot += b.takeLocked(addr, value)
+}
+
+// total returns the size of the pot including the bets of this street.
+func (b *bettingState) total() int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.totalLocked()
+}
+
+func (b *bettingState) totalLocked() int {
+	total := b.pot
+	for _, bet := range b.bets {
+		total += bet
+	}
+	return total
+}
+
+// potLimitMax returns the highest amount the given player can bet in a pot
+// limit game. A pot sized raise is calling the current bet first and then
+// raising by the size of the pot after that call.
+func (b *bettingState) potLimitMax(addr string) int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.potLimitMaxLocked(addr)
+}
+
+func (b *bettingState) potLimitMaxLocked(addr string) int {
+	toCall := b.currentBet - b.bets[addr]
+	return b.currentBet + b.totalLocked() + toCall
+}
+
+// bet returns the amount the given player has bet in this street.
+func (b *bettingState) bet(addr string) int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.bets[addr]
+}
+
+// currentBetSize returns the highest amount bet in this street.
+func (b *bettingState) currentBetSize() int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.currentBet
+}
+
+// betLimits returns the lowest and highest amount the given player can bet
+// in this street. It returns false when the player cannot bet or raise.
+func (b *bettingState) betLimits(addr string, bs BettingStructure, bigBetStreet bool) (int, int, bool) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	if b.currentBet > 0 && bs.RaiseCap > 0 && b.raises >= bs.RaiseCap {
+		return 0, 0, false
+	}
+	if b.acted[addr] {
+		return 0, 0, false
+	}
+	allIn := b.bets[addr] + b.stacks[addr]
+	if allIn <= b.currentBet {
+		return 0, 0, false
+	}
+
+	var min, max int
+	switch bs.Limit {
+	case FixedLimit:
+		min = b.currentBet + bs.betSize(bigBetStreet)
+		if b.bringIn {
+			min = bs.betSize(bigBetStreet)
+		}
+		max = min
+	default:
+		minRaise := b.lastRaise
+		if minRaise < bs.SmallBet {
+			minRaise = bs.SmallBet
+		}
+		min = b.currentBet + minRaise
+		max = allIn
+		if bs.Limit == PotLimit && b.potLimitMaxLocked(addr) < max {
+			max = b.potLimitMaxLocked(addr)
+		}
+	}
+
+	// A player without enough chips for the smallest bet can still go all-in.
+	if min > allIn {
+		min = allIn
+	}
+	if max > allIn {
+		max = allIn
+	}
+
+	return min, max, true
+}
+
+// toCall returns the amount the given player needs to put in to call.
+func (b *bettingState) toCall(addr string) int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.currentBet - b.bets[addr]
+}
+
+// validateAction checks the action of the given player against the betting
+// structure. For a bet the value is the total amount the player is betting
+// in this street.
+func (b *bettingState) validateAction(addr string, action PlayerAction, value int, bs BettingStructure, bigBetStreet bool) error {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	toCall := b.currentBet - b.bets[addr]
+
+	switch action {
+	case PlayerActionFold:
+		return nil
+	case PlayerActionCheck:
+		if toCall > 0 {
+			return newGameError(ErrCodeIllegalAction, "cannot check facing a bet of %d", b.currentBet)
+		}
+		return nil
+	case PlayerActionCall:
+		if toCall == 0 {
+			return newGameError(ErrCodeIllegalAction, "there is no bet to call")
+		}
+		return nil
+	case PlayerActionBet:
+	default:
+		return newGameError(ErrCodeIllegalAction, "invalid action (%s)", action)
+	}
+
+	if b.currentBet > 0 && bs.RaiseCap > 0 && b.raises >= bs.RaiseCap {
+		return newGameError(ErrCodeIllegalAction, "the betting is capped at %d raises", bs.RaiseCap)
+	}
+	// The player acted since the last full raise, an all-in for less does not
+	// reopen the betting for him.
+	if b.acted[addr] {
+		return newGameError(ErrCodeIllegalAction, "the betting is not reopened by an all-in for less than a full raise")
+	}
+
+	allIn := b.bets[addr] + b.stacks[addr]
+	if value > allIn {
+		return newGameError(ErrCodeIllegalAmount, "bet (%d) is higher than the chips of the player (%d)", value, allIn)
+	}
+	if value <= b.currentBet {
+		return newGameError(ErrCodeIllegalAmount, "bet (%d) needs to be higher than the current bet (%d)", value, b.currentBet)
+	}
+
+	// A player can always go all-in, even when he has not enough chips left
+	// for a full bet or raise.
+	switch bs.Limit {
+	case FixedLimit:
+		want := b.currentBet + bs.betSize(bigBetStreet)
+		if b.bringIn {
+			want = bs.betSize(bigBetStreet)
+		}
+		if value != want && !(value == allIn && allIn < want) {
+			return newGameError(ErrCodeIllegalAmount, "bet (%d) needs to be exactly %d in fixed limit", value, want)
+		}
+	default:
+		minRaise := b.lastRaise
+		if minRaise < bs.SmallBet {
+			minRaise = bs.SmallBet
+		}
+		if minBet := b.currentBet + minRaise; value < minBet && value != allIn {
+			return newGameError(ErrCodeIllegalAmount, "bet (%d) is lower than the minimum bet (%d)", value, minBet)
+		}
+		if bs.Limit == PotLimit {
+			if max := b.potLimitMaxLocked(addr); value > max {
+				return newGameError(ErrCodeIllegalAmount, "bet (%d) is higher than the size of the pot allows (%d)", value, max)
+			}
+		}
+	}
+
+	return nil
+}
+
+// apply updates the state with an action that is already validated.
+func (b *bettingState) apply(addr string, action PlayerAction, value int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch action {
+	case PlayerActionFold:
+		b.folded[addr] = true
+	case PlayerActionCall:
+		b.bets[addr] += b.takeLocked(addr, b.currentBet-b.bets[addr])
+	case PlayerActionBet:
+		b.bets[addr] += b.takeLocked(addr, value-b.bets[addr])
+		// An all-in for less than a full raise is not a raise, the players
+		// that already acted can only call the difference or fold.
+		raise := b.bets[addr] - b.currentBet
+		if raise >= b.lastRaise || b.bringIn {
+			if b.currentBet > 0 && !b.bringIn {
+				b.raises++
+			}
+			if raise > b.lastRaise {
+				b.lastRaise = raise
+			}
+			// Everybody needs to act again on a bet or a raise.
+			b.acted = make(map[string]bool)
+		}
+		b.bringIn = false
+		b.currentBet = b.bets[addr]
+	}
+
+	b.acted[addr] = true
+}
+
+// isActive reports whether the player is dealt in and has not folded.
+func (b *bettingState) isActive(addr string) bool {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.isActiveLocked(addr)
+}
+
+func (b *bettingState) isActiveLocked(addr string) bool {
+	if b.folded[addr] {
+		return false
+	}
+	for _, p := range b.players {
+		if p == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// activePlayers returns the players that have not folded yet.
+func (b *bettingState) activePlayers() []string {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	players := []string{}
+	for _, addr := range b.players {
+		if b.isActiveLocked(addr) {
+			players = append(players, addr)
+		}
+	}
+	return players
+}
+
+// canAct reports whether the player is still in the hand and has chips left
+// to bet with.
+func (b *bettingState) canAct(addr string) bool {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.isActiveLocked(addr) && b.stacks[addr] > 0
+}
+
+// isAllIn reports whether there is no more betting possible, because all the
+// players left in the hand, except maybe one, are all-in.
+func (b *bettingState) isAllIn() bool {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	active, canAct := 0, 0
+	for _, addr := range b.players {
+		if b.isActiveLocked(addr) {
+			active++
+			if b.stacks[addr] > 0 {
+				canAct++
+			}
+		}
+	}
+
+	return active > 1 && canAct <= 1
+}
+
+// pots returns the main pot and the side pots of the hand.
+func (b *bettingState) pots() []pot {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	active := []string{}
+	for _, addr := range b.players {
+		if b.isActiveLocked(addr) {
+			active = append(active, addr)
+		}
+	}
+
+	return buildPots(b.contributed, active)
+}
+
+// isStreetComplete reports whether every active player has acted and
+// matched the current bet.
+func (b *bettingState) isStreetComplete() bool {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	for _, addr := range b.players {
+		// Players that are all-in cannot act anymore.
+		if !b.isActiveLocked(addr) || b.stacks[addr] == 0 {
+			continue
+		}
+		if !b.acted[addr] || b.bets[addr] != b.currentBet {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,242 @@
+package p2p
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPotLimitMax(t *testing.T) {
+	var (
+		bs = BettingStructure{Limit: PotLimit, SmallBet: 10, BigBet: 20}
+		b  = newBettingState()
+	)
+	b.reset([]string{"a", "b"})
+	b.sitDown("a", 1000)
+	b.sitDown("b", 1000)
+	b.pot = 100
+
+	// Without a bet we can bet the size of the pot.
+	assert.Equal(t, 100, b.potLimitMax("a"))
+
+	// Facing a bet of 50 into 100 we can call 50 and raise the pot (200).
+	assert.Nil(t, b.validateAction("a", PlayerActionBet, 50, bs, false))
+	b.apply("a", PlayerActionBet, 50)
+	assert.Equal(t, 250, b.potLimitMax("b"))
+	assert.Nil(t, b.validateAction("b", PlayerActionBet, 250, bs, false))
+	assert.NotNil(t, b.validateAction("b", PlayerActionBet, 251, bs, false))
+
+	b.apply("b", PlayerActionCall, 0)
+	assert.True(t, b.isStreetComplete())
+
+	b.nextStreet()
+	assert.Equal(t, 200, b.total())
+	assert.Equal(t, 200, b.potLimitMax("b"))
+}
+
+func TestNoLimitMinRaise(t *testing.T) {
+	var (
+		bs = BettingStructure{Limit: NoLimit, SmallBet: 10, BigBet: 20}
+		b  = newBettingState()
+	)
+	b.reset([]string{"a", "b", "c"})
+	b.sitDown("a", 1000)
+	b.sitDown("b", 1000)
+	b.sitDown("c", 1000)
+	b.postBlind("a", 5)
+	b.postBlind("b", 10)
+
+	assert.NotNil(t, b.validateAction("c", PlayerActionCheck, 0, bs, false))
+	assert.NotNil(t, b.validateAction("c", PlayerActionBet, 15, bs, false))
+	assert.Nil(t, b.validateAction("c", PlayerActionBet, 20, bs, false))
+	assert.Nil(t, b.validateAction("c", PlayerActionBet, 1000, bs, false))
+	assert.NotNil(t, b.validateAction("c", PlayerActionBet, 1001, bs, false))
+
+	// A raise of 40 makes the next minimum raise 40 as well.
+	b.apply("c", PlayerActionBet, 50)
+	assert.NotNil(t, b.validateAction("a", PlayerActionBet, 80, bs, false))
+	assert.Nil(t, b.validateAction("a", PlayerActionBet, 90, bs, false))
+
+	b.apply("a", PlayerActionFold, 0)
+	b.apply("b", PlayerActionCall, 0)
+	assert.True(t, b.isStreetComplete())
+	assert.Equal(t, []string{"b", "c"}, b.activePlayers())
+}
+
+func TestFixedLimitRaiseCap(t *testing.T) {
+	var (
+		bs = BettingStructure{Limit: FixedLimit, SmallBet: 10, BigBet: 20, RaiseCap: 2}
+		b  = newBettingState()
+	)
+	b.reset([]string{"a", "b"})
+	b.sitDown("a", 1000)
+	b.sitDown("b", 1000)
+
+	assert.NotNil(t, b.validateAction("a", PlayerActionBet, 10, bs, true))
+	assert.Nil(t, b.validateAction("a", PlayerActionBet, 20, bs, true))
+	b.apply("a", PlayerActionBet, 20)
+
+	assert.NotNil(t, b.validateAction("b", PlayerActionBet, 60, bs, true))
+	assert.Nil(t, b.validateAction("b", PlayerActionBet, 40, bs, true))
+	b.apply("b", PlayerActionBet, 40)
+	b.apply("a", PlayerActionBet, 60)
+
+	assert.NotNil(t, b.validateAction("b", PlayerActionBet, 80, bs, true))
+	assert.Nil(t, b.validateAction("b", PlayerActionCall, 0, bs, true))
+}
+
+func TestAllIn(t *testing.T) {
+	var (
+		bs = BettingStructure{Limit: NoLimit, SmallBet: 10, BigBet: 20}
+		b  = newBettingState()
+	)
+	b.reset([]string{"a", "b", "c"})
+	b.sitDown("a", 1000)
+	b.sitDown("b", 30)
+	b.sitDown("c", 1000)
+
+	b.apply("a", PlayerActionBet, 100)
+	assert.False(t, b.isAllIn())
+
+	// Calling with less chips than the bet puts the player all-in.
+	assert.Nil(t, b.validateAction("b", PlayerActionCall, 0, bs, false))
+	b.apply("b", PlayerActionCall, 0)
+	assert.Equal(t, 0, b.stack("b"))
+	assert.False(t, b.canAct("b"))
+
+	b.apply("c", PlayerActionFold, 0)
+	assert.True(t, b.isStreetComplete())
+	assert.True(t, b.isAllIn())
+	assert.Equal(t, 130, b.total())
+}
+
+func TestAllInUnderRaise(t *testing.T) {
+	var (
+		bs = BettingStructure{Limit: NoLimit, SmallBet: 10, BigBet: 20}
+		b  = newBettingState()
+	)
+	b.reset([]string{"a", "b", "c"})
+	b.sitDown("a", 1000)
+	b.sitDown("b", 130)
+	b.sitDown("c", 1000)
+
+	// b goes all-in for less than a full raise, a can only call or fold.
+	b.apply("a", PlayerActionBet, 100)
+	assert.Nil(t, b.validateAction("b", PlayerActionBet, 130, bs, false))
+	b.apply("b", PlayerActionBet, 130)
+	assert.Equal(t, 0, b.raises)
+	assert.NotNil(t, b.validateAction("a", PlayerActionBet, 300, bs, false))
+	_, _, ok := b.betLimits("a", bs, false)
+	assert.False(t, ok)
+	assert.Nil(t, b.validateAction("a", PlayerActionCall, 0, bs, false))
+
+	// c did not act yet, he can raise the full raise of a.
+	assert.NotNil(t, b.validateAction("c", PlayerActionBet, 200, bs, false))
+	assert.Nil(t, b.validateAction("c", PlayerActionBet, 230, bs, false))
+	b.apply("c", PlayerActionCall, 0)
+	assert.False(t, b.isStreetComplete())
+	b.apply("a", PlayerActionCall, 0)
+	assert.True(t, b.isStreetComplete())
+	assert.Equal(t, 390, b.total())
+}
+
+func TestBringIn(t *testing.T) {
+	var (
+		bs = BettingStructure{Limit: FixedLimit, SmallBet: 10, BigBet: 20, RaiseCap: 1, BringIn: 5}
+		b  = newBettingState()
+	)
+	b.reset([]string{"a", "b", "c"})
+	b.sitDown("a", 1000)
+	b.sitDown("b", 1000)
+	b.sitDown("c", 1000)
+	b.postBringIn("a", 5)
+
+	// Completing the bring-in is a full bet and not a raise.
+	assert.NotNil(t, b.validateAction("b", PlayerActionBet, 15, bs, false))
+	assert.Nil(t, b.validateAction("b", PlayerActionBet, 10, bs, false))
+	b.apply("b", PlayerActionBet, 10)
+
+	assert.Nil(t, b.validateAction("c", PlayerActionBet, 20, bs, false))
+	b.apply("c", PlayerActionBet, 20)
+	assert.NotNil(t, b.validateAction("a", PlayerActionBet, 30, bs, false))
+}
+
+func TestBetLimits(t *testing.T) {
+	var (
+		bs = BettingStructure{Limit: PotLimit, SmallBet: 10, BigBet: 20}
+		b  = newBettingState()
+	)
+	b.reset([]string{"a", "b"})
+	b.sitDown("a", 1000)
+	b.sitDown("b", 60)
+	b.postBlind("a", 5)
+	b.postBlind("b", 10)
+
+	min, max, ok := b.betLimits("a", bs, false)
+	assert.True(t, ok)
+	assert.Equal(t, 20, min)
+	assert.Equal(t, 30, max)
+
+	// The short stack can only go all-in.
+	b.apply("a", PlayerActionBet, 30)
+	min, max, ok = b.betLimits("b", bs, false)
+	assert.True(t, ok)
+	assert.Equal(t, 50, min)
+	assert.Equal(t, 60, max)
+
+	bs.Limit = FixedLimit
+	min, max, _ = b.betLimits("b", bs, false)
+	assert.Equal(t, 40, min)
+	assert.Equal(t, 40, max)
+}
+
+func TestConcurrentActions(t *testing.T) {
+	g := newSitOutGame(":3000", ":4000", ":5000")
+	order := g.handOrder()
+	g.setStatus(GameStatusPreFlop)
+	g.startHand(testDeck, order, ForcedBets{})
+	assert.Equal(t, 0, int(g.currentPlayerTurn.Get()))
+
+	// Requests that arrive at the same time only call once, even when one
+	// of them waits to send the action to the other players.
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+		calls = make(chan error, 50)
+		sent  = make(chan int)
+	)
+	g.broadcastch = make(chan BroadcastTo)
+	go func() {
+		<-start
+		time.Sleep(10 * time.Millisecond)
+		n := 0
+		for range g.broadcastch {
+			n++
+		}
+		sent <- n
+	}()
+	for i := 0; i < cap(calls); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			calls <- g.TakeAction(PlayerActionCall, 0)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(calls)
+	close(g.broadcastch)
+
+	called := 0
+	for err := range calls {
+		if err == nil {
+			called++
+		}
+	}
+	assert.Equal(t, 1, called)
+	assert.Equal(t, 1, <-sent)
+	assert.Equal(t, 990, g.betting.stack(":3000"))
+}
@@ -0,0 +1,237 @@
+package p2p
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultMinBuyInBlinds is the minimum buy-in in big blinds, when the table
+// is not configured with one.
+const defaultMinBuyInBlinds = 20
+
+// BuyIn sits us down at the table with the given amount of chips.
+func (g *GameState) BuyIn(amount int) error {
+	if err := g.validateChips(g.listenAddr, LedgerBuyIn, amount); err != nil {
+		return err
+	}
+	return g.sitDown(amount)
+}
+
+// SetReady is being called when we set ourselfs as ready. When we did not
+// buy in yet we buy in with the starting stack, or enter the tournament.
+func (g *GameState) SetReady() error {
+	if !g.betting.hasStack(g.listenAddr) {
+		if g.tournament != nil {
+			return g.BuyIn(g.tournament.BuyIn)
+		}
+		return g.BuyIn(g.options.StartingStack)
+	}
+	return g.sitDown(0)
+}
+
+// sitDown seats us and tells the other players we are ready to play. The
+// buy-in is zero when we already have chips at the table.
+func (g *GameState) sitDown(buyIn int) error {
+	seat, err := g.takeSeat()
+	if err != nil {
+		return newGameError(ErrCodeNotAllowed, "%s", err)
+	}
+	if buyIn > 0 {
+		g.moveChips(g.listenAddr, LedgerBuyIn, buyIn)
+	}
+	g.publish(EventPlayerReady, PlayerEvent{Addr: g.listenAddr})
+
+	g.sendToPlayers(MessageReady{Seat: seat, BuyIn: buyIn}, g.getOtherPlayers()...)
+	g.setStatus(GameStatusPlayerReady)
+
+	return nil
+}
+
+// Rebuy buys a new stack after we lost all our chips.
+func (g *GameState) Rebuy(amount int) error {
+	return g.addChips(LedgerRebuy, amount)
+}
+
+// TopUp adds chips to our stack, up to the maximum buy-in.
+func (g *GameState) TopUp(amount int) error {
+	return g.addChips(LedgerTopUp, amount)
+}
+
+func (g *GameState) addChips(typ LedgerEntryType, amount int) error {
+	if !g.isBetweenHands() {
+		return newGameError(ErrCodeWrongGameStatus, "chips can only be added between hands")
+	}
+	if err := g.validateChips(g.listenAddr, typ, amount); err != nil {
+		return err
+	}
+
+	g.moveChips(g.listenAddr, typ, amount)
+	g.sendToPlayers(MessageAddChips{Type: typ, Amount: amount}, g.getOtherPlayers()...)
+
+	return nil
+}
+
+func (g *GameState) handleAddChips(from string, msg MessageAddChips) error {
+	if msg.Type != LedgerRebuy && msg.Type != LedgerTopUp {
+		return fmt.Errorf("player (%s) sent invalid chips type (%s)", from, msg.Type)
+	}
+	// The stacks of a hand that is being played can not change, every
+	// player drops the chips so the stacks stay the same everywhere.
+	if !g.isBetweenHands() {
+		return fmt.Errorf("player (%s) added chips during a hand", from)
+	}
+	if err := g.validateChips(from, msg.Type, msg.Amount); err != nil {
+		return err
+	}
+
+	g.moveChips(from, msg.Type, msg.Amount)
+	return nil
+}
+
+// CashOut takes our chips off the table and frees our seat.
+func (g *GameState) CashOut() error {
+	if g.tournament != nil {
+		return newGameError(ErrCodeNotAllowed, "cashing out is not allowed in a tournament")
+	}
+	if !g.isBetweenHands() {
+		return newGameError(ErrCodeWrongGameStatus, "cashing out is only allowed between hands")
+	}
+	if !g.betting.hasStack(g.listenAddr) {
+		return newGameError(ErrCodeNotAllowed, "there are no chips to cash out")
+	}
+
+	g.cashOut(g.listenAddr)
+	g.sendToPlayers(MessageCashOut{}, g.getOtherPlayers()...)
+	g.setStatus(GameStatusConnected)
+
+	return nil
+}
+
+func (g *GameState) handleCashOut(from string) error {
+	if g.tournament != nil {
+		return fmt.Errorf("player (%s) cashed out in a tournament", from)
+	}
+	if !g.isBetweenHands() {
+		return fmt.Errorf("player (%s) cashed out during a hand", from)
+	}
+	if !g.betting.hasStack(from) {
+		return fmt.Errorf("player (%s) cashed out without chips at the table", from)
+	}
+
+	g.cashOut(from)
+	return nil
+}
+
+func (g *GameState) cashOut(addr string) {
+	g.moveChips(addr, LedgerCashOut, g.betting.stack(addr))
+	g.table.release(addr)
+}
+
+// validateChips checks the amount of chips the player wants to buy. Every
+// player checks it the same way, so the stacks stay the same everywhere.
+func (g *GameState) validateChips(addr string, typ LedgerEntryType, amount int) error {
+	if g.tournament != nil {
+		if err := g.validateEntry(addr, typ); err != nil {
+			return err
+		}
+	}
+	min, max := g.options.MinBuyIn, g.options.MaxBuyIn
+
+	switch typ {
+	case LedgerBuyIn:
+		if g.betting.hasStack(addr) {
+			return newGameError(ErrCodeNotAllowed, "player (%s) already bought in, rebuy or top up instead", addr)
+		}
+	case LedgerRebuy:
+		if !g.betting.hasStack(addr) {
+			return newGameError(ErrCodeNotAllowed, "player (%s) needs to buy in first", addr)
+		}
+		if g.betting.stack(addr) > 0 {
+			return newGameError(ErrCodeNotAllowed, "a rebuy is only allowed without chips, top up instead")
+		}
+	case LedgerTopUp:
+		if !g.betting.hasStack(addr) {
+			return newGameError(ErrCodeNotAllowed, "player (%s) needs to buy in first", addr)
+		}
+		stack := g.betting.stack(addr)
+		if stack == 0 {
+			return newGameError(ErrCodeNotAllowed, "a top up is only allowed with chips behind, rebuy instead")
+		}
+		// A top up can be as small as the player wants, but his stack can
+		// not grow above the maximum buy-in.
+		min = 1
+		if max > 0 {
+			max -= stack
+		}
+	}
+
+	if min < 1 {
+		min = 1
+	}
+	if amount < min || (max > 0 && amount > max) {
+		return newGameError(ErrCodeIllegalAmount, "%s of (%d) is not allowed, it needs to be between (%d) and (%d)", typ, amount, min, max)
+	}
+
+	return nil
+}
+
+// moveChips moves chips between the player and the table and records it in
+// the ledger. The buy-in of a tournament gets the player the starting stack.
+func (g *GameState) moveChips(addr string, typ LedgerEntryType, amount int) {
+	chips := amount
+	if typ == LedgerBuyIn && g.tournament != nil {
+		chips = g.tournament.StartingStack
+	}
+
+	stack := 0
+	if typ == LedgerCashOut || typ == LedgerMoveOut {
+		g.betting.standUp(addr)
+	} else {
+		stack = g.betting.addChips(addr, chips)
+	}
+
+	entry := LedgerEntry{
+		Time:   time.Now(),
+		Addr:   addr,
+		Type:   typ,
+		Amount: amount,
+		Stack:  stack,
+	}
+	g.ledger.record(entry)
+	g.publish(EventLedgerEntry, entry)
+
+	logrus.WithFields(logrus.Fields{
+		"we":     g.listenAddr,
+		"player": addr,
+		"type":   typ,
+		"amount": amount,
+	}).Info("ledger")
+}
+
+// isBetweenHands reports whether no hand is being played right now.
+func (g *GameState) isBetweenHands() bool {
+	status := GameStatus(g.currentStatus.Get())
+	return status == GameStatusConnected || status == GameStatusPlayerReady
+}
+
+// applyStacks gives the players of a table we just joined their stacks, and
+// records them in the ledger. A player we already know keeps the stack we
+// have for him, so a joining player can not change it.
+func (g *GameState) applyStacks(stacks map[string]int) {
+	addrs := make([]string, 0, len(stacks))
+	for addr := range stacks {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	for _, addr := range addrs {
+		stack := stacks[addr]
+		if addr == g.listenAddr || stack < 0 || g.betting.hasStack(addr) {
+			continue
+		}
+		g.moveChips(addr, LedgerSeated, stack)
+	}
+}
@@ -0,0 +1,133 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newBuyInGame() (*GameState, chan BroadcastTo) {
+	broadcastch := make(chan BroadcastTo, 10)
+	cfg := ServerConfig{
+		AdvertiseAddr: "a.example.com:3000",
+		TableOptions: TableOptions{
+			StartingStack: 1000,
+			MinBuyIn:      200,
+			MaxBuyIn:      2000,
+		},
+	}
+	return NewGame(cfg, broadcastch), broadcastch
+}
+
+func TestBuyIn(t *testing.T) {
+	g, broadcastch := newBuyInGame()
+
+	assert.Equal(t, ErrCodeIllegalAmount, g.BuyIn(100).(*GameError).Code)
+	assert.Equal(t, ErrCodeIllegalAmount, g.BuyIn(2001).(*GameError).Code)
+	assert.Equal(t, ErrCodeNotAllowed, g.TopUp(100).(*GameError).Code)
+
+	assert.Nil(t, g.BuyIn(500))
+	msg := <-broadcastch
+	assert.Equal(t, MessageReady{Seat: 0, BuyIn: 500}, msg.Payload)
+	assert.Equal(t, 500, g.betting.stack(g.listenAddr))
+	assert.Equal(t, ErrCodeNotAllowed, g.BuyIn(500).(*GameError).Code)
+
+	// Being ready for the next hand does not buy in again.
+	assert.Nil(t, g.SetReady())
+	msg = <-broadcastch
+	assert.Equal(t, MessageReady{Seat: 0}, msg.Payload)
+	assert.Equal(t, 500, g.betting.stack(g.listenAddr))
+}
+
+func TestRebuyAndTopUp(t *testing.T) {
+	g, broadcastch := newBuyInGame()
+	assert.Nil(t, g.BuyIn(1500))
+	<-broadcastch
+
+	assert.Equal(t, ErrCodeNotAllowed, g.Rebuy(1000).(*GameError).Code)
+	assert.Equal(t, ErrCodeIllegalAmount, g.TopUp(501).(*GameError).Code)
+	assert.Nil(t, g.TopUp(500))
+	msg := <-broadcastch
+	assert.Equal(t, MessageAddChips{Type: LedgerTopUp, Amount: 500}, msg.Payload)
+	assert.Equal(t, 2000, g.betting.stack(g.listenAddr))
+
+	// We lost all our chips.
+	g.betting.takeLocked(g.listenAddr, 2000)
+	assert.Equal(t, ErrCodeNotAllowed, g.TopUp(100).(*GameError).Code)
+	assert.Nil(t, g.Rebuy(200))
+	<-broadcastch
+
+	g.setStatus(GameStatusPreFlop)
+	assert.Equal(t, ErrCodeWrongGameStatus, g.TopUp(100).(*GameError).Code)
+	assert.Equal(t, ErrCodeWrongGameStatus, g.CashOut().(*GameError).Code)
+}
+
+func TestCashOutAndLedger(t *testing.T) {
+	g, broadcastch := newBuyInGame()
+	assert.Nil(t, g.BuyIn(1000))
+	<-broadcastch
+
+	// The other player buys in, wins our chips and tops up.
+	g.SetPlayerReady("b.example.com:3000", 1, 1000)
+	assert.NotNil(t, g.handleAddChips("b.example.com:3000", MessageAddChips{Type: LedgerRebuy, Amount: 1000}))
+	g.betting.takeLocked(g.listenAddr, 400)
+	g.betting.award("b.example.com:3000", 400)
+	assert.NotNil(t, g.handleAddChips("b.example.com:3000", MessageAddChips{Type: LedgerTopUp, Amount: 700}))
+	assert.Nil(t, g.handleAddChips("b.example.com:3000", MessageAddChips{Type: LedgerTopUp, Amount: 600}))
+
+	assert.Nil(t, g.CashOut())
+	msg := <-broadcastch
+	assert.Equal(t, MessageCashOut{}, msg.Payload)
+	assert.Equal(t, map[string]int{"b.example.com:3000": 1}, g.table.Seats())
+	assert.Equal(t, ErrCodeNotAllowed, g.CashOut().(*GameError).Code)
+
+	ledger := g.Ledger()
+	assert.Equal(t, 4, len(ledger.Entries))
+	assert.Equal(t, LedgerEntry{Time: ledger.Entries[3].Time, Addr: "a.example.com:3000", Type: LedgerCashOut, Amount: 600}, ledger.Entries[3])
+	assert.Equal(t, []LedgerBalance{
+		{Addr: "a.example.com:3000", BoughtIn: 1000, CashedOut: 600, Net: -400},
+		{Addr: "b.example.com:3000", BoughtIn: 1600, Stack: 2000, Net: 400},
+	}, ledger.Balances)
+}
+
+func TestChipsDuringHand(t *testing.T) {
+	g, broadcastch := newBuyInGame()
+	assert.Nil(t, g.BuyIn(1000))
+	<-broadcastch
+	g.SetPlayerReady("b.example.com:3000", 1, 1000)
+
+	// Chips that are added or cashed out while a hand is played are dropped.
+	g.setStatus(GameStatusPreFlop)
+	assert.NotNil(t, g.handleAddChips("b.example.com:3000", MessageAddChips{Type: LedgerTopUp, Amount: 500}))
+	assert.NotNil(t, g.handleCashOut("b.example.com:3000"))
+	assert.Equal(t, 1000, g.betting.stack("b.example.com:3000"))
+	assert.Equal(t, map[string]int{"a.example.com:3000": 0, "b.example.com:3000": 1}, g.table.Seats())
+
+	g.setStatus(GameStatusPlayerReady)
+	assert.Nil(t, g.handleCashOut("b.example.com:3000"))
+	assert.False(t, g.betting.hasStack("b.example.com:3000"))
+}
+
+func TestApplyStacks(t *testing.T) {
+	g, broadcastch := newBuyInGame()
+	assert.Nil(t, g.BuyIn(1000))
+	<-broadcastch
+	g.SetPlayerReady("b.example.com:3000", 1, 1000)
+
+	// A joining player can not change the stacks we know.
+	g.applyStacks(map[string]int{
+		"a.example.com:3000": 5000,
+		"b.example.com:3000": 5000,
+		"c.example.com:3000": 800,
+		"d.example.com:3000": -100,
+	})
+	assert.Equal(t, map[string]int{
+		"a.example.com:3000": 1000,
+		"b.example.com:3000": 1000,
+		"c.example.com:3000": 800,
+	}, g.betting.stackSnapshot())
+
+	ledger := g.Ledger()
+	assert.Equal(t, 3, len(ledger.Entries))
+	assert.Equal(t, LedgerEntry{Time: ledger.Entries[2].Time, Addr: "c.example.com:3000", Type: LedgerSeated, Amount: 800, Stack: 800}, ledger.Entries[2])
+}
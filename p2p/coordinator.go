@@ -0,0 +1,854 @@
+package p2p
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// coordinatorRetry is the time the dealer waits before he asks the
+	// coordinator again whether he can deal, and how often a player asks at
+	// which table he sits.
+	coordinatorRetry = time.Second
+	// waitingTable is the number of the first table of a node that is not
+	// seated by the coordinator yet, no hands are dealt at it.
+	waitingTable = -1
+)
+
+// TableCoordinator keeps a table in sync with the other tables of a
+// multi-table tournament. The dealer of the table asks it before every hand
+// whether he can deal, and tells it how the hand ended. Every player asks it
+// at which table he sits.
+type TableCoordinator interface {
+	// Register enters the player in the tournament before it starts.
+	Register(addr string) error
+	// Seat returns the seat the player sits at, with the stack he brought
+	// when he moved there. It is false before the tournament starts and once
+	// the player is eliminated.
+	Seat(addr string) (PlayerMove, bool)
+	// Players returns the players that sit at the table.
+	Players(table int) []string
+	// Level returns the blind level every table plays at.
+	Level() int
+	// CanDeal reports whether the table can deal its next hand to the
+	// players. It can not when one of them moved to another table. During
+	// hand-for-hand play a table waits until every other table finished its
+	// hand.
+	CanDeal(table int, players []string) bool
+	// HandFinished takes the players that lost all their chips in the hand,
+	// with the chips each of them put in the pot, and the stacks of the
+	// players left at the table. It returns the players that move to another
+	// table.
+	HandFinished(table int, busted, stacks map[string]int) []PlayerMove
+}
+
+// PlayerMove moves a player to a seat at another table, to keep the tables
+// balanced or because his table is broken. The player brings his stack. When
+// the tournament starts every player is moved to the seat he drew, from and
+// to the same table.
+type PlayerMove struct {
+	Addr  string `json:"addr"`
+	From  int    `json:"from"`
+	To    int    `json:"to"`
+	Seat  int    `json:"seat"`
+	Stack int    `json:"stack"`
+}
+
+// TableEvent is published when a table is broken, or when the players that
+// are left are merged to the final table.
+type TableEvent struct {
+	Table int `json:"table"`
+}
+
+// HandForHandEvent is published when the money bubble starts, and when it
+// bursts.
+type HandForHandEvent struct {
+	Active bool `json:"active"`
+}
+
+// Coordinator runs a tournament on several tables. It draws the seats, keeps
+// every table at the same blind level and moves players between the tables
+// to keep them balanced. Tables are broken as players bust, until the players
+// that are left fit at the final table. On the money bubble the tables play
+// hand-for-hand: every table deals a single hand and waits for the others, so
+// the players that bust in the same round are ranked together by the chips
+// they had.
+type Coordinator struct {
+	lock sync.Mutex
+	// ts holds the schedule, the entrants and the finishing places.
+	ts    *tournamentState
+	seats int
+	// tables holds the player on every seat of every table that is still
+	// playing.
+	tables map[int]map[int]string
+	// handsAtLevel is the number of hands all tables played together at the
+	// current level.
+	handsAtLevel int
+	handForHand  bool
+	// dealt holds the tables that dealt their hand in this hand-for-hand
+	// round, and playing the tables that are in the middle of a hand.
+	dealt   map[int]bool
+	playing map[int]bool
+	// pending holds the players that busted in this hand-for-hand round,
+	// they get their places once the round is over.
+	pending map[string]int
+	// stacks holds the stack every player had after the last hand at his
+	// table, it is the stack he brings when he moves.
+	stacks map[string]int
+	// seated holds the last move of every player, the seat he drew when he
+	// did not move yet.
+	seated map[string]PlayerMove
+	events *eventBus
+}
+
+// NewTableCoordinator returns the coordinator of a tournament that is played
+// at tables with the given settings.
+func NewTableCoordinator(cfg ServerConfig) *Coordinator {
+	t := Tournament{}
+	if cfg.Tournament != nil {
+		t = *cfg.Tournament
+	}
+	cfg.Tournament = nil
+	cfg = cfg.withDefaults()
+	return NewCoordinator(t, cfg.Rotation.Games[0].BettingStructure.bigBlind(), cfg.MaxPlayers)
+}
+
+// NewCoordinator returns a coordinator for a tournament at tables with the
+// given number of seats, the blind schedule starts at the given big blind
+// when it is not configured. Players is the maximum number of entrants, zero
+// means there is no maximum.
+func NewCoordinator(t Tournament, bigBlind, seats int) *Coordinator {
+	if seats < 2 {
+		seats = defaultMaxPlayers
+	}
+	players, payouts := t.Players, t.Payouts
+	t = t.withDefaults(bigBlind, seats)
+	// The defaults of a sit-and-go are for a single table, the payouts
+	// depend on the number of entrants and are set when it starts.
+	t.Players, t.Payouts = players, payouts
+	if ValidatePayouts(payouts) != nil {
+		t.Payouts = nil
+	}
+
+	return &Coordinator{
+		ts:      newTournamentState(t),
+		seats:   seats,
+		tables:  make(map[int]map[int]string),
+		dealt:   make(map[int]bool),
+		playing: make(map[int]bool),
+		pending: make(map[string]int),
+		stacks:  make(map[string]int),
+		seated:  make(map[string]PlayerMove),
+		events:  newEventBus(),
+	}
+}
+
+// Subscribe returns a channel that receives the events of the tournament and
+// a function to stop receiving them.
+func (c *Coordinator) Subscribe() (<-chan Event, func()) {
+	return c.events.subscribe()
+}
+
+// Register enters the player in the tournament before it starts.
+func (c *Coordinator) Register(addr string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.ts.started.IsZero() {
+		return newGameError(ErrCodeNotAllowed, "the registration of the tournament is closed")
+	}
+	if indexOf(c.ts.entrants, addr) != -1 {
+		return newGameError(ErrCodeNotAllowed, "player (%s) is already registered", addr)
+	}
+	if c.ts.Players > 0 && len(c.ts.entrants) >= c.ts.Players {
+		return newGameError(ErrCodeNotAllowed, "the tournament is full")
+	}
+
+	c.ts.entrants = append(c.ts.entrants, addr)
+	return nil
+}
+
+// Start closes the registration and draws the seats, the players are spread
+// evenly over as few tables as they fit at. It returns the players of every
+// table by seat.
+func (c *Coordinator) Start() (map[int][]string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.ts.started.IsZero() {
+		return nil, newGameError(ErrCodeNotAllowed, "the tournament already started")
+	}
+	players := len(c.ts.entrants)
+	if players < 2 {
+		return nil, newGameError(ErrCodeNotAllowed, "the tournament needs at least 2 players")
+	}
+
+	draw := append([]string{}, c.ts.entrants...)
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	rnd.Shuffle(len(draw), func(i, j int) {
+		draw[i], draw[j] = draw[j], draw[i]
+	})
+
+	tables := (players + c.seats - 1) / c.seats
+	for i, addr := range draw {
+		table, seat := i%tables, i/tables
+		if c.tables[table] == nil {
+			c.tables[table] = make(map[int]string)
+		}
+		c.tables[table][seat] = addr
+		c.stacks[addr] = c.ts.StartingStack
+		c.seated[addr] = PlayerMove{Addr: addr, From: table, To: table, Seat: seat, Stack: c.ts.StartingStack}
+	}
+
+	c.ts.started = time.Now()
+	sort.Strings(c.ts.entrants)
+	if len(c.ts.Payouts) == 0 {
+		c.ts.Payouts = defaultPayouts(players)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"players": players,
+		"tables":  tables,
+	}).Info("tournament started")
+
+	return c.tablesLocked(), nil
+}
+
+// Tables returns the players of every table that is still playing by seat.
+func (c *Coordinator) Tables() map[int][]string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.tablesLocked()
+}
+
+func (c *Coordinator) tablesLocked() map[int][]string {
+	tables := make(map[int][]string, len(c.tables))
+	for id, seats := range c.tables {
+		tables[id] = playersBySeat(seats)
+	}
+	return tables
+}
+
+// Seat returns the seat the player sits at, with the stack he brought when he
+// moved there.
+func (c *Coordinator) Seat(addr string) (PlayerMove, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	m, ok := c.seated[addr]
+	if !ok || c.tables[m.To][m.Seat] != addr {
+		return PlayerMove{}, false
+	}
+	return m, true
+}
+
+// Players returns the players that sit at the table by seat.
+func (c *Coordinator) Players(table int) []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return playersBySeat(c.tables[table])
+}
+
+// isSeatedAt reports whether the player sits at the table.
+func (c *Coordinator) isSeatedAt(addr string, table int) bool {
+	for _, player := range c.tables[table] {
+		if player == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// Level returns the blind level every table plays at.
+func (c *Coordinator) Level() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ts := c.ts
+	if ts.LevelDuration > 0 && !ts.started.IsZero() {
+		level := int(time.Since(ts.started) / ts.LevelDuration)
+		if level >= len(ts.Levels) {
+			level = len(ts.Levels) - 1
+		}
+		if level > ts.level {
+			c.setLevel(level)
+		}
+	}
+	return ts.level
+}
+
+func (c *Coordinator) setLevel(level int) {
+	c.ts.level = level
+	c.handsAtLevel = 0
+
+	logrus.WithFields(logrus.Fields{
+		"level": level + 1,
+		"blind": c.ts.Levels[level],
+	}).Info("blinds going up at every table")
+}
+
+// CanDeal reports whether the table can deal its next hand to the players.
+func (c *Coordinator) CanDeal(table int, players []string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.isFinished() || len(c.tables[table]) < 2 {
+		return false
+	}
+	for _, addr := range players {
+		if !c.isSeatedAt(addr, table) {
+			// The dealer has to let the player go to his new table first.
+			return false
+		}
+	}
+	if c.handForHand {
+		if c.dealt[table] {
+			return false
+		}
+		c.dealt[table] = true
+	}
+	c.playing[table] = true
+
+	return true
+}
+
+// HandFinished places the players that busted, raises the blinds when the
+// tables played enough hands and balances the tables. It returns every move,
+// the dealer of a table moves the players of his own table. The players of
+// the other tables learn about their move when they ask for their seat.
+func (c *Coordinator) HandFinished(table int, busted, stacks map[string]int) []PlayerMove {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.playing, table)
+	for addr, stack := range stacks {
+		// The dealer only knows the stacks of the players at his table.
+		if c.isSeatedAt(addr, table) {
+			c.stacks[addr] = stack
+		}
+	}
+
+	ts := c.ts
+	c.handsAtLevel++
+	if ts.LevelDuration == 0 && c.handsAtLevel >= ts.LevelHands*len(c.tables) && ts.level < len(ts.Levels)-1 {
+		c.setLevel(ts.level + 1)
+	}
+
+	for addr, chips := range busted {
+		for seat, player := range c.tables[table] {
+			if player == addr {
+				delete(c.tables[table], seat)
+			}
+		}
+		if c.handForHand {
+			c.pending[addr] = chips
+		}
+	}
+	if !c.handForHand {
+		c.eliminate(busted)
+	} else if c.isRoundOver() {
+		c.eliminate(c.pending)
+		c.pending = make(map[string]int)
+		c.dealt = make(map[int]bool)
+	}
+
+	return c.balance()
+}
+
+// isRoundOver reports whether every table that can deal played its hand of
+// the hand-for-hand round.
+func (c *Coordinator) isRoundOver() bool {
+	if len(c.playing) > 0 {
+		return false
+	}
+	for id, seats := range c.tables {
+		if len(seats) >= 2 && !c.dealt[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// eliminate gives the players that busted at the same time their places,
+// starts hand-for-hand play on the bubble and finishes the tournament once a
+// single player is left.
+func (c *Coordinator) eliminate(busted map[string]int) {
+	ts := c.ts
+	for _, addr := range finishingOrder(busted) {
+		left := len(ts.entrants) - len(ts.results)
+		result := TournamentResult{Addr: addr, Place: left, Prize: ts.prize(left)}
+		ts.results = append(ts.results, result)
+		c.events.publish(Event{Type: EventPlayerEliminated, Data: result})
+	}
+
+	left := len(ts.entrants) - len(ts.results)
+	paid := len(ts.Payouts)
+	switch {
+	case left == 1:
+		c.finish()
+	case !c.handForHand && left == paid+1:
+		c.setHandForHand(true)
+	case c.handForHand && left <= paid:
+		c.setHandForHand(false)
+	}
+}
+
+func (c *Coordinator) setHandForHand(active bool) {
+	c.handForHand = active
+	c.dealt = make(map[int]bool)
+
+	logrus.WithFields(logrus.Fields{
+		"active": active,
+	}).Info("hand-for-hand")
+
+	c.events.publish(Event{Type: EventHandForHand, Data: HandForHandEvent{Active: active}})
+}
+
+// finish gives the first place to the player that holds all the chips.
+func (c *Coordinator) finish() {
+	c.handForHand = false
+	for _, seats := range c.tables {
+		for _, addr := range seats {
+			c.ts.results = append(c.ts.results, TournamentResult{Addr: addr, Place: 1, Prize: c.ts.prize(1)})
+		}
+	}
+
+	results := c.resultsLocked()
+	logrus.WithFields(logrus.Fields{
+		"winner": results[0].Addr,
+	}).Info("tournament finished")
+
+	c.events.publish(Event{Type: EventTournamentFinished, Data: TournamentFinishedEvent{Results: results}})
+}
+
+func (c *Coordinator) isFinished() bool {
+	for _, result := range c.ts.results {
+		if result.Place == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// balance breaks the smallest table when the players that are left fit at
+// the other tables. Otherwise players move from the biggest tables to the
+// smallest, until no table has more than a single player more than another.
+// Only tables that are between hands are broken or lose players, a table
+// that is playing a hand is balanced once it finished it. Players can move
+// to a table that is playing, they are dealt in the next hand.
+func (c *Coordinator) balance() []PlayerMove {
+	moves := []PlayerMove{}
+	if c.isFinished() {
+		return moves
+	}
+
+	for len(c.tables) > 1 {
+		left := 0
+		for _, seats := range c.tables {
+			left += len(seats)
+		}
+		if left > (len(c.tables)-1)*c.seats {
+			break
+		}
+
+		table, ok := c.smallestIdleTable()
+		if !ok {
+			break
+		}
+		players := playersBySeat(c.tables[table])
+		delete(c.tables, table)
+		delete(c.dealt, table)
+		for _, addr := range players {
+			moves = append(moves, c.move(addr, table, c.smallestTable()))
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"table": table,
+		}).Info("breaking table")
+
+		c.events.publish(Event{Type: EventTableBroken, Data: TableEvent{Table: table}})
+		if len(c.tables) == 1 {
+			for id := range c.tables {
+				logrus.WithFields(logrus.Fields{
+					"table": id,
+				}).Info("final table")
+
+				c.events.publish(Event{Type: EventFinalTable, Data: TableEvent{Table: id}})
+			}
+		}
+	}
+
+	for _, table := range c.tableIDs() {
+		if c.playing[table] {
+			continue
+		}
+		for {
+			to := c.smallestTable()
+			if len(c.tables[table]) <= len(c.tables[to])+1 {
+				break
+			}
+			players := playersBySeat(c.tables[table])
+			addr := players[len(players)-1]
+			for seat, player := range c.tables[table] {
+				if player == addr {
+					delete(c.tables[table], seat)
+				}
+			}
+			moves = append(moves, c.move(addr, table, to))
+		}
+	}
+
+	return moves
+}
+
+// move seats the player on the first free seat of the table.
+func (c *Coordinator) move(addr string, from, to int) PlayerMove {
+	seat := 0
+	for c.tables[to][seat] != "" {
+		seat++
+	}
+	c.tables[to][seat] = addr
+
+	m := PlayerMove{Addr: addr, From: from, To: to, Seat: seat, Stack: c.stacks[addr]}
+	c.seated[addr] = m
+	logrus.WithFields(logrus.Fields{
+		"player": addr,
+		"from":   from,
+		"to":     to,
+		"seat":   seat,
+		"stack":  m.Stack,
+	}).Info("moving player")
+
+	c.events.publish(Event{Type: EventPlayerMoved, Data: m})
+	return m
+}
+
+// smallestTable returns the table with the fewest players, the lowest table
+// when there is a tie.
+func (c *Coordinator) smallestTable() int {
+	ids := c.tableIDs()
+	smallest := ids[0]
+	for _, id := range ids[1:] {
+		if len(c.tables[id]) < len(c.tables[smallest]) {
+			smallest = id
+		}
+	}
+	return smallest
+}
+
+// smallestIdleTable returns the table with the fewest players that is
+// between hands, it is only returned when no table has fewer players.
+func (c *Coordinator) smallestIdleTable() (int, bool) {
+	fewest := len(c.tables[c.smallestTable()])
+	for _, id := range c.tableIDs() {
+		if len(c.tables[id]) == fewest && !c.playing[id] {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+func (c *Coordinator) tableIDs() []int {
+	ids := make([]int, 0, len(c.tables))
+	for id := range c.tables {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// CoordinatorState is the progress of a multi-table tournament.
+type CoordinatorState struct {
+	// Level is the blind level of every table, the first level is 1.
+	Level       int  `json:"level"`
+	BigBlind    int  `json:"bigBlind"`
+	Ante        int  `json:"ante,omitempty"`
+	HandForHand bool `json:"handForHand"`
+	FinalTable  bool `json:"finalTable"`
+	Entrants    int  `json:"entrants"`
+	PlayersLeft int  `json:"playersLeft"`
+	PrizePool   int  `json:"prizePool"`
+	Started     bool `json:"started"`
+	Finished    bool `json:"finished"`
+	// Tables holds the players of every table that is still playing by
+	// seat.
+	Tables map[int][]string `json:"tables"`
+	// Results are the finishing places of the players that are eliminated,
+	// starting with the best place.
+	Results []TournamentResult `json:"results"`
+}
+
+// State returns the progress of the tournament.
+func (c *Coordinator) State() CoordinatorState {
+	level := c.Level()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ts := c.ts
+	state := CoordinatorState{
+		Level:       level + 1,
+		BigBlind:    ts.Levels[level].BigBlind,
+		Ante:        ts.Levels[level].Ante,
+		HandForHand: c.handForHand,
+		FinalTable:  !ts.started.IsZero() && len(c.tables) == 1,
+		Entrants:    len(ts.entrants),
+		PlayersLeft: len(ts.entrants) - len(ts.results),
+		PrizePool:   ts.prizePool(),
+		Started:     !ts.started.IsZero(),
+		Finished:    c.isFinished(),
+		Tables:      c.tablesLocked(),
+		Results:     c.resultsLocked(),
+	}
+	if state.Finished {
+		state.PlayersLeft = 1
+	}
+	return state
+}
+
+func (c *Coordinator) resultsLocked() []TournamentResult {
+	results := append([]TournamentResult{}, c.ts.results...)
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Place < results[j].Place
+	})
+	return results
+}
+
+// playersBySeat returns the players of the table ordered by their seat.
+func playersBySeat(seats map[int]string) []string {
+	positions := make([]int, 0, len(seats))
+	for seat := range seats {
+		positions = append(positions, seat)
+	}
+	sort.Ints(positions)
+
+	players := make([]string, len(positions))
+	for i, seat := range positions {
+		players[i] = seats[seat]
+	}
+	return players
+}
+
+// reportHand tells the coordinator how the hand ended at our table. Only the
+// dealer reports, he moves the players the coordinator asks for and tells the
+// other players at the table to do the same.
+func (g *GameState) reportHand(busted map[string]int) {
+	moves := g.coordinator.HandFinished(g.coordinatorTable, busted, g.betting.stackSnapshot())
+	if moved := g.movePlayers(moves); len(moved) > 0 {
+		g.sendToPlayers(MessageMovePlayers{Moves: moved}, g.getOtherPlayers()...)
+	}
+}
+
+// letPlayersGo moves the players the coordinator moved away from our table
+// while it was waiting for its next hand. The dealer does it before he deals,
+// the coordinator does not let him deal to them.
+func (g *GameState) letPlayersGo() {
+	seated := g.coordinator.Players(g.coordinatorTable)
+	moves := []PlayerMove{}
+	for addr := range g.betting.stackSnapshot() {
+		if indexOf(seated, addr) != -1 {
+			continue
+		}
+		if m, ok := g.coordinator.Seat(addr); ok {
+			moves = append(moves, m)
+		}
+	}
+
+	if moved := g.movePlayers(moves); len(moved) > 0 {
+		g.sendToPlayers(MessageMovePlayers{Moves: moved}, g.getOtherPlayers()...)
+	}
+}
+
+func (g *GameState) handleMovePlayers(from string, msg MessageMovePlayers) error {
+	if g.coordinator == nil {
+		return fmt.Errorf("player (%s) moved players at a table without a coordinator", from)
+	}
+	if _, err := g.table.GetPlayer(from); err != nil {
+		return fmt.Errorf("player (%s) moved players without sitting at the table", from)
+	}
+	for _, m := range msg.Moves {
+		if seat, ok := g.coordinator.Seat(m.Addr); !ok || seat != m {
+			return fmt.Errorf("player (%s) moved player (%s) to a seat the coordinator did not give him", from, m.Addr)
+		}
+	}
+
+	g.movePlayers(msg.Moves)
+	return nil
+}
+
+// movePlayers takes the players that move to another table off our table,
+// their chips go with them. It returns the moves of the players that left.
+func (g *GameState) movePlayers(moves []PlayerMove) []PlayerMove {
+	moved := []PlayerMove{}
+	for _, m := range moves {
+		if m.From != g.coordinatorTable || m.To == m.From || !g.betting.hasStack(m.Addr) {
+			continue
+		}
+
+		g.moveChips(m.Addr, LedgerMoveOut, g.betting.stack(m.Addr))
+		g.table.release(m.Addr)
+		g.publish(EventPlayerMoved, m)
+		moved = append(moved, m)
+
+		if m.Addr == g.listenAddr {
+			g.setStatus(GameStatusConnected)
+		}
+	}
+	return moved
+}
+
+// leaveTable takes us off our table when the coordinator moved us to another
+// table, and tells the other players in case the dealer did not.
+func (g *GameState) leaveTable(m PlayerMove) {
+	if moved := g.movePlayers([]PlayerMove{m}); len(moved) > 0 {
+		g.sendToPlayers(MessageMovePlayers{Moves: moved}, g.getOtherPlayers()...)
+	}
+}
+
+// arrive seats us at the table the coordinator moved us to, with the stack we
+// bring from our old table.
+func (g *GameState) arrive(m PlayerMove) {
+	g.arrivalLock.Lock()
+	g.arrival = &m
+	g.arrivalLock.Unlock()
+
+	g.claimSeat(g.listenAddr, m.Seat, false)
+	g.moveChips(g.listenAddr, LedgerMoveIn, m.Stack)
+	g.setStatus(GameStatusPlayerReady)
+}
+
+// announceArrival tells a player that joins the table at which seat we sit
+// and the stack we brought, until the first hand after we arrived is over.
+// Until then the players only take our stack from the coordinator.
+func (g *GameState) announceArrival(addr string) {
+	g.arrivalLock.Lock()
+	m := g.arrival
+	g.arrivalLock.Unlock()
+
+	if m != nil {
+		g.sendToPlayers(MessageTakeSeat{Seat: m.Seat, Stack: m.Stack}, addr)
+	}
+}
+
+// settleArrival is called once a hand is over, the players that join the
+// table from now on take our stack from the other players.
+func (g *GameState) settleArrival() {
+	g.arrivalLock.Lock()
+	defer g.arrivalLock.Unlock()
+
+	g.arrival = nil
+}
+
+// isArriving reports whether we did not play a hand at the table since the
+// coordinator moved us here.
+func (g *GameState) isArriving() bool {
+	g.arrivalLock.Lock()
+	defer g.arrivalLock.Unlock()
+
+	return g.arrival != nil
+}
+
+// handleArrival seats the player the coordinator moved to our table with the
+// stack he brings, once the coordinator confirms the move.
+func (g *GameState) handleArrival(from string, msg MessageTakeSeat) error {
+	if g.coordinator == nil {
+		return fmt.Errorf("player (%s) brought chips to a table without a coordinator", from)
+	}
+	m, ok := g.coordinator.Seat(from)
+	if !ok || m.To != g.coordinatorTable || m.Seat != msg.Seat || m.Stack != msg.Stack {
+		return fmt.Errorf("player (%s) was not moved to seat (%d) of our table with (%d) chips", from, msg.Seat, msg.Stack)
+	}
+
+	g.claimSeat(from, m.Seat, false)
+	if !g.betting.hasStack(from) {
+		g.moveChips(from, LedgerMoveIn, m.Stack)
+	}
+	g.publish(EventPlayerReady, PlayerEvent{Addr: from})
+	g.scheduleDeal()
+
+	return nil
+}
+
+// coordinatorLoop enters us in the tournament of the coordinator and keeps us
+// at the table it seats us at, until the node stops.
+func (s *Server) coordinatorLoop() {
+	ticker := time.NewTicker(coordinatorRetry)
+	defer ticker.Stop()
+
+	registered := false
+	for ; ; <-ticker.C {
+		if !registered {
+			err := s.Coordinator.Register(s.AdvertiseAddr)
+			if gameErr, ok := err.(*GameError); err != nil && !(ok && gameErr.Code == ErrCodeNotAllowed) {
+				logrus.WithField("we", s.AdvertiseAddr).Errorf("cannot register for the tournament: %s", err)
+				continue
+			}
+			// A registration that is not allowed means we are registered
+			// already, or the tournament started without us.
+			registered = true
+		}
+
+		m, ok := s.Coordinator.Seat(s.AdvertiseAddr)
+		if !ok {
+			continue
+		}
+		if err := s.followSeat(m); err != nil {
+			logrus.WithField("we", s.AdvertiseAddr).Errorf("cannot move to table (%d): %s", m.To, err)
+		}
+	}
+}
+
+// coordinatedTableID returns the ID of the table of the tournament with the
+// given number.
+func (s *Server) coordinatedTableID(table int) string {
+	return fmt.Sprintf("%s-%d", s.TableID, table)
+}
+
+// followSeat joins the table the coordinator seats us at. When it moved us we
+// leave our old table between hands, and bring our stack to the new table.
+func (s *Server) followSeat(m PlayerMove) error {
+	id := s.coordinatedTableID(m.To)
+	if _, ok := s.table(id); ok {
+		return nil
+	}
+
+	var old *tableServer
+	for _, t := range s.tableList() {
+		if t.Coordinator != nil {
+			old = t
+		}
+	}
+	if old != nil {
+		g := old.gameState
+		if _, err := g.table.GetPlayer(s.AdvertiseAddr); err == nil && !g.isBetweenHands() {
+			// We finish the hand first.
+			return nil
+		}
+		g.leaveTable(m)
+	}
+
+	_, err := s.addTable(ServerConfig{
+		TableID:          id,
+		Rotation:         s.Rotation,
+		TableOptions:     s.TableOptions,
+		MaxPlayers:       s.MaxPlayers,
+		Tournament:       s.Tournament,
+		Coordinator:      s.Coordinator,
+		CoordinatorTable: m.To,
+		BootstrapPeers:   s.Coordinator.Players(m.To),
+	}, &m)
+	if err != nil {
+		return err
+	}
+	if old != nil {
+		return s.RemoveTable(old.TableID)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package p2p
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// CoordinatorOpenAPI is the OpenAPI document describing every route of the
+// Coordinator.
+//
+//go:embed coordinator.yaml
+var CoordinatorOpenAPI []byte
+
+type registerRequest struct {
+	Addr string `json:"addr"`
+}
+
+type levelResponse struct {
+	Level int `json:"level"`
+}
+
+type dealRequest struct {
+	Players []string `json:"players"`
+}
+
+type dealResponse struct {
+	CanDeal bool `json:"canDeal"`
+}
+
+type handRequest struct {
+	Busted map[string]int `json:"busted"`
+	Stacks map[string]int `json:"stacks"`
+}
+
+// isFull reports whether the maximum number of players registered.
+func (c *Coordinator) isFull() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.ts.Players > 0 && len(c.ts.entrants) >= c.ts.Players
+}
+
+// Run serves the API of the coordinator on the given address, the nodes of
+// the tournament need the token.
+func (c *Coordinator) Run(listenAddr, token string) error {
+	logrus.WithField("listenAddr", listenAddr).Info("starting coordinator")
+	return http.ListenAndServe(listenAddr, c.Handler(token))
+}
+
+// Handler returns the router of the coordinator. Reading the state of the
+// tournament is public, the nodes that play it need the token.
+func (c *Coordinator) Handler(token string) http.Handler {
+	r := mux.NewRouter()
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, newGameError(ErrCodeMethodNotAllowed, "method (%s) is not allowed on (%s)", r.Method, r.URL.Path))
+	})
+
+	withToken := func(f apiFunc) apiFunc {
+		return requireToken(token, f)
+	}
+
+	r.HandleFunc(openAPIPath, makeHTTPHandleFunc(c.handleOpenAPI)).Methods(http.MethodGet)
+	r.HandleFunc("/state", makeHTTPHandleFunc(c.handleGetState)).Methods(http.MethodGet)
+	r.HandleFunc("/players", makeHTTPHandleFunc(withToken(c.handleRegister))).Methods(http.MethodPost)
+	r.HandleFunc("/start", makeHTTPHandleFunc(withToken(c.handleStart))).Methods(http.MethodPost)
+	r.HandleFunc("/players/{addr}/seat", makeHTTPHandleFunc(withToken(c.handleGetSeat))).Methods(http.MethodGet)
+	r.HandleFunc("/level", makeHTTPHandleFunc(withToken(c.handleGetLevel))).Methods(http.MethodGet)
+	r.HandleFunc("/tables/{table}/players", makeHTTPHandleFunc(withToken(c.handleGetPlayers))).Methods(http.MethodGet)
+	r.HandleFunc("/tables/{table}/deal", makeHTTPHandleFunc(withToken(c.handleDeal))).Methods(http.MethodPost)
+	r.HandleFunc("/tables/{table}/hand", makeHTTPHandleFunc(withToken(c.handleHandFinished))).Methods(http.MethodPost)
+
+	return r
+}
+
+func (c *Coordinator) handleOpenAPI(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/yaml")
+	_, err := w.Write(CoordinatorOpenAPI)
+	return err
+}
+
+func (c *Coordinator) handleGetState(w http.ResponseWriter, r *http.Request) error {
+	return JSON(w, http.StatusOK, c.State())
+}
+
+// handleRegister enters the player, the tournament starts once the maximum
+// number of players registered.
+func (c *Coordinator) handleRegister(w http.ResponseWriter, r *http.Request) error {
+	var req registerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Addr == "" {
+		return newGameError(ErrCodeInvalidRequest, "the request needs the address of the player")
+	}
+	addr, err := normalizeAddr(req.Addr)
+	if err != nil {
+		return newGameError(ErrCodeInvalidRequest, "%s", err)
+	}
+
+	if err := c.Register(addr); err != nil {
+		return err
+	}
+	if c.isFull() {
+		if _, err := c.Start(); err != nil {
+			return err
+		}
+	}
+	return JSON(w, http.StatusOK, c.State())
+}
+
+func (c *Coordinator) handleStart(w http.ResponseWriter, r *http.Request) error {
+	if _, err := c.Start(); err != nil {
+		return err
+	}
+	return JSON(w, http.StatusOK, c.State())
+}
+
+func (c *Coordinator) handleGetSeat(w http.ResponseWriter, r *http.Request) error {
+	addr := mux.Vars(r)["addr"]
+	m, ok := c.Seat(addr)
+	if !ok {
+		return newGameError(ErrCodeUnknownTable, "player (%s) does not sit at a table", addr)
+	}
+	return JSON(w, http.StatusOK, m)
+}
+
+func (c *Coordinator) handleGetLevel(w http.ResponseWriter, r *http.Request) error {
+	return JSON(w, http.StatusOK, levelResponse{Level: c.Level()})
+}
+
+func (c *Coordinator) handleGetPlayers(w http.ResponseWriter, r *http.Request) error {
+	table, err := tableNumber(r)
+	if err != nil {
+		return err
+	}
+	return JSON(w, http.StatusOK, c.Players(table))
+}
+
+func (c *Coordinator) handleDeal(w http.ResponseWriter, r *http.Request) error {
+	table, err := tableNumber(r)
+	if err != nil {
+		return err
+	}
+	var req dealRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return newGameError(ErrCodeInvalidRequest, "invalid players: %s", err)
+	}
+	return JSON(w, http.StatusOK, dealResponse{CanDeal: c.CanDeal(table, req.Players)})
+}
+
+func (c *Coordinator) handleHandFinished(w http.ResponseWriter, r *http.Request) error {
+	table, err := tableNumber(r)
+	if err != nil {
+		return err
+	}
+	var req handRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return newGameError(ErrCodeInvalidRequest, "invalid hand: %s", err)
+	}
+	return JSON(w, http.StatusOK, c.HandFinished(table, req.Busted, req.Stacks))
+}
+
+func tableNumber(r *http.Request) (int, error) {
+	s := mux.Vars(r)["table"]
+	table, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, newGameError(ErrCodeInvalidRequest, "invalid table (%s)", s)
+	}
+	return table, nil
+}
+
+var coordinatorClient = &http.Client{Timeout: 5 * time.Second}
+
+// RemoteCoordinator is the coordinator of a multi-table tournament that is
+// reached over its API, like a coordinator started with ggpoker coordinator
+// run. A table that can not reach it does not deal.
+type RemoteCoordinator struct {
+	addr  string
+	token string
+	// level is the last level the coordinator returned, it is used while the
+	// coordinator can not be reached.
+	level int32
+}
+
+// NewRemoteCoordinator returns the coordinator at the given address, like
+// coordinator.example.com:3200 or https://coordinator.example.com.
+func NewRemoteCoordinator(addr, token string) *RemoteCoordinator {
+	return &RemoteCoordinator{
+		addr:  baseURL(addr),
+		token: token,
+	}
+}
+
+// do sends the request and decodes the response into v. A failed request
+// returns the error of the coordinator.
+func (c *RemoteCoordinator) do(method, path string, body, v any) error {
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, c.addr+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := coordinatorClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Code == "" {
+			return fmt.Errorf("coordinator responded with %s", resp.Status)
+		}
+		return newGameError(errResp.Code, "%s", errResp.Error)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// logError logs a request to the coordinator that failed.
+func (c *RemoteCoordinator) logError(path string, err error) {
+	logrus.WithFields(logrus.Fields{
+		"coordinator": c.addr,
+		"path":        path,
+	}).Errorf("coordinator request failed: %s", err)
+}
+
+func (c *RemoteCoordinator) Register(addr string) error {
+	var state CoordinatorState
+	return c.do(http.MethodPost, "/players", registerRequest{Addr: addr}, &state)
+}
+
+func (c *RemoteCoordinator) Seat(addr string) (PlayerMove, bool) {
+	var m PlayerMove
+	if err := c.do(http.MethodGet, "/players/"+url.PathEscape(addr)+"/seat", nil, &m); err != nil {
+		if gameErr, ok := err.(*GameError); !ok || gameErr.Code != ErrCodeUnknownTable {
+			c.logError("seat", err)
+		}
+		return PlayerMove{}, false
+	}
+	return m, true
+}
+
+func (c *RemoteCoordinator) Players(table int) []string {
+	players := []string{}
+	if err := c.do(http.MethodGet, fmt.Sprintf("/tables/%d/players", table), nil, &players); err != nil {
+		c.logError("players", err)
+		return []string{}
+	}
+	return players
+}
+
+func (c *RemoteCoordinator) Level() int {
+	var resp levelResponse
+	if err := c.do(http.MethodGet, "/level", nil, &resp); err != nil {
+		c.logError("level", err)
+		return int(atomic.LoadInt32(&c.level))
+	}
+	atomic.StoreInt32(&c.level, int32(resp.Level))
+	return resp.Level
+}
+
+func (c *RemoteCoordinator) CanDeal(table int, players []string) bool {
+	var resp dealResponse
+	if err := c.do(http.MethodPost, fmt.Sprintf("/tables/%d/deal", table), dealRequest{Players: players}, &resp); err != nil {
+		c.logError("deal", err)
+		return false
+	}
+	return resp.CanDeal
+}
+
+func (c *RemoteCoordinator) HandFinished(table int, busted, stacks map[string]int) []PlayerMove {
+	moves := []PlayerMove{}
+	req := handRequest{Busted: busted, Stacks: stacks}
+	if err := c.do(http.MethodPost, fmt.Sprintf("/tables/%d/hand", table), req, &moves); err != nil {
+		c.logError("hand", err)
+		return []PlayerMove{}
+	}
+	return moves
+}
@@ -0,0 +1,399 @@
+package p2p
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCoordinator(t *testing.T, tournament Tournament, players, seats int) *Coordinator {
+	c := NewCoordinator(tournament, 20, seats)
+	for i := 0; i < players; i++ {
+		assert.Nil(t, c.Register(fmt.Sprintf("p%02d.example.com:3000", i)))
+	}
+	return c
+}
+
+// nextEvent returns the next event of the given type.
+func nextEvent(events <-chan Event, t EventType) Event {
+	for e := range events {
+		if e.Type == t {
+			return e
+		}
+	}
+	return Event{}
+}
+
+func TestCoordinatorSeating(t *testing.T) {
+	c := newTestCoordinator(t, Tournament{}, 14, 6)
+	assert.Equal(t, ErrCodeNotAllowed, c.Register("p00.example.com:3000").(*GameError).Code)
+
+	tables, err := c.Start()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(tables))
+	seat, ok := c.Seat(tables[2][3])
+	assert.True(t, ok)
+	assert.Equal(t, PlayerMove{Addr: tables[2][3], From: 2, To: 2, Seat: 3, Stack: c.ts.StartingStack}, seat)
+	assert.Equal(t, 5, len(tables[0]))
+	assert.Equal(t, 5, len(tables[1]))
+	assert.Equal(t, 4, len(tables[2]))
+
+	seated := map[string]bool{}
+	for _, players := range tables {
+		for _, addr := range players {
+			seated[addr] = true
+		}
+	}
+	assert.Equal(t, 14, len(seated))
+
+	assert.Equal(t, ErrCodeNotAllowed, c.Register("p99.example.com:3000").(*GameError).Code)
+	_, err = c.Start()
+	assert.NotNil(t, err)
+	assert.Equal(t, []int{50, 30, 20}, c.ts.Payouts)
+
+	full := newTestCoordinator(t, Tournament{Players: 2}, 2, 6)
+	assert.Equal(t, ErrCodeNotAllowed, full.Register("p99.example.com:3000").(*GameError).Code)
+}
+
+func TestCoordinatorBalancing(t *testing.T) {
+	c := newTestCoordinator(t, Tournament{Payouts: []int{100}}, 9, 3)
+	events, stop := c.Subscribe()
+	defer stop()
+
+	tables, err := c.Start()
+	assert.Nil(t, err)
+	for table := range tables {
+		assert.True(t, c.CanDeal(table, nil))
+	}
+
+	// Table 0 loses two players, it has to wait for the other tables.
+	moves := c.HandFinished(0, map[string]int{tables[0][0]: 100, tables[0][1]: 200}, nil)
+	assert.Empty(t, moves)
+	assert.False(t, c.CanDeal(0, nil))
+	assert.Equal(t, []TournamentResult{
+		{Addr: tables[0][1], Place: 8},
+		{Addr: tables[0][0], Place: 9},
+	}, c.State().Results)
+
+	// Table 1 gives the player on its last seat to table 0, he brings his
+	// stack. The table can not deal to him anymore.
+	moves = c.HandFinished(1, nil, map[string]int{tables[1][0]: 4000, tables[1][1]: 1000, tables[1][2]: 1000})
+	assert.Equal(t, []PlayerMove{{Addr: tables[1][2], From: 1, To: 0, Seat: 0, Stack: 1000}}, moves)
+	seat, ok := c.Seat(tables[1][2])
+	assert.True(t, ok)
+	assert.Equal(t, moves[0], seat)
+	assert.Equal(t, []string{tables[1][2], tables[0][2]}, c.Players(0))
+	assert.False(t, c.CanDeal(1, tables[1]))
+	assert.True(t, c.CanDeal(1, tables[1][:2]))
+
+	// The 6 players that are left fit at two tables, table 0 is broken.
+	moves = c.HandFinished(2, map[string]int{tables[2][0]: 100}, nil)
+	assert.Equal(t, 2, len(moves))
+	for _, m := range moves {
+		assert.Equal(t, 0, m.From)
+	}
+	assert.Equal(t, TableEvent{Table: 0}, nextEvent(events, EventTableBroken).Data)
+	tables = c.Tables()
+	assert.Equal(t, 2, len(tables))
+	assert.Equal(t, 3, len(tables[1]))
+	assert.Equal(t, 3, len(tables[2]))
+
+	// Table 2 waits for players while table 1 is still playing.
+	moves = c.HandFinished(2, map[string]int{tables[2][0]: 100, tables[2][1]: 100}, nil)
+	assert.Empty(t, moves)
+
+	// The 3 players that are left are merged to the final table.
+	moves = c.HandFinished(1, map[string]int{tables[1][0]: 100}, nil)
+	assert.Equal(t, []PlayerMove{{Addr: tables[2][2], From: 2, To: 1, Seat: 0, Stack: c.ts.StartingStack}}, moves)
+	assert.Equal(t, TableEvent{Table: 1}, nextEvent(events, EventFinalTable).Data)
+
+	state := c.State()
+	assert.True(t, state.FinalTable)
+	assert.Equal(t, 3, state.PlayersLeft)
+	assert.Equal(t, 3, len(state.Tables[1]))
+}
+
+func TestHandForHand(t *testing.T) {
+	c := newTestCoordinator(t, Tournament{BuyIn: 100, Payouts: []int{50, 30, 20}}, 6, 3)
+	events, stop := c.Subscribe()
+	defer stop()
+
+	tables, err := c.Start()
+	assert.Nil(t, err)
+	assert.True(t, c.CanDeal(0, nil))
+	assert.True(t, c.CanDeal(1, nil))
+
+	c.HandFinished(0, map[string]int{tables[0][0]: 100}, nil)
+	assert.True(t, c.CanDeal(0, nil))
+
+	// With 4 players left and 3 places paid the tables play hand-for-hand.
+	c.HandFinished(1, map[string]int{tables[1][0]: 100}, nil)
+	assert.Equal(t, HandForHandEvent{Active: true}, nextEvent(events, EventHandForHand).Data)
+	assert.True(t, c.State().HandForHand)
+
+	// Table 0 was still playing, it finishes its hand and starts the round.
+	assert.Empty(t, c.HandFinished(0, nil, nil))
+	assert.True(t, c.CanDeal(0, nil))
+	assert.True(t, c.CanDeal(1, nil))
+
+	// A player busts at table 0, the table waits for table 1 to finish.
+	c.HandFinished(0, map[string]int{tables[0][1]: 300}, nil)
+	assert.False(t, c.CanDeal(0, nil))
+	assert.Equal(t, 2, len(c.State().Results))
+
+	// The player at table 1 started the round with less chips.
+	c.HandFinished(1, map[string]int{tables[1][1]: 200}, nil)
+	assert.Equal(t, HandForHandEvent{Active: false}, nextEvent(events, EventHandForHand).Data)
+
+	results := c.State().Results
+	assert.Equal(t, TournamentResult{Addr: tables[0][1], Place: 3, Prize: 120}, results[0])
+	assert.Equal(t, TournamentResult{Addr: tables[1][1], Place: 4}, results[1])
+
+	// Heads up at the final table.
+	state := c.State()
+	assert.False(t, state.HandForHand)
+	assert.True(t, state.FinalTable)
+	assert.Equal(t, 2, state.PlayersLeft)
+	assert.Equal(t, 600, state.PrizePool)
+}
+
+func TestCoordinatorLevels(t *testing.T) {
+	c := newTestCoordinator(t, Tournament{
+		Levels:     []BlindLevel{{BigBlind: 20}, {BigBlind: 40}},
+		LevelHands: 2,
+	}, 4, 2)
+	_, err := c.Start()
+	assert.Nil(t, err)
+
+	// Both tables play 2 hands at every level.
+	for hand := 0; hand < 3; hand++ {
+		c.HandFinished(hand%2, nil, nil)
+		assert.Equal(t, 0, c.Level())
+	}
+	c.HandFinished(1, nil, nil)
+	assert.Equal(t, 1, c.Level())
+	assert.Equal(t, 40, c.State().BigBlind)
+
+	// The last level is played until the tournament is over.
+	for hand := 0; hand < 4; hand++ {
+		c.HandFinished(hand%2, nil, nil)
+	}
+	assert.Equal(t, 1, c.Level())
+}
+
+type testCoordinator struct {
+	level  int
+	busted map[string]int
+	moves  []PlayerMove
+}
+
+func (c *testCoordinator) Level() int {
+	return c.level
+}
+
+func (c *testCoordinator) Register(addr string) error {
+	return nil
+}
+
+func (c *testCoordinator) Seat(addr string) (PlayerMove, bool) {
+	for _, m := range c.moves {
+		if m.Addr == addr {
+			return m, true
+		}
+	}
+	return PlayerMove{}, false
+}
+
+func (c *testCoordinator) Players(table int) []string {
+	return []string{}
+}
+
+func (c *testCoordinator) CanDeal(table int, players []string) bool {
+	return true
+}
+
+func (c *testCoordinator) HandFinished(table int, busted, stacks map[string]int) []PlayerMove {
+	c.busted = busted
+	return c.moves
+}
+
+func TestTableWithCoordinator(t *testing.T) {
+	var (
+		a = "a.example.com:3000"
+		b = "b.example.com:3000"
+		c = "c.example.com:3000"
+	)
+	coordinator := &testCoordinator{
+		level: 1,
+		moves: []PlayerMove{{Addr: b, From: 0, To: 1, Seat: 4, Stack: 1500}},
+	}
+	broadcastch := make(chan BroadcastTo, 10)
+	g := NewGame(ServerConfig{
+		AdvertiseAddr: a,
+		Rotation:      Rotation{Games: []Game{{GameVariant: TexasHoldem}}}.withDefaults(),
+		TableOptions:  TableOptions{StartingStack: 1500, MinBuyIn: 100, MaxBuyIn: 100},
+		Tournament: &Tournament{
+			BuyIn:         100,
+			StartingStack: 1500,
+			Players:       3,
+			Levels:        []BlindLevel{{BigBlind: 20}, {BigBlind: 40}},
+			LevelHands:    10,
+			Payouts:       []int{100},
+		},
+		Coordinator: coordinator,
+	}, broadcastch)
+
+	assert.Nil(t, g.SetReady())
+	<-broadcastch
+	g.AddPlayer(b)
+	g.AddPlayer(c)
+	g.SetPlayerReady(b, 1, 100)
+	g.SetPlayerReady(c, 2, 100)
+	g.startTournament([]string{a, b, c})
+	assert.Equal(t, 1, g.nextLevel())
+
+	// c busts and b moves to another table.
+	g.betting.reset([]string{a, b, c})
+	g.betting.takeLocked(c, 1500)
+	g.betting.award(a, 1500)
+	g.eliminateBustedPlayers(true)
+
+	assert.Equal(t, map[string]int{c: 1500}, coordinator.busted)
+	assert.Equal(t, []string{a}, g.dealOrder())
+	assert.False(t, g.betting.hasStack(b))
+	assert.Empty(t, g.TournamentResults())
+
+	msg := <-broadcastch
+	assert.Equal(t, MessageMovePlayers{Moves: coordinator.moves}, msg.Payload)
+	assert.NotNil(t, g.handleMovePlayers("d.example.com:3000", MessageMovePlayers{}))
+	ledger := g.Ledger()
+	assert.Equal(t, LedgerMoveOut, ledger.Entries[len(ledger.Entries)-1].Type)
+	assert.Equal(t, 1500, ledger.Entries[len(ledger.Entries)-1].Amount)
+
+	// d moves to our table with his stack, the coordinator needs to confirm
+	// the seat and the stack he claims.
+	d := "d.example.com:3000"
+	coordinator.moves = []PlayerMove{{Addr: d, From: 1, To: 0, Seat: 3, Stack: 2200}}
+	assert.NotNil(t, g.handleTakeSeat(d, MessageTakeSeat{Seat: 3, Stack: 5000}))
+	assert.NotNil(t, g.handleTakeSeat(c, MessageTakeSeat{Seat: 4, Stack: 2200}))
+	assert.NotNil(t, g.handleMovePlayers(a, MessageMovePlayers{Moves: []PlayerMove{{Addr: a, From: 0, To: 1, Seat: 0}}}))
+	assert.False(t, g.betting.hasStack(d))
+
+	assert.Nil(t, g.handleTakeSeat(d, MessageTakeSeat{Seat: 3, Stack: 2200}))
+	assert.Equal(t, 2200, g.betting.stack(d))
+	player, err := g.table.GetPlayer(d)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, player.tablePos)
+	ledger = g.Ledger()
+	entry := ledger.Entries[len(ledger.Entries)-1]
+	assert.Equal(t, []any{d, LedgerMoveIn, 2200}, []any{entry.Addr, entry.Type, entry.Amount})
+}
+
+func TestRemoteCoordinator(t *testing.T) {
+	c := NewCoordinator(Tournament{Players: 4, Payouts: []int{100}}, 20, 3)
+	server := httptest.NewServer(c.Handler("token"))
+	defer server.Close()
+
+	assert.Equal(t, ErrCodeUnauthorized, NewRemoteCoordinator(server.URL, "wrong").Register("p00.example.com:3000").(*GameError).Code)
+
+	remote := NewRemoteCoordinator(server.URL, "token")
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, remote.Register(fmt.Sprintf("p%02d.example.com:3000", i)))
+	}
+	_, ok := remote.Seat("p00.example.com:3000")
+	assert.False(t, ok)
+
+	// The tournament starts once the last player registered.
+	assert.Nil(t, remote.Register("p03.example.com:3000"))
+	tables := c.Tables()
+	assert.Equal(t, 2, len(tables))
+	assert.Equal(t, tables[1], remote.Players(1))
+	seat, ok := remote.Seat(tables[1][1])
+	assert.True(t, ok)
+	assert.Equal(t, PlayerMove{Addr: tables[1][1], From: 1, To: 1, Seat: 1, Stack: c.ts.StartingStack}, seat)
+
+	assert.True(t, remote.CanDeal(0, tables[0]))
+	assert.False(t, remote.CanDeal(1, tables[0]))
+	assert.Equal(t, 0, remote.Level())
+
+	// Table 1 is broken, its last player moves to table 0 with his stack.
+	moves := remote.HandFinished(1, map[string]int{tables[1][0]: 100}, map[string]int{tables[1][1]: 2500})
+	assert.Equal(t, []PlayerMove{{Addr: tables[1][1], From: 1, To: 0, Seat: 2, Stack: 2500}}, moves)
+	assert.Equal(t, 3, c.State().PlayersLeft)
+}
+
+func TestCoordinatedNodes(t *testing.T) {
+	c := NewCoordinator(Tournament{Payouts: []int{100}}, 20, 6)
+	cfg := func(listenAddr string, bootstrap ...string) ServerConfig {
+		return ServerConfig{
+			ListenAddr:     listenAddr,
+			APIListenAddr:  ":0",
+			BootstrapPeers: bootstrap,
+			Tournament:     &Tournament{Payouts: []int{100}},
+			Coordinator:    c,
+		}
+	}
+	a := NewServer(cfg(":23130"))
+	b := NewServer(cfg(":23132", ":23130"))
+	go a.Start()
+	go b.Start()
+
+	assert.Eventually(t, func() bool {
+		return c.State().Entrants == 2
+	}, 10*time.Second, 50*time.Millisecond)
+	_, err := c.Start()
+	assert.Nil(t, err)
+
+	// Both players leave the table they waited at and sit down at the
+	// table they drew, every player knows the stacks they brought.
+	assert.Eventually(t, func() bool {
+		for _, s := range []*Server{a, b} {
+			g, ok := s.Table("main-0")
+			if !ok || len(g.betting.stackSnapshot()) != 2 {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 50*time.Millisecond)
+
+	for _, s := range []*Server{a, b} {
+		assert.Equal(t, 1, len(s.Tables()))
+		g, _ := s.Table("main-0")
+		assert.Equal(t, g, s.firstTable())
+		for _, entry := range g.Ledger().Entries {
+			assert.Equal(t, LedgerMoveIn, entry.Type)
+			assert.Equal(t, c.ts.StartingStack, entry.Amount)
+		}
+	}
+}
+
+func TestNineMaxTables(t *testing.T) {
+	c := newTestCoordinator(t, Tournament{Payouts: []int{100}}, 18, 9)
+	tables, err := c.Start()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(tables))
+	assert.Equal(t, 9, len(tables[1]))
+
+	// The players on the last seats of the table are seated as well.
+	cfg := ServerConfig{
+		AdvertiseAddr:    tables[1][0],
+		MaxPlayers:       9,
+		Tournament:       &Tournament{Payouts: []int{100}},
+		Coordinator:      c,
+		CoordinatorTable: 1,
+	}.withDefaults()
+	g := NewGame(cfg, make(chan BroadcastTo, 10))
+	for _, addr := range tables[1][6:] {
+		m, ok := c.Seat(addr)
+		assert.True(t, ok)
+		assert.Nil(t, g.handleArrival(addr, MessageTakeSeat{Seat: m.Seat, Stack: m.Stack}))
+
+		player, err := g.table.GetPlayer(addr)
+		assert.Nil(t, err)
+		assert.Equal(t, m.Seat, player.tablePos)
+	}
+	assert.Equal(t, 3, len(g.table.Players()))
+}
@@ -0,0 +1,505 @@
+package p2p
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// dealTimeout is the time the players have to accept a deal, after that it
+// is withdrawn and the tournament is played on.
+const dealTimeout = time.Minute
+
+// DealMethod is how the prizes that are left are split in a deal.
+type DealMethod string
+
+const (
+	// DealICM splits the prizes by the Independent Chip Model.
+	DealICM DealMethod = "ICM"
+	// DealChipEV splits the prizes by the share of the chips of every player.
+	DealChipEV DealMethod = "CHIP_EV"
+)
+
+// ICM returns the equity of every stack in the prizes, starting with the
+// prize of the winner, by the Independent Chip Model. The chance a player
+// finishes first is his share of the chips, and the chance he finishes in a
+// lower place is the same share of the chips of the players that are left
+// once the places above him are taken.
+func ICM(stacks []int, prizes []int) []float64 {
+	equity := make([]float64, len(stacks))
+	total := 0
+	for _, stack := range stacks {
+		total += stack
+	}
+	if len(prizes) > len(stacks) {
+		prizes = prizes[:len(stacks)]
+	}
+
+	placed := make([]bool, len(stacks))
+	var finish func(place, chips int, chance float64)
+	finish = func(place, chips int, chance float64) {
+		if place == len(prizes) || chips == 0 {
+			return
+		}
+		for i, stack := range stacks {
+			if placed[i] || stack == 0 {
+				continue
+			}
+			p := chance * float64(stack) / float64(chips)
+			equity[i] += p * float64(prizes[place])
+
+			placed[i] = true
+			finish(place+1, chips-stack, p)
+			placed[i] = false
+		}
+	}
+	finish(0, total, 1)
+
+	return equity
+}
+
+// ChipEV returns the equity of every stack in the prizes when every chip is
+// worth the same.
+func ChipEV(stacks []int, prizes []int) []float64 {
+	equity := make([]float64, len(stacks))
+	total, pool := 0, 0
+	for _, stack := range stacks {
+		total += stack
+	}
+	for i, prize := range prizes {
+		if i < len(stacks) {
+			pool += prize
+		}
+	}
+	if total == 0 {
+		return equity
+	}
+
+	for i, stack := range stacks {
+		equity[i] = float64(pool) * float64(stack) / float64(total)
+	}
+	return equity
+}
+
+// Equity is what the stack of a player that is still in the tournament is
+// worth of the prizes that are left.
+type Equity struct {
+	Addr   string  `json:"addr"`
+	Stack  int     `json:"stack"`
+	ICM    float64 `json:"icm"`
+	ChipEV float64 `json:"chipEV"`
+}
+
+// DealPayout is the prize a player gets in a deal.
+type DealPayout struct {
+	Addr  string `json:"addr"`
+	Stack int    `json:"stack"`
+	Prize int    `json:"prize"`
+}
+
+// Deal splits the prizes that are left between the players that are still
+// in the tournament, instead of playing it out. It is made once every one of
+// them accepted it.
+type Deal struct {
+	Method   DealMethod   `json:"method"`
+	Proposer string       `json:"proposer"`
+	Payouts  []DealPayout `json:"payouts"`
+	// Accepted holds the players that accepted the deal so far.
+	Accepted []string `json:"accepted"`
+}
+
+func (d *Deal) String() string {
+	return fmt.Sprintf("%s deal %v", d.Method, d.Payouts)
+}
+
+// equal reports whether both deals pay the same prizes.
+func (d *Deal) equal(other *Deal) bool {
+	if d.Method != other.Method || len(d.Payouts) != len(other.Payouts) {
+		return false
+	}
+	for i := range d.Payouts {
+		if d.Payouts[i] != other.Payouts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// isIn reports whether the player gets a prize in the deal.
+func (d *Deal) isIn(addr string) bool {
+	for _, payout := range d.Payouts {
+		if payout.Addr == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// playersLeft returns the players that are still in the tournament.
+func (g *GameState) playersLeft() []string {
+	g.tournament.lock.RLock()
+	defer g.tournament.lock.RUnlock()
+
+	players := []string{}
+	for _, addr := range g.tournament.entrants {
+		if g.betting.stack(addr) > 0 {
+			players = append(players, addr)
+		}
+	}
+	return players
+}
+
+// validateDeal checks whether the players can make a deal right now. Deals
+// are only made between hands of a sit-and-go, so every player splits the
+// prizes by the same stacks.
+func (g *GameState) validateDeal() error {
+	if g.tournament == nil {
+		return newGameError(ErrCodeNotAllowed, "there is no tournament played at this table")
+	}
+	if g.coordinator != nil {
+		return newGameError(ErrCodeNotAllowed, "deals are not allowed in a multi-table tournament")
+	}
+	if g.isRegistering() {
+		return newGameError(ErrCodeNotAllowed, "the tournament did not start yet")
+	}
+	if g.tournamentFinished() {
+		return newGameError(ErrCodeNotAllowed, "the tournament is finished")
+	}
+	if !g.isBetweenHands() {
+		return newGameError(ErrCodeWrongGameStatus, "deals are only made between hands")
+	}
+	return nil
+}
+
+// Equities returns what the stacks of the players that are still in the
+// tournament are worth of the prizes that are left.
+func (g *GameState) Equities() ([]Equity, error) {
+	if g.tournament == nil {
+		return nil, newGameError(ErrCodeNotAllowed, "there is no tournament played at this table")
+	}
+	if g.coordinator != nil {
+		return nil, newGameError(ErrCodeNotAllowed, "deals are not allowed in a multi-table tournament")
+	}
+
+	players, stacks, prizes := g.prizesLeft()
+	icm := ICM(stacks, prizes)
+	chipEV := ChipEV(stacks, prizes)
+
+	equities := make([]Equity, len(players))
+	for i, addr := range players {
+		equities[i] = Equity{Addr: addr, Stack: stacks[i], ICM: icm[i], ChipEV: chipEV[i]}
+	}
+	return equities, nil
+}
+
+// prizesLeft returns the players that are still in the tournament with their
+// stacks, and the prizes of the places they play for.
+func (g *GameState) prizesLeft() ([]string, []int, []int) {
+	players := g.playersLeft()
+	stacks := make([]int, len(players))
+	for i, addr := range players {
+		stacks[i] = g.betting.stack(addr)
+	}
+
+	ts := g.tournament
+	ts.lock.RLock()
+	defer ts.lock.RUnlock()
+
+	prizes := make([]int, len(players))
+	for i := range prizes {
+		prizes[i] = ts.prize(i + 1)
+	}
+	return players, stacks, prizes
+}
+
+// newDeal splits the prizes that are left by the given method. The chips
+// that are lost when the equities are rounded go to the chip leader.
+func (g *GameState) newDeal(proposer string, method DealMethod) (*Deal, error) {
+	players, stacks, prizes := g.prizesLeft()
+	if len(players) < 2 {
+		return nil, newGameError(ErrCodeNotAllowed, "a deal needs at least 2 players")
+	}
+
+	var equity []float64
+	switch method {
+	case DealICM:
+		equity = ICM(stacks, prizes)
+	case DealChipEV:
+		equity = ChipEV(stacks, prizes)
+	default:
+		return nil, newGameError(ErrCodeInvalidRequest, "unknown deal method (%s)", method)
+	}
+
+	deal := &Deal{
+		Method:   method,
+		Proposer: proposer,
+		Payouts:  make([]DealPayout, len(players)),
+		Accepted: []string{},
+	}
+	leader, pool, paid := 0, 0, 0
+	for i, addr := range players {
+		deal.Payouts[i] = DealPayout{Addr: addr, Stack: stacks[i], Prize: int(equity[i])}
+		if stacks[i] > stacks[leader] {
+			leader = i
+		}
+		pool += prizes[i]
+		paid += deal.Payouts[i].Prize
+	}
+	deal.Payouts[leader].Prize += pool - paid
+
+	return deal, nil
+}
+
+// ProposeDeal proposes to split the prizes that are left by the given method.
+// No hand is dealt until every player accepted the deal, one of them
+// declined it or it was not accepted in time.
+func (g *GameState) ProposeDeal(method DealMethod) error {
+	if err := g.validateDeal(); err != nil {
+		return err
+	}
+	if g.betting.stack(g.listenAddr) == 0 {
+		return newGameError(ErrCodeNotAllowed, "we are not in the tournament anymore")
+	}
+
+	deal, err := g.newDeal(g.listenAddr, method)
+	if err != nil {
+		return err
+	}
+	g.setDeal(deal)
+	g.sendToPlayers(MessageProposeDeal{Method: method, Payouts: deal.Payouts}, g.getOtherPlayers()...)
+
+	_, err = g.acceptDeal(g.listenAddr, deal)
+	return err
+}
+
+func (g *GameState) handleProposeDeal(from string, msg MessageProposeDeal) error {
+	if err := g.validateDeal(); err != nil {
+		return fmt.Errorf("player (%s) proposed a deal: %s", from, err)
+	}
+
+	deal, err := g.newDeal(from, msg.Method)
+	if err != nil {
+		return err
+	}
+	if !deal.equal(&Deal{Method: msg.Method, Payouts: msg.Payouts}) {
+		return fmt.Errorf("deal of player (%s) does not match %s", from, deal)
+	}
+	if !deal.isIn(from) {
+		return fmt.Errorf("player (%s) proposed a deal without being in the tournament", from)
+	}
+	g.setDeal(deal)
+
+	_, err = g.acceptDeal(from, deal)
+	return err
+}
+
+// setDeal replaces the deal that is proposed. The players that accepted the
+// deal before it reached us accept it now, and it is withdrawn when not every
+// player accepted it in time.
+func (g *GameState) setDeal(deal *Deal) {
+	ts := g.tournament
+	ts.lock.Lock()
+	ts.deal = deal
+	accepted := []string{}
+	for addr, early := range ts.earlyAccepts {
+		if deal.equal(early) {
+			accepted = append(accepted, addr)
+			delete(ts.earlyAccepts, addr)
+		}
+	}
+	ts.lock.Unlock()
+
+	g.publish(EventDealProposed, *deal)
+	time.AfterFunc(dealTimeout, func() { g.expireDeal(deal) })
+
+	sort.Strings(accepted)
+	for _, addr := range accepted {
+		if _, err := g.acceptDeal(addr, deal); err != nil {
+			logrus.Errorf("invalid deal acceptance of player (%s): %s", addr, err)
+		}
+	}
+}
+
+// AcceptDeal accepts the deal that is proposed.
+func (g *GameState) AcceptDeal() error {
+	if g.tournament == nil {
+		return newGameError(ErrCodeNotAllowed, "there is no tournament played at this table")
+	}
+	deal, err := g.acceptDeal(g.listenAddr, nil)
+	if err != nil {
+		return err
+	}
+
+	g.sendToPlayers(MessageAcceptDeal{Method: deal.Method, Payouts: deal.Payouts}, g.getOtherPlayers()...)
+	return nil
+}
+
+func (g *GameState) handleAcceptDeal(from string, msg MessageAcceptDeal) error {
+	if g.tournament == nil {
+		return fmt.Errorf("player (%s) accepted a deal in a cash game", from)
+	}
+	_, err := g.acceptDeal(from, &Deal{Method: msg.Method, Payouts: msg.Payouts})
+	return err
+}
+
+// acceptDeal records that the player accepted the given deal, or the deal
+// that is proposed when it is nil. A deal that is not proposed yet is
+// accepted once it reaches us, the acceptance can arrive before the proposal.
+// Once every player accepted the deal the tournament is finished.
+func (g *GameState) acceptDeal(addr string, accepted *Deal) (*Deal, error) {
+	ts := g.tournament
+	ts.lock.Lock()
+	deal := ts.deal
+	if accepted != nil && (deal == nil || !deal.equal(accepted)) {
+		ts.earlyAccepts[addr] = accepted
+		ts.lock.Unlock()
+		return nil, nil
+	}
+	if deal == nil {
+		ts.lock.Unlock()
+		return nil, newGameError(ErrCodeNotAllowed, "there is no deal to accept")
+	}
+	if !deal.isIn(addr) {
+		ts.lock.Unlock()
+		return nil, newGameError(ErrCodeNotAllowed, "player (%s) is not in the deal", addr)
+	}
+	for _, accepted := range deal.Accepted {
+		if accepted == addr {
+			ts.lock.Unlock()
+			return nil, newGameError(ErrCodeNotAllowed, "player (%s) already accepted the deal", addr)
+		}
+	}
+	deal.Accepted = append(deal.Accepted, addr)
+	made := len(deal.Accepted) == len(deal.Payouts)
+	ts.lock.Unlock()
+
+	g.publish(EventDealAccepted, PlayerEvent{Addr: addr})
+	if made {
+		g.finishByDeal(deal)
+	}
+	return deal, nil
+}
+
+// DeclineDeal declines the deal that is proposed, the tournament is played
+// on.
+func (g *GameState) DeclineDeal() error {
+	if g.tournament == nil {
+		return newGameError(ErrCodeNotAllowed, "there is no tournament played at this table")
+	}
+	if err := g.declineDeal(g.listenAddr); err != nil {
+		return err
+	}
+
+	g.sendToPlayers(MessageDeclineDeal{}, g.getOtherPlayers()...)
+	return nil
+}
+
+func (g *GameState) handleDeclineDeal(from string) error {
+	if g.tournament == nil {
+		return fmt.Errorf("player (%s) declined a deal in a cash game", from)
+	}
+	return g.declineDeal(from)
+}
+
+func (g *GameState) declineDeal(addr string) error {
+	ts := g.tournament
+	ts.lock.Lock()
+	if ts.deal == nil {
+		ts.lock.Unlock()
+		return newGameError(ErrCodeNotAllowed, "there is no deal to decline")
+	}
+	if !ts.deal.isIn(addr) {
+		ts.lock.Unlock()
+		return newGameError(ErrCodeNotAllowed, "player (%s) is not in the deal", addr)
+	}
+	ts.deal = nil
+	ts.lock.Unlock()
+
+	g.publish(EventDealDeclined, PlayerEvent{Addr: addr})
+	g.scheduleDeal()
+	return nil
+}
+
+// withdrawDeal drops a deal that is still proposed when the dealer started
+// the next hand before it reached him, with the acceptances of deals that
+// were never proposed.
+func (g *GameState) withdrawDeal() {
+	if g.tournament == nil {
+		return
+	}
+
+	g.tournament.lock.Lock()
+	defer g.tournament.lock.Unlock()
+
+	g.tournament.earlyAccepts = make(map[string]*Deal)
+	if g.tournament.deal != nil && !g.tournament.finished() {
+		g.tournament.deal = nil
+	}
+}
+
+// expireDeal withdraws the deal when not every player accepted it in time, so
+// the next hand can be dealt.
+func (g *GameState) expireDeal(deal *Deal) {
+	ts := g.tournament
+	ts.lock.Lock()
+	if ts.deal != deal || ts.finished() {
+		ts.lock.Unlock()
+		return
+	}
+	ts.deal = nil
+	ts.lock.Unlock()
+
+	g.publish(EventDealWithdrawn, *deal)
+	g.scheduleDeal()
+}
+
+// finishByDeal finishes the tournament with the prizes of the deal. The
+// players that are left are placed by their stacks.
+func (g *GameState) finishByDeal(deal *Deal) {
+	payouts := append([]DealPayout{}, deal.Payouts...)
+	sort.SliceStable(payouts, func(i, j int) bool {
+		return payouts[i].Stack > payouts[j].Stack
+	})
+
+	ts := g.tournament
+	ts.lock.Lock()
+	for i, payout := range payouts {
+		ts.results = append(ts.results, TournamentResult{
+			Addr:  payout.Addr,
+			Place: i + 1,
+			Prize: payout.Prize,
+			Deal:  true,
+		})
+	}
+	ts.lock.Unlock()
+
+	g.finishTournament()
+}
+
+// isNegotiating reports whether a deal is proposed that not every player
+// accepted yet.
+func (g *GameState) isNegotiating() bool {
+	if g.tournament == nil {
+		return false
+	}
+
+	g.tournament.lock.RLock()
+	defer g.tournament.lock.RUnlock()
+
+	return g.tournament.deal != nil && !g.tournament.finished()
+}
+
+// tournamentFinished reports whether the tournament has a winner, or was
+// finished by a deal.
+func (g *GameState) tournamentFinished() bool {
+	if g.tournament == nil {
+		return false
+	}
+
+	g.tournament.lock.RLock()
+	defer g.tournament.lock.RUnlock()
+
+	return g.tournament.finished()
+}
@@ -0,0 +1,118 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestICM(t *testing.T) {
+	equity := ICM([]int{5000, 3000, 2000}, []int{50, 30, 20})
+	assert.InDelta(t, 38.393, equity[0], 0.001)
+	assert.InDelta(t, 32.75, equity[1], 0.001)
+	assert.InDelta(t, 28.857, equity[2], 0.001)
+
+	// Heads up with a single prize the equity is the share of the chips.
+	equity = ICM([]int{3000, 1000}, []int{100, 0})
+	assert.InDelta(t, 75, equity[0], 0.001)
+	assert.InDelta(t, 25, equity[1], 0.001)
+
+	equity = ChipEV([]int{5000, 3000, 2000}, []int{50, 30, 20})
+	assert.InDelta(t, 50, equity[0], 0.001)
+	assert.InDelta(t, 20, equity[2], 0.001)
+
+	assert.Equal(t, []float64{0, 0}, ICM([]int{0, 0}, []int{100}))
+	assert.Equal(t, []float64{0, 0}, ChipEV([]int{0, 0}, []int{100}))
+}
+
+func TestDeal(t *testing.T) {
+	var (
+		a = "a.example.com:3000"
+		b = "b.example.com:3000"
+		c = "c.example.com:3000"
+	)
+	g, broadcastch := newTournamentGame(3)
+	assert.Equal(t, ErrCodeNotAllowed, g.ProposeDeal(DealICM).(*GameError).Code)
+
+	assert.Nil(t, g.SetReady())
+	<-broadcastch
+	g.SetPlayerReady(b, 1, 100)
+	g.SetPlayerReady(c, 2, 100)
+	order := []string{a, b, c}
+	g.startTournament(order)
+
+	g.betting.reset(order)
+	g.betting.takeLocked(c, 1000)
+	g.betting.award(a, 1000)
+
+	equities, err := g.Equities()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(equities))
+	assert.Equal(t, c, equities[2].Addr)
+	assert.Equal(t, 500, equities[2].Stack)
+	assert.InDelta(t, 40.833, equities[2].ICM, 0.001)
+	assert.InDelta(t, 33.333, equities[2].ChipEV, 0.001)
+
+	assert.Equal(t, ErrCodeInvalidRequest, g.ProposeDeal("HALF").(*GameError).Code)
+	assert.Equal(t, ErrCodeNotAllowed, g.AcceptDeal().(*GameError).Code)
+
+	// The chips lost when the ICM equities are rounded go to the chip leader.
+	assert.Nil(t, g.ProposeDeal(DealICM))
+	msg := <-broadcastch
+	payouts := []DealPayout{
+		{Addr: a, Stack: 2500, Prize: 149},
+		{Addr: b, Stack: 1500, Prize: 111},
+		{Addr: c, Stack: 500, Prize: 40},
+	}
+	assert.Equal(t, MessageProposeDeal{Method: DealICM, Payouts: payouts}, msg.Payload)
+	assert.True(t, g.isNegotiating())
+	assert.Equal(t, []string{a}, g.State().Tournament.Deal.Accepted)
+	assert.Equal(t, ErrCodeNotAllowed, g.AcceptDeal().(*GameError).Code)
+
+	assert.Nil(t, g.handleAcceptDeal(b, MessageAcceptDeal{Method: DealICM, Payouts: payouts}))
+	assert.Nil(t, g.handleDeclineDeal(c))
+	assert.False(t, g.isNegotiating())
+	assert.Nil(t, g.State().Tournament.Deal)
+
+	// A deal that nobody accepts in time is withdrawn.
+	assert.Nil(t, g.ProposeDeal(DealICM))
+	<-broadcastch
+	g.expireDeal(g.tournament.deal)
+	assert.False(t, g.isNegotiating())
+
+	// c proposes a chip EV deal that every player accepts, b accepts it
+	// before the proposal reaches us.
+	assert.NotNil(t, g.handleProposeDeal(c, MessageProposeDeal{Method: DealChipEV, Payouts: payouts}))
+	payouts = []DealPayout{
+		{Addr: a, Stack: 2500, Prize: 167},
+		{Addr: b, Stack: 1500, Prize: 100},
+		{Addr: c, Stack: 500, Prize: 33},
+	}
+	assert.Nil(t, g.handleAcceptDeal(b, MessageAcceptDeal{Method: DealChipEV, Payouts: payouts}))
+	assert.False(t, g.isNegotiating())
+	assert.Nil(t, g.handleProposeDeal(c, MessageProposeDeal{Method: DealChipEV, Payouts: payouts}))
+	assert.Equal(t, []string{b, c}, g.State().Tournament.Deal.Accepted)
+	assert.False(t, g.tournamentFinished())
+	assert.Nil(t, g.AcceptDeal())
+	<-broadcastch
+
+	assert.Equal(t, []TournamentResult{
+		{Addr: a, Place: 1, Prize: 167, Deal: true},
+		{Addr: b, Place: 2, Prize: 100, Deal: true},
+		{Addr: c, Place: 3, Prize: 33, Deal: true},
+	}, g.TournamentResults())
+
+	state := g.State().Tournament
+	assert.True(t, state.Finished)
+	assert.Equal(t, 3, state.PlayersLeft)
+	assert.Equal(t, 3, len(state.Deal.Accepted))
+	assert.False(t, g.isNegotiating())
+	assert.Equal(t, ErrCodeNotAllowed, g.ProposeDeal(DealICM).(*GameError).Code)
+
+	balances := map[string]LedgerBalance{}
+	for _, balance := range g.Ledger().Balances {
+		balances[balance.Addr] = balance
+	}
+	assert.Equal(t, 67, balances[a].Net)
+	assert.Equal(t, -67, balances[c].Net)
+}
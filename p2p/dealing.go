@@ -0,0 +1,457 @@
+package p2p
+
+import (
+	"fmt"
+
+	"github.com/anthdm/ggpoker/deck"
+	"github.com/sirupsen/logrus"
+)
+
+// cardLayout maps the positions of the encrypted deck to the players and the
+// board. Every player builds the exact same layout from the same events, so
+// nobody has to tell the others which cards were dealt to whom.
+type cardLayout struct {
+	// next is the position of the next card that will be dealt.
+	next int
+	// hole holds the deck positions of the private cards of each player.
+	hole map[string][]int
+	// up holds the deck positions of the face up cards of each player.
+	up map[string][]int
+	// board holds the deck positions of the community cards.
+	board []int
+	// runs holds the deck positions of the extra boards when the players run
+	// it more than once. The first run is always the board itself.
+	runs [][]int
+}
+
+func newCardLayout() *cardLayout {
+	return &cardLayout{
+		hole:  make(map[string][]int),
+		up:    make(map[string][]int),
+		board: []int{},
+	}
+}
+
+// dealHoleCards deals n cards to each player one at a time, starting with
+// the first player in the list, just like a real dealer would.
+func (l *cardLayout) dealHoleCards(players []string, n int) {
+	for i := 0; i < n; i++ {
+		for _, addr := range players {
+			l.hole[addr] = append(l.hole[addr], l.next)
+			l.next++
+		}
+	}
+}
+
+// dealUpCards deals n face up cards to each player and returns their
+// positions in the deck.
+func (l *cardLayout) dealUpCards(players []string, n int) []int {
+	positions := []int{}
+	for i := 0; i < n; i++ {
+		for _, addr := range players {
+			l.up[addr] = append(l.up[addr], l.next)
+			positions = append(positions, l.next)
+			l.next++
+		}
+	}
+	return positions
+}
+
+// dealBoard deals n community cards and returns their positions in the deck.
+func (l *cardLayout) dealBoard(n int) []int {
+	positions := make([]int, n)
+	for i := 0; i < n; i++ {
+		positions[i] = l.next
+		l.board = append(l.board, l.next)
+		l.next++
+	}
+	return positions
+}
+
+// dealRun deals another run of the last n community cards. The cards that
+// were dealt before the players went all-in are shared by every run.
+func (l *cardLayout) dealRun(n int) []int {
+	run := append([]int{}, l.board[:len(l.board)-n]...)
+	positions := make([]int, n)
+	for i := 0; i < n; i++ {
+		positions[i] = l.next
+		run = append(run, l.next)
+		l.next++
+	}
+	l.runs = append(l.runs, run)
+	return positions
+}
+
+func (l *cardLayout) isHoleCardOf(addr string, index int) bool {
+	for _, i := range l.hole[addr] {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
+
+// upCardOwner returns the player the face up card is dealt to. It returns an
+// empty string for community cards.
+func (l *cardLayout) upCardOwner(index int) string {
+	for addr, positions := range l.up {
+		for _, i := range positions {
+			if i == index {
+				return addr
+			}
+		}
+	}
+	return ""
+}
+
+// isPublicCard reports whether the card is a community card or a face up card,
+// which are revealed to every player.
+func (l *cardLayout) isPublicCard(index int) bool {
+	for _, positions := range l.up {
+		for _, i := range positions {
+			if i == index {
+				return true
+			}
+		}
+	}
+	return l.isBoardCard(index)
+}
+
+func (l *cardLayout) isBoardCard(index int) bool {
+	for _, run := range append([][]int{l.board}, l.runs...) {
+		for _, i := range run {
+			if i == index {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dealOrder returns the players on the table in the order they receive their
+// cards, starting with the player after the dealer.
+func (g *GameState) dealOrder() []string {
+	dealer, _ := g.getCurrentDealerAddr()
+	players := g.table.Players()
+
+	start := 0
+	for i, p := range players {
+		if p.addr == dealer {
+			start = i + 1
+		}
+	}
+
+	addrs := make([]string, len(players))
+	for i, p := range players {
+		addrs[i] = p.addr
+	}
+
+	return rotate(addrs, start)
+}
+
+// rotate returns a copy of the list that starts at the given index.
+func rotate(list []string, start int) []string {
+	out := make([]string, len(list))
+	for i := 0; i < len(list); i++ {
+		out[i] = list[(start+i)%len(list)]
+	}
+	return out
+}
+
+// startHand is called by every player once the deck is encrypted and
+// shuffled by all players on the table. The order holds the players the
+// dealer dealt in, and the forced bets are the ones he decided on.
+func (g *GameState) startHand(encDeck [][]byte, order []string, bets ForcedBets) {
+	if len(order) < 2 {
+		logrus.Errorf("cannot start a hand with %d players", len(order))
+		return
+	}
+
+	g.lock.Lock()
+	g.encDeck = encDeck
+	g.layout = newCardLayout()
+	g.holeCards = make(map[int]deck.Card)
+	g.publicCards = make(map[int]deck.Card)
+	g.shownHands = make(map[string][]deck.Card)
+	g.handFinished = false
+	g.awaitingUpCards = false
+	g.drawing = false
+	g.runProposals = make(map[string]int)
+	g.runout = false
+	g.runsDealt = false
+	g.lock.Unlock()
+
+	g.startTournament(order)
+	g.withdrawDeal()
+	g.trackMissedBlinds(order)
+	for _, addr := range order {
+		// A player that waited for the big blind does not owe the blinds
+		// he missed once he is dealt in.
+		g.table.updatePlayer(addr, func(p *Player) {
+			if p.waitForBB {
+				p.waitForBB = false
+				p.missedBlinds = false
+			}
+		})
+	}
+
+	g.betting.reset(order)
+	g.publish(EventStreetAdvanced, StreetEvent{Status: GameStatus(g.currentStatus.Get()).String()})
+	g.postForcedBets(order, bets)
+}
+
+// dealStreet deals the cards of the given street to the players that are
+// still in the hand.
+func (g *GameState) dealStreet(st street) {
+	players := g.betting.activePlayers()
+
+	g.lock.Lock()
+	own, public := g.dealStreetLocked(st, players)
+	g.lock.Unlock()
+
+	g.revealCards(own, public)
+}
+
+// dealStreetLocked updates the layout with the cards of the given street and
+// returns the positions of our own new hole cards and of the public cards.
+func (g *GameState) dealStreetLocked(st street, players []string) ([]int, []int) {
+	before := len(g.layout.hole[g.listenAddr])
+	g.layout.dealHoleCards(players, st.down)
+	own := g.layout.hole[g.listenAddr][before:]
+
+	public := g.layout.dealUpCards(players, st.up)
+	public = append(public, g.layout.dealBoard(st.board)...)
+
+	return own, public
+}
+
+// revealCards starts the decryption of our own hole cards and, if we are the
+// dealer, of the public cards. Every player needs to keep the layout in sync,
+// but only the dealer starts the decryption of the public cards.
+func (g *GameState) revealCards(own []int, public []int) {
+	for _, index := range own {
+		g.requestDecryption(index, g.listenAddr)
+	}
+
+	if dealer, isDealer := g.getCurrentDealerAddr(); isDealer {
+		for _, index := range public {
+			g.requestDecryption(index, dealer)
+		}
+	}
+}
+
+// requestDecryption sends the encrypted card around the table. Every player
+// removes his own layer of encryption and the owner of the card, being the
+// last one in the circle, can finally read it.
+func (g *GameState) requestDecryption(index int, owner string) {
+	next, err := g.table.GetPlayerAfter(g.listenAddr)
+	if err != nil {
+		logrus.Errorf("cannot request decryption: %s", err)
+		return
+	}
+
+	g.lock.RLock()
+	encCard := g.encDeck[index]
+	g.lock.RUnlock()
+
+	g.sendToPlayers(MessageDecryptCard{
+		Index: index,
+		Owner: owner,
+		Card:  encCard,
+	}, next.addr)
+}
+
+func (g *GameState) canReveal(owner string, index int) bool {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	if g.layout == nil {
+		return false
+	}
+	if g.layout.isHoleCardOf(owner, index) {
+		return true
+	}
+
+	dealer, _ := g.getCurrentDealerAddr()
+	return owner == dealer && g.layout.isPublicCard(index)
+}
+
+func (g *GameState) handleDecryptCard(from string, msg MessageDecryptCard) error {
+	// Make sure we are not helping a player to peek at cards that are not his.
+	if !g.canReveal(msg.Owner, msg.Index) {
+		return fmt.Errorf("player (%s) is not allowed to see card (%d)", msg.Owner, msg.Index)
+	}
+
+	g.lock.RLock()
+	key := g.key
+	g.lock.RUnlock()
+
+	if msg.Owner != g.listenAddr {
+		encCard, err := deck.Decrypt(key, msg.Card)
+		if err != nil {
+			return err
+		}
+
+		next, err := g.table.GetPlayerAfter(g.listenAddr)
+		if err != nil {
+			return err
+		}
+
+		g.sendToPlayers(MessageDecryptCard{
+			Index: msg.Index,
+			Owner: msg.Owner,
+			Card:  encCard,
+		}, next.addr)
+		return nil
+	}
+
+	card, err := deck.DecryptCard(key, msg.Card)
+	if err != nil {
+		return err
+	}
+
+	g.lock.Lock()
+	isPublic := g.layout.isPublicCard(msg.Index)
+	if isPublic {
+		g.publicCards[msg.Index] = card
+	} else {
+		g.holeCards[msg.Index] = card
+	}
+	g.lock.Unlock()
+
+	if isPublic {
+		g.sendToPlayers(MessagePublicCard{Index: msg.Index, Card: card}, g.getOtherPlayers()...)
+		g.onPublicCard(msg.Index, card)
+		return nil
+	}
+
+	g.publishCards(g.listenAddr, false, card)
+	g.maybeShowHand()
+
+	return nil
+}
+
+// NOTE: the card is trusted, only the dealer decrypts the public cards.
+func (g *GameState) handlePublicCard(from string, msg MessagePublicCard) error {
+	if !g.isFromCurrentDealer(from) {
+		return fmt.Errorf("received public card from player (%s) that is not the dealer", from)
+	}
+
+	g.lock.Lock()
+	if g.layout == nil || !g.layout.isPublicCard(msg.Index) {
+		g.lock.Unlock()
+		return fmt.Errorf("card (%d) is not a public card", msg.Index)
+	}
+	g.publicCards[msg.Index] = msg.Card
+	g.lock.Unlock()
+
+	g.onPublicCard(msg.Index, msg.Card)
+
+	return nil
+}
+
+// onPublicCard is called every time a public card is revealed.
+func (g *GameState) onPublicCard(index int, card deck.Card) {
+	g.lock.RLock()
+	owner := g.layout.upCardOwner(index)
+	g.lock.RUnlock()
+
+	g.publishCards(owner, true, card)
+
+	g.maybeStartStudBetting()
+	g.maybeFinishHand()
+}
+
+// boardsLocked returns the cards of every run of the board. It returns false
+// when not all the cards are revealed yet.
+func (g *GameState) boardsLocked() ([][]deck.Card, bool) {
+	boards := [][]deck.Card{}
+	for _, run := range append([][]int{g.layout.board}, g.layout.runs...) {
+		board := []deck.Card{}
+		for _, index := range run {
+			card, ok := g.publicCards[index]
+			if !ok {
+				return nil, false
+			}
+			board = append(board, card)
+		}
+		boards = append(boards, board)
+	}
+
+	return boards, true
+}
+
+// upCardsLocked returns the face up cards of the player. It returns false when
+// not all of them are revealed yet.
+func (g *GameState) upCardsLocked(addr string) ([]deck.Card, bool) {
+	cards := []deck.Card{}
+	if g.layout == nil {
+		return cards, true
+	}
+	for _, index := range g.layout.up[addr] {
+		card, ok := g.publicCards[index]
+		if !ok {
+			return cards, false
+		}
+		cards = append(cards, card)
+	}
+
+	return cards, true
+}
+
+// Board returns the community cards that are revealed so far.
+func (g *GameState) Board() []deck.Card {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	cards := []deck.Card{}
+	if g.layout == nil {
+		return cards
+	}
+	for _, index := range g.layout.board {
+		if card, ok := g.publicCards[index]; ok {
+			cards = append(cards, card)
+		}
+	}
+
+	return cards
+}
+
+// UpCards returns the face up cards of the given player that are revealed so far.
+func (g *GameState) UpCards(addr string) []deck.Card {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	cards, _ := g.upCardsLocked(addr)
+	return cards
+}
+
+// HoleCards returns our own private cards of the current hand that are
+// decrypted so far.
+func (g *GameState) HoleCards() []deck.Card {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	cards, _ := g.holeCardsLocked()
+	return cards
+}
+
+// holeCardsLocked returns our own private cards in the order they are dealt.
+// It returns false when not all of them are decrypted yet.
+func (g *GameState) holeCardsLocked() ([]deck.Card, bool) {
+	cards := []deck.Card{}
+	if g.layout == nil {
+		return cards, true
+	}
+	complete := true
+	for _, index := range g.layout.hole[g.listenAddr] {
+		card, ok := g.holeCards[index]
+		if !ok {
+			complete = false
+			continue
+		}
+		cards = append(cards, card)
+	}
+
+	return cards, complete
+}
@@ -0,0 +1,233 @@
+package p2p
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// peerExchangeInterval is how often we gossip the peers we are connected
+	// to, so every player learns about the whole table.
+	peerExchangeInterval = 5 * time.Second
+	// dialInterval is how often we try to connect to the known players we are
+	// not connected to yet.
+	dialInterval = time.Second
+	// maxDialBackoff is the longest we wait before dialing a player again that
+	// could not be reached.
+	maxDialBackoff = 30 * time.Second
+	// handshakeTimeout is the time a new connection has to complete the
+	// handshake.
+	handshakeTimeout = 5 * time.Second
+)
+
+// knownPeer is a player we know the address of, we are not necessarily
+// connected to it.
+type knownPeer struct {
+	failures int
+	nextDial time.Time
+	dialing  bool
+}
+
+// peerBook holds the addresses of every player at a table we know about,
+// without duplicates and without our own address.
+type peerBook struct {
+	lock  sync.Mutex
+	self  string
+	peers map[string]*knownPeer
+}
+
+func newPeerBook(self string) *peerBook {
+	return &peerBook{
+		self:  self,
+		peers: make(map[string]*knownPeer),
+	}
+}
+
+// add returns the number of addresses we did not know yet.
+func (b *peerBook) add(addrs ...string) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	added := 0
+	for _, addr := range addrs {
+		addr, err := normalizeAddr(addr)
+		if err != nil || addr == b.self {
+			continue
+		}
+		if _, ok := b.peers[addr]; ok {
+			continue
+		}
+		b.peers[addr] = &knownPeer{}
+		added++
+	}
+	return added
+}
+
+func (b *peerBook) list() []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	addrs := make([]string, 0, len(b.peers))
+	for addr := range b.peers {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// due returns the addresses that need to be dialed now, they are marked as
+// dialing until dialed is called.
+func (b *peerBook) due(now time.Time, connected func(string) bool) []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	addrs := []string{}
+	for addr, peer := range b.peers {
+		if peer.dialing || now.Before(peer.nextDial) || connected(addr) {
+			continue
+		}
+		peer.dialing = true
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// rename moves the player we know at the address from to the address to,
+// the one it advertises. A player we already know under both addresses is
+// kept once.
+func (b *peerBook) rename(from, to string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	peer, ok := b.peers[from]
+	if !ok {
+		return
+	}
+	delete(b.peers, from)
+	if _, ok := b.peers[to]; ok || to == b.self {
+		return
+	}
+	// The dial of the old address is done, it succeeded.
+	peer.dialing = false
+	peer.failures = 0
+	b.peers[to] = peer
+}
+
+// dialed records the result of dialing the address. Every failure doubles the
+// time until the next try.
+func (b *peerBook) dialed(addr string, err error, now time.Time) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	peer, ok := b.peers[addr]
+	if !ok {
+		return
+	}
+	peer.dialing = false
+
+	if err == nil {
+		// Give the handshake the time to complete before we try again.
+		peer.failures = 0
+		peer.nextDial = now.Add(handshakeTimeout)
+		return
+	}
+
+	backoff := dialInterval << peer.failures
+	if backoff > maxDialBackoff || backoff <= 0 {
+		backoff = maxDialBackoff
+	} else {
+		peer.failures++
+	}
+	peer.nextDial = now.Add(backoff)
+}
+
+// discoveryLoop keeps dialing the players we know about until we are
+// connected to every one of them, and gossips our peers to the table.
+func (s *Server) discoveryLoop() {
+	dialTicker := time.NewTicker(dialInterval)
+	gossipTicker := time.NewTicker(peerExchangeInterval)
+	defer dialTicker.Stop()
+	defer gossipTicker.Stop()
+
+	s.dialKnownPeers()
+
+	meshComplete := false
+	for {
+		select {
+		case <-dialTicker.C:
+			s.dialKnownPeers()
+
+			if complete := s.MeshComplete(); complete != meshComplete {
+				meshComplete = complete
+				if complete {
+					logrus.WithFields(logrus.Fields{
+						"we":    s.AdvertiseAddr,
+						"peers": s.Peers(),
+					}).Info("connected to every known player")
+				}
+			}
+		case <-gossipTicker.C:
+			s.gossipPeers()
+		}
+	}
+}
+
+// dialKnownPeers connects to the known players of every table we are not
+// connected to, as long as we are below our connection limit.
+func (s *Server) dialKnownPeers() {
+	for _, t := range s.tableList() {
+		book := t.book
+		for _, addr := range book.due(time.Now(), s.isInPeerList) {
+			if s.peerCount() >= s.maxPeers() {
+				book.dialed(addr, errMaxPeers, time.Now())
+				continue
+			}
+
+			go func(addr string) {
+				err := s.connect(addr)
+				if err != nil {
+					logrus.WithFields(logrus.Fields{
+						"we":   s.AdvertiseAddr,
+						"addr": addr,
+						"err":  err,
+					}).Debug("failed to dial player")
+				}
+				book.dialed(addr, err, time.Now())
+			}(addr)
+		}
+	}
+}
+
+// gossipPeers sends the players of every table we are connected to, to
+// every one of them.
+func (s *Server) gossipPeers() {
+	for _, t := range s.tableList() {
+		peers := t.peers()
+		if len(peers) == 0 {
+			continue
+		}
+
+		s.broadcastch <- BroadcastTo{
+			To:      peers,
+			Table:   t.TableID,
+			Payload: MessagePeerList{Peers: peers},
+		}
+	}
+}
+
+// MeshComplete reports whether we are connected to every player we know
+// about.
+func (s *Server) MeshComplete() bool {
+	for _, t := range s.tableList() {
+		for _, addr := range t.book.list() {
+			if !s.isInPeerList(addr) {
+				return false
+			}
+		}
+	}
+	return true
+}
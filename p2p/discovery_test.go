@@ -0,0 +1,114 @@
+package p2p
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeerBook(t *testing.T) {
+	b := newPeerBook(":3000")
+	assert.Equal(t, 2, b.add(":4000", ":3000", ":5000", ":4000", ""))
+	assert.Equal(t, 0, b.add(":5000"))
+	assert.Equal(t, []string{":4000", ":5000"}, b.list())
+
+	now := time.Now()
+	connected := func(addr string) bool { return addr == ":5000" }
+	assert.Equal(t, []string{":4000"}, b.due(now, connected))
+	// A player is not dialed twice at the same time.
+	assert.Empty(t, b.due(now, connected))
+
+	// Every failure doubles the backoff.
+	b.dialed(":4000", errors.New("refused"), now)
+	assert.Empty(t, b.due(now.Add(dialInterval/2), connected))
+	assert.Equal(t, []string{":4000"}, b.due(now.Add(dialInterval), connected))
+	b.dialed(":4000", errors.New("refused"), now)
+	assert.Empty(t, b.due(now.Add(dialInterval), connected))
+	assert.Equal(t, []string{":4000"}, b.due(now.Add(2*dialInterval), connected))
+
+	for i := 0; i < 10; i++ {
+		b.dialed(":4000", errors.New("refused"), now)
+		b.due(now.Add(maxDialBackoff), connected)
+	}
+	b.dialed(":4000", errors.New("refused"), now)
+	assert.Equal(t, []string{":4000"}, b.due(now.Add(maxDialBackoff), connected))
+
+	// A player is known by the address it advertises, not the one we dialed.
+	b.add("127.0.0.1:6000", "127.0.0.1:7000")
+	b.rename("127.0.0.1:6000", ":6000")
+	b.rename("127.0.0.1:7000", ":5000")
+	b.rename("127.0.0.1:8000", ":8000")
+	assert.Equal(t, []string{":4000", ":5000", ":6000"}, b.list())
+}
+
+func TestKeepNewConn(t *testing.T) {
+	a := &Server{ServerConfig: ServerConfig{AdvertiseAddr: ":3000"}}
+	b := &Server{ServerConfig: ServerConfig{AdvertiseAddr: ":4000"}}
+
+	// The connection that :3000 dialed is kept by both players.
+	dialedByA := &Peer{listenAddr: ":4000", outbound: true}
+	dialedByB := &Peer{listenAddr: ":4000", outbound: false}
+	assert.True(t, a.keepNewConn(dialedByB, dialedByA))
+	assert.False(t, a.keepNewConn(dialedByA, dialedByB))
+
+	dialedByA = &Peer{listenAddr: ":3000", outbound: false}
+	dialedByB = &Peer{listenAddr: ":3000", outbound: true}
+	assert.True(t, b.keepNewConn(dialedByB, dialedByA))
+	assert.False(t, b.keepNewConn(dialedByA, dialedByB))
+
+	// A reconnect replaces the old connection.
+	assert.True(t, a.keepNewConn(&Peer{outbound: true}, &Peer{outbound: true}))
+}
+
+func TestMeshDiscovery(t *testing.T) {
+	addrs := []string{":23100", "127.0.0.1:23102", "localhost:23104"}
+	servers := make([]*Server, len(addrs))
+	for i, addr := range addrs {
+		cfg := ServerConfig{
+			ListenAddr:    addr,
+			APIListenAddr: ":0",
+		}
+		// Every player only knows the one before it.
+		if i > 0 {
+			cfg.BootstrapPeers = []string{addrs[i-1]}
+		}
+		servers[i] = NewServer(cfg)
+		go servers[i].Start()
+	}
+
+	for _, s := range servers {
+		s := s
+		assert.Eventually(t, func() bool {
+			return len(s.Peers()) == len(addrs)-1 && s.MeshComplete()
+		}, 10*time.Second, 50*time.Millisecond, s.ListenAddr)
+	}
+}
+
+func TestBootstrapAdvertisedAddr(t *testing.T) {
+	a := NewServer(ServerConfig{ListenAddr: ":23140", APIListenAddr: ":0"})
+	go a.Start()
+	// We dial the player at another address than the one it advertises.
+	b := NewServer(ServerConfig{
+		ListenAddr:     ":23142",
+		APIListenAddr:  ":0",
+		BootstrapPeers: []string{"127.0.0.1:23140"},
+	})
+	go b.Start()
+
+	assert.Eventually(t, func() bool {
+		return b.isInPeerList(":23140") && b.MeshComplete() && a.MeshComplete()
+	}, 10*time.Second, 50*time.Millisecond)
+
+	b.peerLock.RLock()
+	conn := b.peers[":23140"]
+	b.peerLock.RUnlock()
+
+	// The player is not dialed again, the connection is kept.
+	time.Sleep(handshakeTimeout + 2*dialInterval)
+	b.peerLock.RLock()
+	defer b.peerLock.RUnlock()
+	assert.Same(t, conn, b.peers[":23140"])
+	assert.Equal(t, 1, len(b.peers))
+}
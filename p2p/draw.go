@@ -0,0 +1,192 @@
+package p2p
+
+import (
+	"fmt"
+
+	"github.com/anthdm/ggpoker/deck"
+	"github.com/sirupsen/logrus"
+)
+
+// draw replaces the discarded cards of the player with new cards from the top
+// of the deck and returns the positions of the replacement cards.
+func (l *cardLayout) draw(addr string, discards []int) []int {
+	hole := []int{}
+	for _, index := range l.hole[addr] {
+		if !containsInt(discards, index) {
+			hole = append(hole, index)
+		}
+	}
+
+	positions := []int{}
+	for range discards {
+		hole = append(hole, l.next)
+		positions = append(positions, l.next)
+		l.next++
+	}
+	l.hole[addr] = hole
+
+	return positions
+}
+
+// validateDraw checks that the player only discards his own cards and that
+// there are enough cards left in the deck to replace them.
+func (l *cardLayout) validateDraw(addr string, discards []int) error {
+	for i, index := range discards {
+		if !l.isHoleCardOf(addr, index) {
+			return newGameError(ErrCodeIllegalAction, "card (%d) is not in the hand of player (%s)", index, addr)
+		}
+		if containsInt(discards[:i], index) {
+			return newGameError(ErrCodeIllegalAction, "card (%d) is discarded twice", index)
+		}
+	}
+	// NOTE: the discards cannot be reshuffled into the deck, because every
+	// player would need to give up the key of his own layer of encryption.
+	// The seats of a draw game are capped so every player can replace his
+	// whole hand once, after that a player can only draw the cards left.
+	if left := len(deck.New()) - l.next; len(discards) > left {
+		return newGameError(ErrCodeIllegalAction, "there are only %d cards left in the deck", left)
+	}
+	return nil
+}
+
+func containsInt(list []int, v int) bool {
+	for _, i := range list {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
+
+// startDraw gives the turn to the first player to draw. Every player still in
+// the hand draws, including the players that are all-in.
+func (g *GameState) startDraw() {
+	g.lock.Lock()
+	g.drawing = true
+	g.lock.Unlock()
+
+	g.setDrawTurn(g.dealOrder())
+}
+
+// setDrawTurn gives the turn to the first player in the given order that is
+// still in the hand. When everybody has drawn the betting starts.
+func (g *GameState) setDrawTurn(order []string) {
+	for _, addr := range order {
+		if !g.betting.isActive(addr) {
+			continue
+		}
+		player, err := g.table.GetPlayer(addr)
+		if err != nil {
+			continue
+		}
+		g.setCurrentTurn(player.tablePos)
+		return
+	}
+
+	g.finishDraw()
+}
+
+// finishDraw starts the betting once every player has drawn. When there is
+// nobody left to bet the hand moves on to the next draw right away.
+func (g *GameState) finishDraw() {
+	g.lock.Lock()
+	g.drawing = false
+	g.lock.Unlock()
+
+	if g.betting.isAllIn() {
+		g.advanceToNexRound()
+		return
+	}
+
+	g.setTurn(g.dealOrder())
+}
+
+func (g *GameState) isDrawing() bool {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	return g.drawing
+}
+
+// Draw discards the given cards from our hand and replaces them with new
+// cards from the deck. The cards are given by their index in HoleCards, an
+// empty list means we stand pat.
+func (g *GameState) Draw(cards []int) error {
+	g.actionLock.Lock()
+	defer g.actionLock.Unlock()
+
+	if !g.isDrawing() {
+		return newGameError(ErrCodeWrongGameStatus, "cannot draw in game status (%s)", GameStatus(g.currentStatus.Get()))
+	}
+	if !g.canTakeAction(g.listenAddr) {
+		return newGameError(ErrCodeNotYourTurn, "drawing before its my turn %s", g.listenAddr)
+	}
+
+	g.lock.RLock()
+	hole := g.layout.hole[g.listenAddr]
+	discards := []int{}
+	for _, i := range cards {
+		if i < 0 || i >= len(hole) {
+			g.lock.RUnlock()
+			return newGameError(ErrCodeIllegalAction, "invalid card (%d) to discard", i)
+		}
+		discards = append(discards, hole[i])
+	}
+	g.lock.RUnlock()
+
+	msg := MessageDraw{
+		CurrentGameStatus: GameStatus(g.currentStatus.Get()),
+		Discards:          discards,
+	}
+	return g.applyDraw(g.listenAddr, msg)
+}
+
+func (g *GameState) handleDraw(from string, msg MessageDraw) error {
+	g.actionLock.Lock()
+	defer g.actionLock.Unlock()
+
+	if !g.isDrawing() || !g.canTakeAction(from) {
+		return fmt.Errorf("player (%s) drawing before his turn", from)
+	}
+	if msg.CurrentGameStatus != GameStatus(g.currentStatus.Get()) {
+		return fmt.Errorf("player (%s) has not the correct game status (%s)", from, msg.CurrentGameStatus)
+	}
+
+	return g.applyDraw(from, msg)
+}
+
+// applyDraw updates the layout with the draw of the given player and passes
+// the turn to the next player. The replacement cards are only revealed to the
+// player that draws them.
+func (g *GameState) applyDraw(addr string, msg MessageDraw) error {
+	g.lock.Lock()
+	if err := g.layout.validateDraw(addr, msg.Discards); err != nil {
+		g.lock.Unlock()
+		return err
+	}
+	positions := g.layout.draw(addr, msg.Discards)
+	g.lock.Unlock()
+
+	if addr == g.listenAddr {
+		g.sendToPlayers(msg, g.getOtherPlayers()...)
+		for _, index := range positions {
+			g.requestDecryption(index, g.listenAddr)
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"we":    g.listenAddr,
+		"from":  addr,
+		"cards": len(msg.Discards),
+	}).Info("player draws")
+
+	order := g.dealOrder()
+	for i, p := range order {
+		if p == addr {
+			g.setDrawTurn(order[i+1:])
+			break
+		}
+	}
+
+	return nil
+}
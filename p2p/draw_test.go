@@ -0,0 +1,66 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCardLayoutDraw(t *testing.T) {
+	l := newCardLayout()
+	l.dealHoleCards([]string{"a", "b"}, 5)
+	assert.Equal(t, []int{0, 2, 4, 6, 8}, l.hole["a"])
+
+	assert.NotNil(t, l.validateDraw("a", []int{1}))
+	assert.NotNil(t, l.validateDraw("a", []int{2, 2}))
+	assert.Nil(t, l.validateDraw("a", []int{2, 6}))
+
+	// The replacement cards are the next cards of the deck.
+	assert.Equal(t, []int{10, 11}, l.draw("a", []int{2, 6}))
+	assert.Equal(t, []int{0, 4, 8, 10, 11}, l.hole["a"])
+	assert.False(t, l.isHoleCardOf("a", 2))
+
+	l.next = 51
+	assert.NotNil(t, l.validateDraw("b", []int{1, 3}))
+}
+
+func TestDrawGameSeats(t *testing.T) {
+	newDrawGame := func(cfg ServerConfig) *GameState {
+		cfg.AdvertiseAddr = ":3000"
+		return NewGame(cfg.withDefaults(), make(chan BroadcastTo, 10))
+	}
+
+	// A sixth player can not sit down at a 2-7 triple draw table.
+	g := newDrawGame(ServerConfig{GameVariant: DeuceToSevenTripleDraw, MaxPlayers: 9})
+	for seat, addr := range []string{":4000", ":5000", ":6000", ":7000", ":8000"} {
+		g.AddPlayer(addr)
+		assert.Nil(t, g.handleTakeSeat(addr, MessageTakeSeat{Seat: seat}))
+	}
+	assert.Equal(t, ErrCodeInvalidRequest, g.TakeSeat(5).(*GameError).Code)
+	_, err := g.takeSeat()
+	assert.NotNil(t, err)
+
+	// A player with more seats at the table does not play the same game.
+	cfg := ServerConfig{GameVariant: DeuceToSevenTripleDraw}.withDefaults()
+	table := &tableServer{ServerConfig: cfg, gameState: g}
+	hs := table.handshake()
+	assert.Nil(t, table.check(hs))
+	hs.MaxPlayers = 9
+	assert.NotNil(t, table.check(hs))
+
+	// Every player at a full table can replace his whole hand once, after
+	// that only the cards that are left can be drawn.
+	l := newCardLayout()
+	players := []string{"a", "b", "c", "d", "e"}
+	l.dealHoleCards(players, 5)
+	for _, addr := range players {
+		discards := append([]int{}, l.hole[addr]...)
+		assert.Nil(t, l.validateDraw(addr, discards))
+		l.draw(addr, discards)
+	}
+	assert.NotNil(t, l.validateDraw("a", l.hole["a"][:3]))
+
+	// A mixed game is played with the seats of its draw game.
+	assert.NotNil(t, newDrawGame(ServerConfig{Rotation: EightGame(8)}).TakeSeat(5))
+	assert.Nil(t, newDrawGame(ServerConfig{Rotation: HORSE(8), MaxPlayers: 9}).TakeSeat(8))
+}
@@ -0,0 +1,34 @@
+package p2p
+
+import "fmt"
+
+// ErrorCode tells the kind of a GameError apart, so API clients do not have
+// to match on the error message.
+type ErrorCode string
+
+const (
+	ErrCodeInvalidRequest  ErrorCode = "INVALID_REQUEST"
+	ErrCodeNotYourTurn     ErrorCode = "NOT_YOUR_TURN"
+	ErrCodeWrongGameStatus ErrorCode = "WRONG_GAME_STATUS"
+	ErrCodeIllegalAction   ErrorCode = "ILLEGAL_ACTION"
+	ErrCodeIllegalAmount   ErrorCode = "ILLEGAL_AMOUNT"
+	ErrCodeNotAllowed      ErrorCode = "NOT_ALLOWED"
+)
+
+// GameError is an error caused by an action that is not possible in the
+// current state of the game.
+type GameError struct {
+	Code ErrorCode
+	Msg  string
+}
+
+func newGameError(code ErrorCode, format string, args ...any) *GameError {
+	return &GameError{
+		Code: code,
+		Msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+func (e *GameError) Error() string {
+	return e.Msg
+}
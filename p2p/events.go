@@ -0,0 +1,166 @@
+package p2p
+
+import (
+	"sync"
+
+	"github.com/anthdm/ggpoker/deck"
+)
+
+type EventType string
+
+const (
+	// EventSnapshot holds the full State, it is sent to a client when it
+	// connects.
+	EventSnapshot       EventType = "SNAPSHOT"
+	EventPlayerJoined   EventType = "PLAYER_JOINED"
+	EventPlayerLeft     EventType = "PLAYER_LEFT"
+	EventPlayerReady    EventType = "PLAYER_READY"
+	EventPlayerSatOut   EventType = "PLAYER_SAT_OUT"
+	EventPlayerSatIn    EventType = "PLAYER_SAT_IN"
+	EventSeatReserved   EventType = "SEAT_RESERVED"
+	EventSeatReleased   EventType = "SEAT_RELEASED"
+	EventCardsDealt     EventType = "CARDS_DEALT"
+	EventActionTaken    EventType = "ACTION_TAKEN"
+	EventStreetAdvanced EventType = "STREET_ADVANCED"
+	EventShowdown       EventType = "SHOWDOWN"
+	EventPotAwarded     EventType = "POT_AWARDED"
+	// EventLedgerEntry holds the LedgerEntry of a buy-in, rebuy, top up or
+	// cash out.
+	EventLedgerEntry EventType = "LEDGER_ENTRY"
+	// EventPlayerEliminated holds the TournamentResult of a player that lost
+	// all his chips in a tournament.
+	EventPlayerEliminated   EventType = "PLAYER_ELIMINATED"
+	EventTournamentFinished EventType = "TOURNAMENT_FINISHED"
+	// EventPlayerMoved holds the PlayerMove of a player that moves to
+	// another table of a multi-table tournament.
+	EventPlayerMoved EventType = "PLAYER_MOVED"
+	// The events of the Coordinator of a multi-table tournament.
+	EventTableBroken EventType = "TABLE_BROKEN"
+	EventFinalTable  EventType = "FINAL_TABLE"
+	EventHandForHand EventType = "HAND_FOR_HAND"
+	// EventDealProposed holds the Deal a player proposes in a tournament,
+	// and EventDealWithdrawn the Deal that was not accepted in time. The
+	// other deal events hold the PlayerEvent of the player that accepted or
+	// declined it.
+	EventDealProposed  EventType = "DEAL_PROPOSED"
+	EventDealAccepted  EventType = "DEAL_ACCEPTED"
+	EventDealDeclined  EventType = "DEAL_DECLINED"
+	EventDealWithdrawn EventType = "DEAL_WITHDRAWN"
+)
+
+// Event is something that happened at the table. Events are pushed to UI
+// clients, so just like the State they never hold hidden cards of other
+// players.
+type Event struct {
+	Type EventType `json:"type"`
+	Data any       `json:"data,omitempty"`
+}
+
+type PlayerEvent struct {
+	Addr string `json:"addr"`
+}
+
+// SeatEvent is published when a seat is reserved for a player, or when the
+// player loses his reservation or seat.
+type SeatEvent struct {
+	Addr string `json:"addr"`
+	Seat int    `json:"seat"`
+}
+
+// CardsDealtEvent is published when a card is revealed to us. Owner is empty
+// for community cards.
+type CardsDealtEvent struct {
+	Owner string `json:"owner,omitempty"`
+	// Public reports whether every player at the table can see the cards.
+	Public bool        `json:"public"`
+	Cards  []CardState `json:"cards"`
+}
+
+type ActionEvent struct {
+	Addr   string `json:"addr"`
+	Action string `json:"action"`
+	Value  int    `json:"value,omitempty"`
+}
+
+type StreetEvent struct {
+	Status string `json:"status"`
+}
+
+type ShowdownEvent struct {
+	Hands map[string][]CardState `json:"hands"`
+}
+
+type PotAwardedEvent struct {
+	Run     int      `json:"run"`
+	Pot     int      `json:"pot"`
+	Half    string   `json:"half"`
+	Amount  int      `json:"amount"`
+	Winners []string `json:"winners"`
+}
+
+// subscriberBuffer is the number of events a subscriber can fall behind
+// before events are dropped for it.
+const subscriberBuffer = 64
+
+// eventBus fans out the events of the game to every subscriber.
+type eventBus struct {
+	lock        sync.RWMutex
+	subscribers map[chan Event]struct{}
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// subscribe returns a channel that receives every published event and a
+// function to stop receiving them.
+func (b *eventBus) subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.lock.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.lock.Unlock()
+
+	return ch, func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// publish never blocks the game, a subscriber that cannot keep up misses
+// the event.
+func (b *eventBus) publish(e Event) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns the events of the game as they happen.
+func (g *GameState) Subscribe() (<-chan Event, func()) {
+	return g.events.subscribe()
+}
+
+func (g *GameState) publish(t EventType, data any) {
+	g.events.publish(Event{Type: t, Data: data})
+}
+
+func (g *GameState) publishCards(owner string, public bool, cards ...deck.Card) {
+	g.publish(EventCardsDealt, CardsDealtEvent{
+		Owner:  owner,
+		Public: public,
+		Cards:  newCardStates(cards),
+	})
+}
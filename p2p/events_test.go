@@ -0,0 +1,26 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBus(t *testing.T) {
+	b := newEventBus()
+	events, unsubscribe := b.subscribe()
+
+	b.publish(Event{Type: EventPlayerReady, Data: PlayerEvent{Addr: ":3000"}})
+	assert.Equal(t, Event{Type: EventPlayerReady, Data: PlayerEvent{Addr: ":3000"}}, <-events)
+
+	// A subscriber that falls behind misses events instead of blocking the game.
+	for i := 0; i < subscriberBuffer+10; i++ {
+		b.publish(Event{Type: EventActionTaken})
+	}
+	assert.Equal(t, subscriberBuffer, len(events))
+
+	unsubscribe()
+	unsubscribe()
+	b.publish(Event{Type: EventActionTaken})
+	assert.Equal(t, 0, len(b.subscribers))
+}
@@ -0,0 +1,254 @@
+package p2p
+
+import (
+	"fmt"
+	"time"
+)
+
+// TableOptions holds the forced bets that are played at the table on top of
+// the blinds.
+type TableOptions struct {
+	// Ante is posted by every player that is dealt in. Zero means no ante.
+	Ante int
+	// Straddle allows the player after the big blind to post a blind raise of
+	// twice the big blind before the cards are dealt.
+	Straddle bool
+	// BombPotAnte is posted by every player in a bomb pot. There is no
+	// preflop betting in a bomb pot, the hand starts on the flop. Zero means
+	// bomb pots are not played at this table.
+	BombPotAnte int
+	// StartingStack is the amount of chips a player buys in with when he does
+	// not choose an amount himself.
+	StartingStack int
+	// MinBuyIn and MaxBuyIn are the smallest and biggest amount of chips a
+	// player can buy in or rebuy with. A top up can not bring the stack of
+	// the player above the MaxBuyIn.
+	MinBuyIn int
+	MaxBuyIn int
+	// ActionTime is the time a player has to act. When it runs out the node
+	// of the player checks for him, or folds when there is a bet. The other
+	// players only show the time he has left. Zero means there is no limit.
+	ActionTime time.Duration
+	// MaxSitOutOrbits is the number of orbits a player can sit out before he
+	// is cashed out and loses his seat.
+	MaxSitOutOrbits int
+	// SeatReservation is the time a player that took a seat has to sit down,
+	// after that the seat is free again. Zero means reservations do not
+	// expire.
+	SeatReservation time.Duration
+}
+
+// ForcedBets are the optional forced bets of a hand. The dealer decides on
+// them from the straddles and bomb pot votes he received and sends them with
+// the preflop, so every player posts the same bets whatever votes reached
+// him.
+type ForcedBets struct {
+	// Straddler is the player that straddles the hand, empty when nobody
+	// does.
+	Straddler string
+	// BombPot is set when the hand is a bomb pot.
+	BombPot bool
+}
+
+// postForcedBets puts the antes and blinds of the new hand in the pot, deals
+// the first street and gives the turn to the first player to act. Stud games
+// are played without blinds, the bring-in is posted once the up cards are
+// revealed.
+func (g *GameState) postForcedBets(order []string, bets ForcedBets) {
+	streets := g.variant().streets()
+	g.clearForcedBets(bets)
+
+	if bets.BombPot {
+		for _, addr := range order {
+			g.betting.postAnte(addr, g.options.BombPotAnte)
+		}
+
+		// There is no betting before the flop, the hole cards are dealt and
+		// the hand continues right away on the next street.
+		g.dealStreet(streets[0])
+		g.currentStatus.Set(int32(streets[1].status))
+		g.publish(EventStreetAdvanced, StreetEvent{Status: streets[1].status.String()})
+		g.startBetting(streets[1])
+		return
+	}
+
+	if ante := g.ante(); ante > 0 {
+		for _, addr := range order {
+			g.betting.postAnte(addr, ante)
+		}
+	}
+
+	if g.variant().isStud() {
+		g.startBetting(streets[0])
+		return
+	}
+
+	g.dealStreet(streets[0])
+	g.postBlinds(order, bets.Straddler)
+}
+
+// postBlinds puts the blinds and the optional straddle in the pot and gives
+// the turn to the player after the last blind. Heads up the dealer posts the
+// small blind and acts first. Players that missed the blinds and sat in
+// again post a big blind.
+func (g *GameState) postBlinds(order []string, straddler string) {
+	sb, bb := blindPositions(len(order))
+
+	g.betting.postBlind(order[sb], g.structure().smallBlind())
+	g.betting.postBlind(order[bb], g.structure().bigBlind())
+
+	for i, addr := range order {
+		g.table.updatePlayer(addr, func(p *Player) {
+			if p.missedBlinds && i != bb {
+				g.betting.postBlind(addr, g.structure().bigBlind())
+			}
+			p.missedBlinds = false
+		})
+	}
+
+	last := bb
+	if utg := (bb + 1) % len(order); straddler != "" && straddler == order[utg] {
+		// The straddler acts last preflop, just like the big blind would.
+		g.betting.postBlind(order[utg], 2*g.structure().bigBlind())
+		last = utg
+	}
+
+	g.setTurn(rotate(order, last+1))
+}
+
+// straddlePosition returns the player that can straddle the hand, the player
+// after the big blind. Heads up nobody can straddle.
+func straddlePosition(order []string) (string, bool) {
+	if len(order) <= 2 {
+		return "", false
+	}
+	_, bb := blindPositions(len(order))
+	return order[(bb+1)%len(order)], true
+}
+
+// decideForcedBets decides on the forced bets of the hand the dealer deals.
+// The player after the big blind straddles when he asked to, and the hand
+// is a bomb pot when the majority of the players dealt in voted for it.
+func (g *GameState) decideForcedBets(order []string) ForcedBets {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.options.BombPotAnte > 0 && g.variant().hasBoard() {
+		votes := 0
+		for _, addr := range order {
+			if g.bombPotVotes[addr] {
+				votes++
+			}
+		}
+		if votes*2 > len(order) {
+			return ForcedBets{BombPot: true}
+		}
+	}
+
+	bets := ForcedBets{}
+	if utg, ok := straddlePosition(order); ok && g.options.Straddle && !g.variant().isStud() && g.straddles[utg] {
+		bets.Straddler = utg
+	}
+	return bets
+}
+
+// validateForcedBets checks the forced bets the dealer decided on. We can not
+// check the votes of the others, they might not have reached us yet, but we
+// know whether we asked to straddle ourselves.
+func (g *GameState) validateForcedBets(order []string, bets ForcedBets) error {
+	if bets.BombPot {
+		if g.options.BombPotAnte == 0 || !g.variant().hasBoard() {
+			return fmt.Errorf("a bomb pot but bomb pots are not played")
+		}
+		if bets.Straddler != "" {
+			return fmt.Errorf("a straddle in a bomb pot")
+		}
+	}
+	if bets.Straddler == "" {
+		return nil
+	}
+
+	if !g.options.Straddle || g.variant().isStud() {
+		return fmt.Errorf("a straddle but straddles are not allowed")
+	}
+	if utg, ok := straddlePosition(order); !ok || utg != bets.Straddler {
+		return fmt.Errorf("a straddle by player (%s) who is not after the big blind", bets.Straddler)
+	}
+
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	if bets.Straddler == g.listenAddr && !g.straddles[g.listenAddr] {
+		return fmt.Errorf("a straddle we did not ask for")
+	}
+	return nil
+}
+
+// clearForcedBets clears the straddles once a hand is dealt, a straddle only
+// counts for a single hand. The bomb pot votes are cleared once the bomb pot
+// is played.
+func (g *GameState) clearForcedBets(bets ForcedBets) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.straddles = make(map[string]bool)
+	if bets.BombPot {
+		g.bombPotVotes = make(map[string]bool)
+	}
+}
+
+// Straddle tells the other players we want to straddle the next hand.
+func (g *GameState) Straddle() error {
+	if !g.options.Straddle {
+		return newGameError(ErrCodeNotAllowed, "straddles are not allowed at this table")
+	}
+
+	g.lock.Lock()
+	g.straddles[g.listenAddr] = true
+	g.lock.Unlock()
+
+	g.sendToPlayers(MessageStraddle{}, g.getOtherPlayers()...)
+
+	return nil
+}
+
+// VoteBombPot votes for a bomb pot in the next hand.
+func (g *GameState) VoteBombPot() error {
+	if g.options.BombPotAnte == 0 || !g.variant().hasBoard() {
+		return newGameError(ErrCodeNotAllowed, "bomb pots are not played at this table")
+	}
+
+	g.lock.Lock()
+	g.bombPotVotes[g.listenAddr] = true
+	g.lock.Unlock()
+
+	g.sendToPlayers(MessageBombPotVote{}, g.getOtherPlayers()...)
+
+	return nil
+}
+
+func (g *GameState) handleStraddle(from string) error {
+	if !g.options.Straddle {
+		return fmt.Errorf("player (%s) wants to straddle but straddles are not allowed", from)
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.straddles[from] = true
+
+	return nil
+}
+
+func (g *GameState) handleBombPotVote(from string) error {
+	if g.options.BombPotAnte == 0 {
+		return fmt.Errorf("player (%s) voted for a bomb pot but bomb pots are not played", from)
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.bombPotVotes[from] = true
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testDeck is an encrypted deck that is never decrypted, it only needs a card
+// at every position.
+var testDeck = make([][]byte, 52)
+
+func TestAntes(t *testing.T) {
+	g := newSitOutGame(":3000", ":4000", ":5000")
+	g.options.Ante = 5
+
+	order := g.handOrder()
+	g.setStatus(GameStatusPreFlop)
+	g.startHand(testDeck, order, g.decideForcedBets(order))
+
+	// Every player posts the ante, on top of the blinds.
+	assert.Equal(t, 990, g.betting.stack(":4000"))
+	assert.Equal(t, 985, g.betting.stack(":5000"))
+	assert.Equal(t, 995, g.betting.stack(":3000"))
+	assert.Equal(t, 30, g.betting.total())
+	assert.Equal(t, 0, int(g.currentPlayerTurn.Get()))
+}
+
+func TestStraddle(t *testing.T) {
+	g := newSitOutGame(":3000", ":4000", ":5000", ":6000")
+	g.options.Straddle = true
+
+	order := g.handOrder()
+	assert.Equal(t, []string{":4000", ":5000", ":6000", ":3000"}, order)
+	// Only the player after the big blind can straddle.
+	assert.Nil(t, g.handleStraddle(":5000"))
+	assert.Equal(t, ForcedBets{}, g.decideForcedBets(order))
+	assert.Nil(t, g.handleStraddle(":6000"))
+	bets := g.decideForcedBets(order)
+	assert.Equal(t, ForcedBets{Straddler: ":6000"}, bets)
+
+	// The dealer can not make up a straddle for another seat, or for us.
+	assert.Nil(t, g.validateForcedBets(order, bets))
+	assert.NotNil(t, g.validateForcedBets(order, ForcedBets{Straddler: ":5000"}))
+	assert.NotNil(t, g.validateForcedBets([]string{":5000", ":6000", ":3000", ":4000"}, ForcedBets{Straddler: ":3000"}))
+	assert.NotNil(t, g.validateForcedBets(order[:2], ForcedBets{Straddler: ":6000"}))
+
+	g.setStatus(GameStatusPreFlop)
+	g.startHand(testDeck, order, bets)
+	assert.Equal(t, 20, g.betting.bet(":6000"))
+	// The straddler acts last, the button acts first.
+	assert.Equal(t, 0, int(g.currentPlayerTurn.Get()))
+	assert.Equal(t, ForcedBets{}, g.decideForcedBets(order))
+
+	// A straddle we did not receive yet is played all the same, but not at a
+	// table without straddles.
+	assert.Nil(t, g.validateForcedBets(order, bets))
+	g.options.Straddle = false
+	assert.NotNil(t, g.validateForcedBets(order, bets))
+}
+
+func TestBombPot(t *testing.T) {
+	g := newSitOutGame(":3000", ":4000", ":5000")
+	g.options.BombPotAnte = 10
+
+	order := g.handOrder()
+	assert.Nil(t, g.handleBombPotVote(":4000"))
+	assert.Equal(t, ForcedBets{}, g.decideForcedBets(order))
+	assert.Nil(t, g.handleBombPotVote(":5000"))
+	bets := g.decideForcedBets(order)
+	assert.Equal(t, ForcedBets{BombPot: true}, bets)
+	assert.Nil(t, g.validateForcedBets(order, bets))
+	assert.NotNil(t, g.validateForcedBets(order, ForcedBets{BombPot: true, Straddler: ":3000"}))
+
+	// There are no blinds and the hand starts on the flop.
+	g.setStatus(GameStatusPreFlop)
+	g.startHand(testDeck, order, bets)
+	for _, addr := range order {
+		assert.Equal(t, 990, g.betting.stack(addr))
+	}
+	assert.Equal(t, GameStatusFlop, GameStatus(g.currentStatus.Get()))
+	assert.Equal(t, ForcedBets{}, g.decideForcedBets(order))
+
+	g.options.BombPotAnte = 0
+	assert.NotNil(t, g.validateForcedBets(order, bets))
+}
@@ -2,16 +2,28 @@ package p2p
 
 import (
 	"fmt"
-	"os"
-	"sort"
+	"sync"
 	"time"
 
+	"github.com/anthdm/ggpoker/deck"
 	"github.com/sirupsen/logrus"
 )
 
 type GameState struct {
+	// id is the ID of the table.
+	id          string
 	listenAddr  string
 	broadcastch chan BroadcastTo
+	rotation    Rotation
+	options     TableOptions
+
+	// currentGame is the index of the game in the rotation that is played in
+	// the current hand.
+	currentGame *AtomicInt
+	// handsInGame is the number of hands played of the current game.
+	handsInGame int
+	// gameStarted is the time the first hand of the current game started.
+	gameStarted time.Time
 
 	// currentStatus should be atomically accessable.
 	currentStatus *AtomicInt
@@ -22,89 +34,181 @@ type GameState struct {
 	currentDealer *AtomicInt
 	// currentPlayerTurn should be atomically accessable.
 	currentPlayerTurn *AtomicInt
+	// actionLock is held while an action or a draw is validated and applied,
+	// so the turn cannot change in between.
+	actionLock sync.Mutex
+	// timerLock protects the action timer of the current turn.
+	timerLock    sync.Mutex
+	turnStarted  time.Time
+	turnTimedOut bool
 	// playersList is the list of connected players to the network
 	playersList *PlayersList
 
 	table *Table
+
+	betting *bettingState
+	events  *eventBus
+
+	// lock protects the cards of the current hand.
+	lock sync.RWMutex
+	// key is our own secret key that is used to encrypt the deck.
+	key *deck.Key
+	// encDeck is the deck shuffled and encrypted by every player on the table.
+	encDeck [][]byte
+	// layout keeps track of which cards of the deck are dealt to whom.
+	layout *cardLayout
+	// holeCards holds our own decrypted private cards by their position in
+	// the deck. In draw games the discarded cards are kept in here as well.
+	holeCards map[int]deck.Card
+	// publicCards holds the revealed community cards and face up cards by
+	// their position in the deck.
+	publicCards map[int]deck.Card
+	// awaitingUpCards is set in stud games until the face up cards of the
+	// street are revealed, the first player to act depends on them.
+	awaitingUpCards bool
+	// drawing is set in draw games while the players discard and draw their
+	// replacement cards.
+	drawing bool
+	// shownHands holds the hole cards the players show at showdown.
+	shownHands map[string][]deck.Card
+	lastHand   *HandSummary
+	// handFinished is set once the pots of the current hand are divided.
+	handFinished bool
+	// runPreference is the number of times we want to run the board when
+	// everybody is all-in.
+	runPreference int
+	// runProposals holds the number of times each player wants to run the board.
+	runProposals map[string]int
+	// runout is set when all players are all-in before the river.
+	runout    bool
+	runsDealt bool
+	// straddles holds the players that want to straddle the next hand.
+	straddles map[string]bool
+	// bombPotVotes holds the players that voted for a bomb pot.
+	bombPotVotes map[string]bool
+	// ledger records the buy-ins and cash-outs of every player.
+	ledger *ledger
+	// tournament is nil in a cash game.
+	tournament *tournamentState
+	// coordinator keeps our table in sync with the other tables of a
+	// multi-table tournament, coordinatorTable is the number of our table.
+	coordinator      TableCoordinator
+	coordinatorTable int
+	// arrival is the move that brought us to the table, until the first hand
+	// after it is over.
+	arrivalLock sync.Mutex
+	arrival     *PlayerMove
+
+	// quit is closed when we leave the table, it stops the loops of the game.
+	quit      chan struct{}
+	closeOnce sync.Once
 }
 
-func NewGame(addr string, bc chan BroadcastTo) *GameState {
+func NewGame(cfg ServerConfig, bc chan BroadcastTo) *GameState {
+	maxSeats := cfg.MaxPlayers
+	if maxSeats == 0 {
+		maxSeats = defaultMaxPlayers
+	}
 	g := &GameState{
-		listenAddr:          addr,
+		id:                  cfg.TableID,
+		listenAddr:          cfg.playerAddr(),
 		broadcastch:         bc,
+		rotation:            cfg.Rotation,
+		options:             cfg.TableOptions,
+		currentGame:         NewAtomicInt(0),
 		currentStatus:       NewAtomicInt(int32(GameStatusConnected)),
 		playersList:         NewPlayersList(),
 		currentPlayerAction: NewAtomicInt(0),
 		currentDealer:       NewAtomicInt(0),
 		currentPlayerTurn:   NewAtomicInt(0),
-		table:               NewTable(6),
+		table:               NewTable(maxSeats),
+		betting:             newBettingState(),
+		ledger:              newLedger(),
+		events:              newEventBus(),
+		holeCards:           make(map[int]deck.Card),
+		publicCards:         make(map[int]deck.Card),
+		shownHands:          make(map[string][]deck.Card),
+		runPreference:       1,
+		runProposals:        make(map[string]int),
+		straddles:           make(map[string]bool),
+		bombPotVotes:        make(map[string]bool),
+		coordinator:         cfg.Coordinator,
+		coordinatorTable:    cfg.CoordinatorTable,
+		quit:                make(chan struct{}),
+	}
+
+	if cfg.Tournament != nil {
+		g.tournament = newTournamentState(*cfg.Tournament)
 	}
 
-	g.playersList.add(addr)
+	g.playersList.add(g.listenAddr)
 
 	go g.loop()
+	if g.options.ActionTime > 0 {
+		go g.actionTimerLoop()
+	}
+	if g.options.SeatReservation > 0 {
+		go g.reservationLoop()
+	}
 
 	return g
 }
 
 func (g *GameState) canTakeAction(from string) bool {
-	currentPlayerAddr := g.playersList.get(g.currentPlayerTurn.Get())
-	return currentPlayerAddr == from
+	player, err := g.table.GetPlayerAtPos(int(g.currentPlayerTurn.Get()))
+	if err != nil {
+		return false
+	}
+	return player.addr == from
 }
 
 func (g *GameState) isFromCurrentDealer(from string) bool {
-	return g.playersList.get(g.currentDealer.Get()) == from
+	dealer, _ := g.getCurrentDealerAddr()
+	return dealer == from
 }
 
 func (g *GameState) handlePlayerAction(from string, action MessagePlayerAction) error {
-	if !g.canTakeAction(from) {
+	g.actionLock.Lock()
+	defer g.actionLock.Unlock()
+
+	if g.isDrawing() || !g.canTakeAction(from) {
 		return fmt.Errorf("player (%s) taking action before his turn", from)
 	}
 
 	// If we receive a message from a peer that doenst have the same game status
-	// as ours, but is not the current dealer we return an error. Cannot proceed.
-	if action.CurrentGameStatus != GameStatus(g.currentStatus.Get()) && !g.isFromCurrentDealer(from) {
+	// as ours we return an error. Cannot proceed.
+	if action.CurrentGameStatus != GameStatus(g.currentStatus.Get()) {
 		return fmt.Errorf("player (%s) has not the correct game status (%s)", from, action.CurrentGameStatus)
 	}
 
-	// Every player in this case will need to set the current game status to the next one (next round).
-	// NEXT
-	// 1. set the next player action to IDLE
-	// 2. set the next current game status
-	if g.playersList.get(g.currentDealer.Get()) == from {
-		g.advanceToNexRound()
+	if err := g.validateAction(from, action.Action, action.Value); err != nil {
+		return fmt.Errorf("player (%s) took an invalid action: %s", from, err)
 	}
 
-	// TODO:(@anthdm)
-	// This still not fixed!
-	// This still not fixed!
-	// This function should be handle the logic of picking the next player
-	// internally. Cause maybe the next player in the list in not the next index, hence not
-	// g.currentPlayerTurn + 1, due to the fact that his status can be just "connected"
-	g.incNextPlayer()
-
 	logrus.WithFields(logrus.Fields{
 		"we":     g.listenAddr,
 		"from":   from,
 		"action": action,
 	}).Info("recv player action")
 
+	g.applyAction(from, action.Action, action.Value)
+
 	return nil
 }
 
 func (g *GameState) TakeAction(action PlayerAction, value int) error {
+	g.actionLock.Lock()
+	defer g.actionLock.Unlock()
+
+	if _, ok := g.variant().street(GameStatus(g.currentStatus.Get())); !ok || g.isDrawing() {
+		return newGameError(ErrCodeWrongGameStatus, "cannot bet in game status (%s)", GameStatus(g.currentStatus.Get()))
+	}
 	if !g.canTakeAction(g.listenAddr) {
-		return fmt.Errorf("taking action before its my turn %s", g.listenAddr)
+		return newGameError(ErrCodeNotYourTurn, "taking action before its my turn %s", g.listenAddr)
 	}
 
-	g.currentPlayerAction.Set((int32)(action))
-
-	g.incNextPlayer()
-
-	// If we are the dealer that just took an action, we can go to the next round.
-	if g.listenAddr == g.playersList.get(g.currentDealer.Get()) {
-		// NEXT
-		g.advanceToNexRound()
+	if err := g.validateAction(g.listenAddr, action, value); err != nil {
+		return err
 	}
 
 	a := MessagePlayerAction{
@@ -114,51 +218,164 @@ func (g *GameState) TakeAction(action PlayerAction, value int) error {
 	}
 	g.sendToPlayers(a, g.getOtherPlayers()...)
 
+	g.currentPlayerAction.Set((int32)(action))
+	g.applyAction(g.listenAddr, action, value)
+
 	return nil
 }
 
-func (g *GameState) getNextGameStatus() GameStatus {
+func (g *GameState) validateAction(addr string, action PlayerAction, value int) error {
+	return g.betting.validateAction(addr, action, value, g.structure(), g.isBigBetStreet())
+}
+
+// isBigBetStreet reports whether the bets in the current street are of the
+// big bet size in fixed limit.
+func (g *GameState) isBigBetStreet() bool {
+	st, _ := g.variant().street(GameStatus(g.currentStatus.Get()))
+	return st.bigBet
+}
+
+// isLastStreet reports whether we are in the last betting round of the hand.
+func (g *GameState) isLastStreet() bool {
+	streets := g.variant().streets()
+	return GameStatus(g.currentStatus.Get()) == streets[len(streets)-1].status
+}
+
+// applyAction updates the game with an action that is already validated and
+// moves the turn to the next player, or to the next street when the betting
+// of this street is complete.
+func (g *GameState) applyAction(addr string, action PlayerAction, value int) {
+	g.betting.apply(addr, action, value)
+	g.publish(EventActionTaken, ActionEvent{Addr: addr, Action: action.String(), Value: value})
+
+	if active := g.betting.activePlayers(); len(active) == 1 {
+		g.winByFold(active[0])
+		return
+	}
+	if g.betting.isStreetComplete() {
+		// When nobody can bet anymore before the last street we run out the
+		// rest of the cards. In draw games the players still draw, so the hand
+		// just moves on without betting.
+		if g.betting.isAllIn() && !g.isLastStreet() && !g.variant().hasDraws() {
+			g.startRunout()
+			return
+		}
+		g.advanceToNexRound()
+		return
+	}
+
+	g.incNextPlayer()
+}
+
+// setTurn gives the turn to the first player in the given order that is still
+// in the hand.
+func (g *GameState) setTurn(order []string) {
+	for _, addr := range order {
+		if !g.betting.canAct(addr) {
+			continue
+		}
+		player, err := g.table.GetPlayer(addr)
+		if err != nil {
+			continue
+		}
+		g.setCurrentTurn(player.tablePos)
+		return
+	}
+}
+
+// getNextStreet returns the street that is played after the current one.
+func (g *GameState) getNextStreet() street {
 	status := GameStatus(g.currentStatus.Get())
-	switch status {
-	case GameStatusPreFlop:
-		return GameStatusFlop
-	case GameStatusFlop:
-		return GameStatusTurn
-	case GameStatusTurn:
-		return GameStatusRiver
-	case GameStatusRiver:
-		return GameStatusPlayerReady
-	default:
-		fmt.Printf("invalid status => %+v\n", status)
-		panic("invalid game status")
+	streets := g.variant().streets()
+	for i, st := range streets[:len(streets)-1] {
+		if st.status == status {
+			return streets[i+1]
+		}
 	}
+
+	fmt.Printf("invalid status => %+v\n", status)
+	panic("invalid game status")
 }
 
 func (g *GameState) advanceToNexRound() {
 	g.currentPlayerAction.Set(int32(PlayerActionNone))
 
-	if GameStatus(g.currentStatus.Get()) == GameStatusRiver {
-		g.SetReady()
+	if g.isLastStreet() {
+		g.startShowdown()
+		return
+	}
+
+	g.betting.nextStreet()
+
+	st := g.getNextStreet()
+	g.currentStatus.Set(int32(st.status))
+	g.publish(EventStreetAdvanced, StreetEvent{Status: st.status.String()})
+	g.startBetting(st)
+}
+
+// startBetting deals the cards of the street and gives the turn to the first
+// player to act. In stud games the first player depends on the face up cards,
+// so nobody can act until those are revealed. In draw games the players draw
+// before the betting starts.
+func (g *GameState) startBetting(st street) {
+	if st.draw {
+		g.startDraw()
 		return
 	}
-	g.currentStatus.Set(int32(g.getNextGameStatus()))
+
+	if g.variant().isStud() {
+		g.setCurrentTurn(-1)
+		g.lock.Lock()
+		g.awaitingUpCards = true
+		g.lock.Unlock()
+	} else {
+		g.setTurn(g.dealOrder())
+	}
+
+	g.dealStreet(st)
 }
 
+// endHand is called when the betting of the hand is over.
+func (g *GameState) endHand() {
+	g.currentPlayerAction.Set(int32(PlayerActionNone))
+	g.removeSatOutPlayers()
+	_, wasDealer := g.getCurrentDealerAddr()
+	g.currentDealer.Set(int32(g.getNextDealer()))
+	g.eliminateBustedPlayers(wasDealer)
+	g.settleArrival()
+
+	// A player that cashed out or is eliminated does not play the next hand.
+	if _, err := g.table.GetPlayer(g.listenAddr); err != nil {
+		return
+	}
+	if err := g.SetReady(); err != nil {
+		logrus.Errorf("%s: %s", g.listenAddr, err)
+	}
+	g.scheduleDeal()
+}
+
+// incNextPlayer moves the turn to the next player on the table that is still
+// in the hand.
 func (g *GameState) incNextPlayer() {
-	player, err := g.table.GetPlayerAfter(g.listenAddr)
+	current, err := g.table.GetPlayerAtPos(int(g.currentPlayerTurn.Get()))
 	if err != nil {
-		panic(err)
+		logrus.Errorf("cannot find the current player: %s", err)
+		return
 	}
 
-	if g.playersList.len()-1 == int(g.currentPlayerTurn.Get()) {
-		g.currentPlayerTurn.Set(0)
-		return
+	next := current
+	for {
+		next, err = g.table.GetPlayerAfter(next.addr)
+		if err != nil {
+			logrus.Errorf("cannot find the next player: %s", err)
+			return
+		}
+		if next.addr == current.addr || g.betting.canAct(next.addr) {
+			break
+		}
 	}
-	g.currentPlayerTurn.Inc()
 
-	fmt.Println("the next player on the table is:", player.tablePos)
-	fmt.Println("old wrong value => ", g.currentPlayerTurn)
-	os.Exit(0)
+	g.setCurrentTurn(next.tablePos)
 }
 
 func (g *GameState) SetStatus(s GameStatus) {
@@ -167,22 +384,32 @@ func (g *GameState) SetStatus(s GameStatus) {
 }
 
 func (g *GameState) setStatus(s GameStatus) {
-	if s == GameStatusPreFlop {
-		g.incNextPlayer()
-	}
-
 	// Only update the status when the status is different.
 	if GameStatus(g.currentStatus.Get()) != s {
 		g.currentStatus.Set(int32(s))
 	}
 }
 
+// getCurrentDealerAddr returns the player that has the button, the first
+// seated player at or after the dealer seat.
 func (g *GameState) getCurrentDealerAddr() (string, bool) {
-	currentDealerAddr := g.playersList.get(g.currentDealer.Get())
+	dealerSeat := int(g.currentDealer.Get())
+	players := g.table.Players()
+	if len(players) == 0 {
+		return "", false
+	}
+
+	currentDealerAddr := players[0].addr
+	for _, player := range players {
+		if player.tablePos >= dealerSeat {
+			currentDealerAddr = player.addr
+			break
+		}
+	}
 	return currentDealerAddr, g.listenAddr == currentDealerAddr
 }
 
-func (g *GameState) ShuffleAndEncrypt(from string, deck [][]byte) error {
+func (g *GameState) ShuffleAndEncrypt(from string, encDeck [][]byte) error {
 	fmt.Println("addr", g.listenAddr)
 	fmt.Printf("%+v\n", g.table)
 	prevPlayer, err := g.table.GetPlayerBefore(g.listenAddr)
@@ -201,9 +428,21 @@ func (g *GameState) ShuffleAndEncrypt(from string, deck [][]byte) error {
 	// the previous player on the table we advance to the next round.
 	_, isDealer := g.getCurrentDealerAddr()
 	if isDealer && from == prevPlayer.addr {
-		g.setStatus(GameStatusPreFlop)
-		g.table.SetPlayerStatus(g.listenAddr, GameStatusPreFlop)
-		g.sendToPlayers(MessagePreFlop{}, g.getOtherPlayers()...)
+		game := g.nextGame()
+		if err := g.switchGame(game); err != nil {
+			return err
+		}
+		level := g.nextLevel()
+		if err := g.switchLevel(level); err != nil {
+			return err
+		}
+		status := g.variant().streets()[0].status
+		g.setStatus(status)
+		g.table.SetPlayerStatus(g.listenAddr, status)
+		order := g.handOrder()
+		bets := g.decideForcedBets(order)
+		g.sendToPlayers(MessagePreFlop{Deck: encDeck, Game: game, Level: level, Players: order, ForcedBets: bets}, g.getOtherPlayers()...)
+		g.startHand(encDeck, order, bets)
 		return nil
 	}
 
@@ -218,8 +457,20 @@ func (g *GameState) ShuffleAndEncrypt(from string, deck [][]byte) error {
 		"dealingToPlayer": dealToPlayer.addr,
 	}).Info("received cards and going to shuffle")
 
-	// TODO:(@anthdm) encryption and shuffle
-	g.sendToPlayers(MessageEncDeck{Deck: [][]byte{}}, dealToPlayer.addr)
+	key, err := deck.NewKey()
+	if err != nil {
+		return err
+	}
+	encDeck, err = deck.EncryptAndShuffle(key, encDeck)
+	if err != nil {
+		return err
+	}
+
+	g.lock.Lock()
+	g.key = key
+	g.lock.Unlock()
+
+	g.sendToPlayers(MessageEncDeck{Deck: encDeck}, dealToPlayer.addr)
 	g.setStatus(GameStatusDealing)
 
 	return nil
@@ -231,8 +482,21 @@ func (g *GameState) InitiateShuffleAndDeal() {
 		panic(err)
 	}
 
+	key, err := deck.NewKey()
+	if err != nil {
+		panic(err)
+	}
+	encDeck, err := deck.EncryptDeck(key, deck.New())
+	if err != nil {
+		panic(err)
+	}
+
+	g.lock.Lock()
+	g.key = key
+	g.lock.Unlock()
+
 	g.setStatus(GameStatusDealing)
-	g.sendToPlayers(MessageEncDeck{Deck: [][]byte{}}, dealToPlayer.addr)
+	g.sendToPlayers(MessageEncDeck{Deck: encDeck}, dealToPlayer.addr)
 
 	logrus.WithFields(logrus.Fields{
 		"we": g.listenAddr,
@@ -240,18 +504,37 @@ func (g *GameState) InitiateShuffleAndDeal() {
 	}).Info("dealing cards")
 }
 
+// maybeDeal starts the hand when we still have the button once the break
+// between the hands is over.
 func (g *GameState) maybeDeal() {
-	if GameStatus(g.currentStatus.Get()) == GameStatusPlayerReady {
-		g.InitiateShuffleAndDeal()
+	if GameStatus(g.currentStatus.Get()) != GameStatusPlayerReady {
+		return
+	}
+	if _, areWeDealer := g.getCurrentDealerAddr(); !areWeDealer {
+		return
 	}
+	if g.coordinator != nil {
+		g.letPlayersGo()
+	}
+	order := g.handOrder()
+	if len(order) < 2 || !g.hasEntrants(order) {
+		return
+	}
+	if g.isNegotiating() || g.tournamentFinished() {
+		return
+	}
+	if g.coordinator != nil && !g.coordinator.CanDeal(g.coordinatorTable, order) {
+		// The other tables are still playing their hand, or players still
+		// have to leave our table.
+		time.AfterFunc(coordinatorRetry, g.maybeDeal)
+		return
+	}
+	g.InitiateShuffleAndDeal()
 }
 
-// SetPlayerReady is getting called when we receive a ready message
-// from a player in the network taking a seat on the table.
-func (g *GameState) SetPlayerReady(addr string) {
-	tablePos := g.playersList.getIndex(addr)
-	g.table.AddPlayerOnPosition(addr, tablePos)
-
+// scheduleDeal starts the next hand after a short break when we have the
+// button.
+func (g *GameState) scheduleDeal() {
 	// TODO(@anthdm): This potentially going to cause an issue!
 	// If we don't have enough players the round cannot be started.
 	if g.table.LenPlayers() < 2 {
@@ -269,34 +552,65 @@ func (g *GameState) SetPlayerReady(addr string) {
 	}
 }
 
-// SetReady is being called when we set ourselfs as ready.
-func (g *GameState) SetReady() {
-	tablePos := g.playersList.getIndex(g.listenAddr)
-	g.table.AddPlayerOnPosition(g.listenAddr, tablePos)
-
-	g.sendToPlayers(MessageReady{}, g.getOtherPlayers()...)
-	g.setStatus(GameStatusPlayerReady)
+// SetPlayerReady is getting called when we receive a ready message
+// from a player in the network taking a seat on the table.
+func (g *GameState) SetPlayerReady(addr string, seat, buyIn int) {
+	g.claimSeat(addr, seat, false)
+	if buyIn > 0 {
+		if err := g.validateChips(addr, LedgerBuyIn, buyIn); err != nil {
+			logrus.Errorf("invalid buy-in of player (%s): %s", addr, err)
+		} else {
+			g.moveChips(addr, LedgerBuyIn, buyIn)
+		}
+	}
+	g.publish(EventPlayerReady, PlayerEvent{Addr: addr})
+	g.scheduleDeal()
 }
 
 func (g *GameState) sendToPlayers(payload any, addr ...string) {
 	g.broadcastch <- BroadcastTo{
 		To:      addr,
+		Table:   g.id,
 		Payload: payload,
 	}
 }
 
 func (g *GameState) AddPlayer(from string) {
+	if g.playersList.getIndex(from) != -1 {
+		return
+	}
+
 	// If the player is being added to the game. We are going to assume
 	// that he is ready to play.
 	g.playersList.add(from)
-	sort.Sort(g.playersList)
+
+	g.publish(EventPlayerJoined, PlayerEvent{Addr: from})
+}
+
+// RemovePlayer is called when a player disconnects from the network.
+// NOTE: the player keeps his seat, leaving the table in the middle of a hand
+// is not supported yet.
+func (g *GameState) RemovePlayer(addr string) {
+	g.publish(EventPlayerLeft, PlayerEvent{Addr: addr})
+}
+
+// Close stops the game when we leave the table.
+func (g *GameState) Close() {
+	g.closeOnce.Do(func() {
+		close(g.quit)
+	})
 }
 
 func (g *GameState) loop() {
 	ticker := time.NewTicker(time.Second * 5)
+	defer ticker.Stop()
 
 	for {
-		<-ticker.C
+		select {
+		case <-ticker.C:
+		case <-g.quit:
+			return
+		}
 
 		currentDealerAddr, _ := g.getCurrentDealerAddr()
 		logrus.WithFields(logrus.Fields{
@@ -322,17 +636,13 @@ func (g *GameState) getOtherPlayers() []string {
 	return players
 }
 
-// getPositionOnTable return the index of our own position on the table.
-func (g *GameState) getPositionOnTable() int {
-	for i := 0; i < g.playersList.len(); i++ {
-		if g.playersList.get(i) == g.listenAddr {
-			return i
-		}
-	}
-
-	panic("player does not exist in the playersList; that should not happen!!!")
-}
-
+// getNextDealer returns the seat the button moves to after the hand, the
+// seat of the next player at the table.
 func (g *GameState) getNextDealer() int {
-	panic("TODO")
+	dealer, _ := g.getCurrentDealerAddr()
+	next, err := g.table.GetPlayerAfter(dealer)
+	if err != nil {
+		return int(g.currentDealer.Get())
+	}
+	return next.tablePos
 }
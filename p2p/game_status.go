@@ -12,6 +12,8 @@ func (pa PlayerAction) String() string {
 		return "CHECK"
 	case PlayerActionBet:
 		return "BET"
+	case PlayerActionCall:
+		return "CALL"
 	default:
 		return "INVALID"
 	}
@@ -22,6 +24,7 @@ const (
 	PlayerActionFold
 	PlayerActionCheck
 	PlayerActionBet
+	PlayerActionCall
 )
 
 type GameStatus int32
@@ -42,6 +45,26 @@ func (g GameStatus) String() string {
 		return "TURN"
 	case GameStatusRiver:
 		return "RIVER"
+	case GameStatusShowdown:
+		return "SHOWDOWN"
+	case GameStatusThirdStreet:
+		return "THIRD STREET"
+	case GameStatusFourthStreet:
+		return "FOURTH STREET"
+	case GameStatusFifthStreet:
+		return "FIFTH STREET"
+	case GameStatusSixthStreet:
+		return "SIXTH STREET"
+	case GameStatusSeventhStreet:
+		return "SEVENTH STREET"
+	case GameStatusPreDraw:
+		return "PRE DRAW"
+	case GameStatusFirstDraw:
+		return "FIRST DRAW"
+	case GameStatusSecondDraw:
+		return "SECOND DRAW"
+	case GameStatusThirdDraw:
+		return "THIRD DRAW"
 	default:
 		return "unknown"
 	}
@@ -55,4 +78,14 @@ const (
 	GameStatusFlop
 	GameStatusTurn
 	GameStatusRiver
+	GameStatusShowdown
+	GameStatusThirdStreet
+	GameStatusFourthStreet
+	GameStatusFifthStreet
+	GameStatusSixthStreet
+	GameStatusSeventhStreet
+	GameStatusPreDraw
+	GameStatusFirstDraw
+	GameStatusSecondDraw
+	GameStatusThirdDraw
 )
@@ -0,0 +1,124 @@
+package p2p
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCodeIdempotencyKeyReused is returned when an idempotency key is sent
+// again with a different request.
+const ErrCodeIdempotencyKeyReused ErrorCode = "IDEMPOTENCY_KEY_REUSED"
+
+// idempotencyKeyHeader is the header a client sets to safely retry a request.
+// A request with a key that was already used is not taken again, the client
+// gets the response of the first request instead.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyKeyTTL is how long the response to a key is remembered.
+const idempotencyKeyTTL = 10 * time.Minute
+
+type idempotentResponse struct {
+	request string
+	created time.Time
+	// done is closed once the first request has been answered.
+	done   chan struct{}
+	status int
+	header http.Header
+	body   []byte
+}
+
+type idempotencyStore struct {
+	lock      sync.Mutex
+	responses map[string]*idempotentResponse
+}
+
+func newIdempotencyStore() *idempotencyStore {
+	return &idempotencyStore{
+		responses: make(map[string]*idempotentResponse),
+	}
+}
+
+// start returns the response stored for the key, or registers the request and
+// reports that it needs to be handled.
+func (st *idempotencyStore) start(key, request string) (*idempotentResponse, bool) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	for k, resp := range st.responses {
+		if time.Since(resp.created) > idempotencyKeyTTL {
+			delete(st.responses, k)
+		}
+	}
+
+	if resp, ok := st.responses[key]; ok {
+		return resp, false
+	}
+
+	resp := &idempotentResponse{
+		request: request,
+		created: time.Now(),
+		done:    make(chan struct{}),
+	}
+	st.responses[key] = resp
+
+	return resp, true
+}
+
+// idempotent makes retries of a POST with the same idempotency key return the
+// recorded response instead of taking the action twice. A retry that arrives
+// while the first request is still handled waits for its response.
+func (s *APIServer) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		request := r.Method + " " + r.URL.Path
+		resp, first := s.idempotency.start(key, request)
+		if first {
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			defer func() {
+				resp.status = rec.status
+				resp.header = w.Header().Clone()
+				resp.body = rec.body.Bytes()
+				close(resp.done)
+			}()
+			next.ServeHTTP(rec, r)
+			return
+		}
+
+		if resp.request != request {
+			writeError(w, newGameError(ErrCodeIdempotencyKeyReused, "idempotency key was used for (%s)", resp.request))
+			return
+		}
+
+		<-resp.done
+		for k, v := range resp.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(resp.status)
+		w.Write(resp.body)
+	})
+}
+
+// responseRecorder writes the response to the client and keeps a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
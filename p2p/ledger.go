@@ -0,0 +1,130 @@
+package p2p
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type LedgerEntryType string
+
+const (
+	LedgerBuyIn   LedgerEntryType = "BUY_IN"
+	LedgerRebuy   LedgerEntryType = "REBUY"
+	LedgerTopUp   LedgerEntryType = "TOP_UP"
+	LedgerCashOut LedgerEntryType = "CASH_OUT"
+	// LedgerPayout is the prize a player wins in a tournament.
+	LedgerPayout LedgerEntryType = "PAYOUT"
+	// LedgerMoveIn and LedgerMoveOut are the stacks the players bring to and
+	// take from a table, when the coordinator of a multi-table tournament
+	// moves them.
+	LedgerMoveIn  LedgerEntryType = "MOVE_IN"
+	LedgerMoveOut LedgerEntryType = "MOVE_OUT"
+	// LedgerSeated is the stack a player already had at a table when we
+	// joined it.
+	LedgerSeated LedgerEntryType = "SEATED"
+)
+
+// LedgerEntry is a movement of chips between a player and the table.
+type LedgerEntry struct {
+	Time   time.Time       `json:"time"`
+	Addr   string          `json:"addr"`
+	Type   LedgerEntryType `json:"type"`
+	Amount int             `json:"amount"`
+	// Stack is the stack of the player after the entry.
+	Stack int `json:"stack"`
+}
+
+// LedgerBalance sums up the entries of a single player.
+type LedgerBalance struct {
+	Addr string `json:"addr"`
+	// BoughtIn is the total of the buy-ins, rebuys and top-ups of the player.
+	BoughtIn int `json:"boughtIn"`
+	// CashedOut is the total of the cash-outs and tournament prizes.
+	CashedOut int `json:"cashedOut"`
+	// Stack is the stack the player has at the table right now.
+	Stack int `json:"stack"`
+	// Net is what the player won, or lost when it is negative, so far.
+	Net int `json:"net"`
+}
+
+// Ledger is every movement of chips between the players and the table
+// during the session. Every player records the entries of all the players,
+// so the stacks at the table can be audited by anyone.
+type Ledger struct {
+	Entries  []LedgerEntry   `json:"entries"`
+	Balances []LedgerBalance `json:"balances"`
+}
+
+// ledger records the entries in the order they happened at the table.
+type ledger struct {
+	lock    sync.RWMutex
+	entries []LedgerEntry
+}
+
+func newLedger() *ledger {
+	return &ledger{
+		entries: []LedgerEntry{},
+	}
+}
+
+func (l *ledger) record(entry LedgerEntry) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.entries = append(l.entries, entry)
+}
+
+// balances sums up the entries of every player, the stacks are the stacks
+// the players have at the table right now.
+func (l *ledger) balances(stacks map[string]int) []LedgerBalance {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	byAddr := map[string]*LedgerBalance{}
+	for _, entry := range l.entries {
+		balance, ok := byAddr[entry.Addr]
+		if !ok {
+			balance = &LedgerBalance{Addr: entry.Addr}
+			byAddr[entry.Addr] = balance
+		}
+		switch entry.Type {
+		case LedgerCashOut, LedgerPayout, LedgerMoveOut:
+			balance.CashedOut += entry.Amount
+		default:
+			balance.BoughtIn += entry.Amount
+		}
+	}
+
+	balances := make([]LedgerBalance, 0, len(byAddr))
+	for addr, balance := range byAddr {
+		balance.Stack = stacks[addr]
+		balance.Net = balance.CashedOut + balance.Stack - balance.BoughtIn
+		balances = append(balances, *balance)
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].Addr < balances[j].Addr
+	})
+
+	return balances
+}
+
+// Ledger returns the entries and balances of the session.
+func (g *GameState) Ledger() Ledger {
+	g.ledger.lock.RLock()
+	entries := make([]LedgerEntry, len(g.ledger.entries))
+	copy(entries, g.ledger.entries)
+	g.ledger.lock.RUnlock()
+
+	// The chips of a tournament are not worth anything, the players only
+	// win the prizes.
+	stacks := g.betting.stackSnapshot()
+	if g.tournament != nil {
+		stacks = nil
+	}
+
+	return Ledger{
+		Entries:  entries,
+		Balances: g.ledger.balances(stacks),
+	}
+}
@@ -0,0 +1,482 @@
+package p2p
+
+import (
+	"bytes"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// announceInterval is how often a node announces its tables to the lobby.
+	announceInterval = 10 * time.Second
+	// listingTTL is how long a table stays listed after it was announced for
+	// the last time.
+	listingTTL = 3 * announceInterval
+)
+
+// Listing is a table announced in the lobby.
+type Listing struct {
+	ID string `json:"id"`
+	// Host is the address of the node that announced the table, a node that
+	// joins the table connects to it and discovers the other players through
+	// it. A lobby takes the host from the address the announcement comes
+	// from, so a node can only announce its own tables.
+	Host       string `json:"host"`
+	Game       string `json:"game"`
+	Status     string `json:"status"`
+	SmallBlind int    `json:"smallBlind"`
+	BigBlind   int    `json:"bigBlind"`
+	// Seats is the number of seats that are taken.
+	Seats      int  `json:"seats"`
+	MaxSeats   int  `json:"maxSeats"`
+	Tournament bool `json:"tournament"`
+	// Config holds the settings of the table a node needs to join it.
+	Config ServerConfig `json:"config"`
+}
+
+// key identifies the table in the lobby, tables on different hosts can have
+// the same ID.
+func (l Listing) key() string {
+	return l.Host + "/" + l.ID
+}
+
+// validate checks the settings a node joins the table with.
+func (l Listing) validate() error {
+	if l.ID == "" || l.Host == "" {
+		return fmt.Errorf("a listing needs an ID and a host")
+	}
+	if l.Config.TableID != l.ID {
+		return fmt.Errorf("table (%s) is listed with the config of table (%s)", l.ID, l.Config.TableID)
+	}
+	return l.Config.Validate()
+}
+
+// LobbyFilter selects the tables of the lobby. The zero value matches every
+// table.
+type LobbyFilter struct {
+	// Variant only matches tables that play the variant, in any game of
+	// their rotation.
+	Variant *GameVariant
+	Limit   *Limit
+	// MinBigBlind and MaxBigBlind are the stakes of the tables, zero means
+	// there is no bound.
+	MinBigBlind int
+	MaxBigBlind int
+	// Open only matches tables with a free seat.
+	Open bool
+}
+
+func (f LobbyFilter) matches(l Listing) bool {
+	if f.MinBigBlind > 0 && l.BigBlind < f.MinBigBlind {
+		return false
+	}
+	if f.MaxBigBlind > 0 && l.BigBlind > f.MaxBigBlind {
+		return false
+	}
+	if f.Open && l.Seats >= l.MaxSeats {
+		return false
+	}
+	if f.Variant == nil && f.Limit == nil {
+		return true
+	}
+	for _, gm := range l.Config.Rotation.Games {
+		if f.Variant != nil && gm.GameVariant != *f.Variant {
+			continue
+		}
+		if f.Limit != nil && gm.BettingStructure.Limit != *f.Limit {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// TableHost is the node that plays at the tables created and joined through
+// the lobby.
+type TableHost interface {
+	AddTable(cfg ServerConfig) (*GameState, error)
+	Listings() []Listing
+}
+
+type listing struct {
+	Listing
+	expires time.Time
+}
+
+// Lobby lists the tables the nodes announce. A standalone lobby only lists
+// tables, a lobby that is embedded in a node can create and join tables for
+// the node as well.
+type Lobby struct {
+	lock     sync.RWMutex
+	listings map[string]listing
+	// host is nil for a standalone lobby.
+	host TableHost
+	// token authenticates creating and joining tables, it is the API token of
+	// the host.
+	token string
+}
+
+func NewLobby(token string, host TableHost) *Lobby {
+	return &Lobby{
+		listings: make(map[string]listing),
+		host:     host,
+		token:    token,
+	}
+}
+
+// Announce lists the tables, or keeps them listed for another listingTTL.
+func (l *Lobby) Announce(listings ...Listing) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	expires := time.Now().Add(listingTTL)
+	for _, li := range listings {
+		l.listings[li.key()] = listing{Listing: li, expires: expires}
+	}
+}
+
+// Tables returns the listed tables that match the filter, by their host and
+// ID.
+func (l *Lobby) Tables(f LobbyFilter) []Listing {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	tables := []Listing{}
+	for key, li := range l.listings {
+		if now.After(li.expires) {
+			delete(l.listings, key)
+			continue
+		}
+		if f.matches(li.Listing) {
+			tables = append(tables, li.Listing)
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].key() < tables[j].key()
+	})
+	return tables
+}
+
+// CreateTable starts a new table on the host and lists it.
+func (l *Lobby) CreateTable(cfg ServerConfig) (Listing, error) {
+	if l.host == nil {
+		return Listing{}, newGameError(ErrCodeNotAllowed, "the lobby does not host tables")
+	}
+	if cfg.TableID == "" {
+		return Listing{}, newGameError(ErrCodeInvalidRequest, "the table needs an ID")
+	}
+	if err := cfg.Validate(); err != nil {
+		return Listing{}, newGameError(ErrCodeInvalidRequest, "%s", err)
+	}
+	cfg.BootstrapPeers = nil
+
+	g, err := l.host.AddTable(cfg)
+	if err != nil {
+		return Listing{}, newGameError(ErrCodeNotAllowed, "%s", err)
+	}
+	return l.announceHost(g.ID())
+}
+
+// JoinTable joins the listed table with the host.
+func (l *Lobby) JoinTable(host, id string) (Listing, error) {
+	if l.host == nil {
+		return Listing{}, newGameError(ErrCodeNotAllowed, "the lobby does not host tables")
+	}
+
+	l.lock.RLock()
+	li, ok := l.listings[Listing{Host: host, ID: id}.key()]
+	l.lock.RUnlock()
+	if !ok {
+		return Listing{}, newGameError(ErrCodeUnknownTable, "table (%s) of host (%s) is not listed", id, host)
+	}
+
+	if err := li.validate(); err != nil {
+		return Listing{}, newGameError(ErrCodeInvalidRequest, "table (%s) of host (%s) is listed with an invalid config: %s", id, host, err)
+	}
+
+	cfg := li.Config
+	cfg.BootstrapPeers = []string{li.Host}
+	g, err := l.host.AddTable(cfg)
+	if err != nil {
+		return Listing{}, newGameError(ErrCodeNotAllowed, "%s", err)
+	}
+	return l.announceHost(g.ID())
+}
+
+// announceHost lists the tables of the host right away, instead of waiting
+// for the next announcement, and returns the table with the given ID.
+func (l *Lobby) announceHost(id string) (Listing, error) {
+	listings := l.host.Listings()
+	l.Announce(listings...)
+	for _, li := range listings {
+		if li.ID == id {
+			return li, nil
+		}
+	}
+	return Listing{}, fmt.Errorf("table (%s) is not announced by the host", id)
+}
+
+// Run serves the API of the lobby on the given address.
+func (l *Lobby) Run(listenAddr string) error {
+	logrus.WithField("listenAddr", listenAddr).Info("starting lobby")
+	return http.ListenAndServe(listenAddr, l.Handler())
+}
+
+// Handler returns the router of the lobby. Listing and announcing tables is
+// public, creating and joining a table needs the token of the host.
+func (l *Lobby) Handler() http.Handler {
+	r := mux.NewRouter()
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, newGameError(ErrCodeMethodNotAllowed, "method (%s) is not allowed on (%s)", r.Method, r.URL.Path))
+	})
+
+	r.HandleFunc(openAPIPath, makeHTTPHandleFunc(l.handleOpenAPI)).Methods(http.MethodGet)
+	r.HandleFunc("/tables", makeHTTPHandleFunc(l.handleGetTables)).Methods(http.MethodGet)
+	r.HandleFunc("/announce", makeHTTPHandleFunc(l.handleAnnounce)).Methods(http.MethodPost)
+	r.HandleFunc("/tables", makeHTTPHandleFunc(l.withToken(l.handleCreateTable))).Methods(http.MethodPost)
+	r.HandleFunc("/tables/{host}/{id}/join", makeHTTPHandleFunc(l.withToken(l.handleJoinTable))).Methods(http.MethodPost)
+
+	return r
+}
+
+// withToken only handles requests that carry the token as a bearer token.
+func (l *Lobby) withToken(f apiFunc) apiFunc {
+	return requireToken(l.token, f)
+}
+
+// requireToken only handles requests that carry the token as a bearer token,
+// no request is handled without a token.
+func requireToken(token string, f apiFunc) apiFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		t, ok := bearerToken(r)
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(t), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ggpoker"`)
+			return newGameError(ErrCodeUnauthorized, "missing or invalid bearer token")
+		}
+		return f(w, r)
+	}
+}
+
+// LobbyOpenAPI is the OpenAPI document describing every route of the Lobby.
+//
+//go:embed lobby.yaml
+var LobbyOpenAPI []byte
+
+func (l *Lobby) handleOpenAPI(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/yaml")
+	_, err := w.Write(LobbyOpenAPI)
+	return err
+}
+
+func (l *Lobby) handleGetTables(w http.ResponseWriter, r *http.Request) error {
+	f, err := parseLobbyFilter(r)
+	if err != nil {
+		return err
+	}
+	return JSON(w, http.StatusOK, l.Tables(f))
+}
+
+// parseLobbyFilter reads the filter from the query, like
+// ?game=omaha&limit=pot-limit&minBigBlind=20&open=true.
+func parseLobbyFilter(r *http.Request) (LobbyFilter, error) {
+	q := r.URL.Query()
+	f := LobbyFilter{}
+	if name := q.Get("game"); name != "" {
+		gv, err := ParseGameVariant(name)
+		if err != nil {
+			return f, newGameError(ErrCodeInvalidRequest, "%s", err)
+		}
+		f.Variant = &gv
+	}
+	if name := q.Get("limit"); name != "" {
+		limit, err := ParseLimit(name)
+		if err != nil {
+			return f, newGameError(ErrCodeInvalidRequest, "%s", err)
+		}
+		f.Limit = &limit
+	}
+	for name, v := range map[string]*int{"minBigBlind": &f.MinBigBlind, "maxBigBlind": &f.MaxBigBlind} {
+		if s := q.Get(name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				return f, newGameError(ErrCodeInvalidRequest, "invalid %s (%s)", name, s)
+			}
+			*v = n
+		}
+	}
+	if s := q.Get("open"); s != "" {
+		open, err := strconv.ParseBool(s)
+		if err != nil {
+			return f, newGameError(ErrCodeInvalidRequest, "invalid open (%s)", s)
+		}
+		f.Open = open
+	}
+	return f, nil
+}
+
+// handleAnnounce lists the tables of the node the request comes from. The
+// host of every listing is the address of the node, with the port of the
+// host it announced, so no node can list or overwrite the tables of another.
+func (l *Lobby) handleAnnounce(w http.ResponseWriter, r *http.Request) error {
+	remoteHost, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return newGameError(ErrCodeInvalidRequest, "invalid remote address (%s)", r.RemoteAddr)
+	}
+	listings := []Listing{}
+	if err := json.NewDecoder(r.Body).Decode(&listings); err != nil {
+		return newGameError(ErrCodeInvalidRequest, "invalid listings: %s", err)
+	}
+	for i, li := range listings {
+		if err := li.validate(); err != nil {
+			return newGameError(ErrCodeInvalidRequest, "%s", err)
+		}
+		_, port, err := net.SplitHostPort(li.Host)
+		if err != nil {
+			return newGameError(ErrCodeInvalidRequest, "invalid host (%s)", li.Host)
+		}
+		listings[i].Host = net.JoinHostPort(remoteHost, port)
+	}
+
+	l.Announce(listings...)
+	return JSON(w, http.StatusOK, listings)
+}
+
+func (l *Lobby) handleCreateTable(w http.ResponseWriter, r *http.Request) error {
+	var cfg ServerConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		return newGameError(ErrCodeInvalidRequest, "invalid table config: %s", err)
+	}
+
+	li, err := l.CreateTable(cfg)
+	if err != nil {
+		return err
+	}
+	return JSON(w, http.StatusOK, li)
+}
+
+func (l *Lobby) handleJoinTable(w http.ResponseWriter, r *http.Request) error {
+	vars := mux.Vars(r)
+	li, err := l.JoinTable(vars["host"], vars["id"])
+	if err != nil {
+		return err
+	}
+	return JSON(w, http.StatusOK, li)
+}
+
+// listing returns how the table is announced in the lobby, we are its host.
+func (t *tableServer) listing() Listing {
+	g := t.gameState
+	structure := g.structure()
+
+	return Listing{
+		ID:         t.TableID,
+		Host:       t.server.AdvertiseAddr,
+		Game:       g.game().String(),
+		Status:     GameStatus(g.currentStatus.Get()).String(),
+		SmallBlind: structure.smallBlind(),
+		BigBlind:   structure.bigBlind(),
+		Seats:      g.table.LenPlayers(),
+		MaxSeats:   g.table.maxSeats,
+		Tournament: t.Tournament != nil,
+		Config: ServerConfig{
+			TableID:      t.TableID,
+			Rotation:     t.Rotation,
+			TableOptions: t.TableOptions,
+			MaxPlayers:   t.MaxPlayers,
+			Tournament:   t.Tournament,
+		},
+	}
+}
+
+// Listings returns how every table we play at is announced in the lobby.
+func (s *Server) Listings() []Listing {
+	listings := []Listing{}
+	for _, t := range s.tableList() {
+		listings = append(listings, t.listing())
+	}
+	return listings
+}
+
+// announceLoop announces our tables to the embedded lobby and the lobby at
+// the LobbyAddr until the node stops.
+func (s *Server) announceLoop() {
+	ticker := time.NewTicker(announceInterval)
+	defer ticker.Stop()
+
+	for {
+		s.announce()
+		<-ticker.C
+	}
+}
+
+// announce lists our tables in the lobbies. Every player at a table could
+// announce it, only the player with the lowest address announces it to the
+// lobby at the LobbyAddr so the table is listed once.
+func (s *Server) announce() {
+	listings := s.Listings()
+	if s.lobby != nil {
+		s.lobby.Announce(listings...)
+	}
+	if s.LobbyAddr == "" {
+		return
+	}
+
+	announced := []Listing{}
+	for _, t := range s.tableList() {
+		if players := t.gameState.playersList.List(); len(players) == 0 || players[0] == s.AdvertiseAddr {
+			announced = append(announced, t.listing())
+		}
+	}
+	if err := postListings(s.LobbyAddr, announced); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"we":    s.AdvertiseAddr,
+			"lobby": s.LobbyAddr,
+		}).Errorf("cannot announce tables: %s", err)
+	}
+}
+
+var lobbyClient = &http.Client{Timeout: 5 * time.Second}
+
+// postListings announces the tables to the lobby at the given address, like
+// lobby.example.com:3100 or https://lobby.example.com.
+func postListings(addr string, listings []Listing) error {
+	b, err := json.Marshal(listings)
+	if err != nil {
+		return err
+	}
+	resp, err := lobbyClient.Post(baseURL(addr)+"/announce", "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("lobby responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// baseURL returns the URL of the HTTP server at the given address, like
+// example.com:3100 or https://example.com.
+func baseURL(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return strings.TrimSuffix(addr, "/")
+}
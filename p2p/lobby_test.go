@@ -0,0 +1,171 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testListing(host, id string, gm Game, seats int) Listing {
+	gm = gm.withDefaults()
+	return Listing{
+		ID:       id,
+		Host:     host,
+		Game:     gm.String(),
+		BigBlind: gm.BettingStructure.bigBlind(),
+		Seats:    seats,
+		MaxSeats: 6,
+		Config: ServerConfig{
+			TableID:    id,
+			Rotation:   Rotation{Games: []Game{gm}},
+			MaxPlayers: 6,
+		},
+	}
+}
+
+func TestLobbyFilter(t *testing.T) {
+	l := NewLobby("token", nil)
+	l.Announce(
+		testListing("a.example.com:3000", "main", Game{GameVariant: TexasHoldem}, 2),
+		testListing("a.example.com:3000", "plo", Game{GameVariant: Omaha}, 6),
+		testListing("b.example.com:3000", "main", Game{
+			GameVariant:      TexasHoldem,
+			BettingStructure: BettingStructure{Limit: NoLimit, SmallBet: 100},
+		}, 3),
+	)
+
+	ids := func(f LobbyFilter) []string {
+		keys := []string{}
+		for _, li := range l.Tables(f) {
+			keys = append(keys, li.key())
+		}
+		return keys
+	}
+	omaha, potLimit := Omaha, PotLimit
+	assert.Equal(t, []string{"a.example.com:3000/main", "a.example.com:3000/plo", "b.example.com:3000/main"}, ids(LobbyFilter{}))
+	assert.Equal(t, []string{"a.example.com:3000/plo"}, ids(LobbyFilter{Variant: &omaha, Limit: &potLimit}))
+	assert.Equal(t, []string{"b.example.com:3000/main"}, ids(LobbyFilter{MinBigBlind: 50}))
+	assert.Equal(t, []string{"a.example.com:3000/main", "b.example.com:3000/main"}, ids(LobbyFilter{MaxBigBlind: 100, Open: true}))
+
+	// A table that is not announced again is no longer listed.
+	l.listings["a.example.com:3000/main"] = listing{
+		Listing: l.listings["a.example.com:3000/main"].Listing,
+		expires: time.Now().Add(-time.Second),
+	}
+	assert.Equal(t, 2, len(l.Tables(LobbyFilter{})))
+
+	_, err := l.CreateTable(ServerConfig{TableID: "nlh"})
+	assert.Equal(t, ErrCodeNotAllowed, err.(*GameError).Code)
+}
+
+func TestLobbyAPI(t *testing.T) {
+	router := NewLobby("token", nil).Handler()
+	send := func(method, path, token string, body any) *httptest.ResponseRecorder {
+		b, err := json.Marshal(body)
+		assert.Nil(t, err)
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewReader(b))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	listings := []Listing{
+		testListing("a.example.com:3000", "plo", Game{GameVariant: Omaha}, 2),
+		testListing("b.example.com:3000", "razz", Game{GameVariant: Razz}, 2),
+	}
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/announce", "", listings).Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/announce", "", []Listing{{ID: "main"}}).Code)
+	invalid := testListing("a.example.com:3000", "nlh", Game{GameVariant: TexasHoldem}, 2)
+	invalid.Config.TableID = "plo"
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/announce", "", []Listing{invalid}).Code)
+
+	// The host is the node that announced the table, no other node can
+	// overwrite its listing.
+	forged := testListing("192.0.2.1:3000", "plo", Game{GameVariant: Razz}, 6)
+	b, err := json.Marshal([]Listing{forged})
+	assert.Nil(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/announce", bytes.NewReader(b))
+	req.RemoteAddr = "198.51.100.7:41000"
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	rec := send(http.MethodGet, "/tables?game=omaha&limit=pot-limit&open=true", "", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	tables := []Listing{}
+	assert.Nil(t, json.NewDecoder(rec.Body).Decode(&tables))
+	listings[0].Host = "192.0.2.1:3000"
+	assert.Equal(t, []Listing{listings[0]}, tables)
+	rec = send(http.MethodGet, "/tables?game=razz", "", nil)
+	assert.Nil(t, json.NewDecoder(rec.Body).Decode(&tables))
+	assert.Equal(t, []string{"192.0.2.1:3000/razz", "198.51.100.7:3000/plo"}, []string{tables[0].key(), tables[1].key()})
+
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/tables?game=bridge", "", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/tables?minBigBlind=-1", "", nil).Code)
+
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "/tables", "", ServerConfig{TableID: "nlh"}).Code)
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "/tables", "token", ServerConfig{TableID: "nlh"}).Code)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "/tables/a.example.com:3000/plo/join", "wrong", nil).Code)
+}
+
+func TestLobbyCreateAndJoin(t *testing.T) {
+	a := NewServer(ServerConfig{
+		ListenAddr:    ":23120",
+		APIListenAddr: ":0",
+	})
+	b := NewServer(ServerConfig{
+		ListenAddr:    ":23122",
+		APIListenAddr: ":0",
+		TableID:       "lobby",
+	})
+	go a.Start()
+	go b.Start()
+
+	lobbyA := NewLobby(a.APIToken, a)
+	created, err := lobbyA.CreateTable(ServerConfig{
+		TableID:     "plo",
+		GameVariant: Omaha,
+		MaxPlayers:  4,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, ":23120", created.Host)
+	assert.Equal(t, 10, created.BigBlind)
+	assert.Equal(t, 4, created.MaxSeats)
+	_, err = lobbyA.CreateTable(ServerConfig{TableID: "plo"})
+	assert.Equal(t, ErrCodeNotAllowed, err.(*GameError).Code)
+	_, err = lobbyA.CreateTable(ServerConfig{TableID: "sng", Tournament: &Tournament{Payouts: []int{80, 40}}})
+	assert.Equal(t, ErrCodeInvalidRequest, err.(*GameError).Code)
+
+	// The table reaches the lobby of b as JSON.
+	lobbyB := NewLobby(b.APIToken, b)
+	body, err := json.Marshal(a.Listings())
+	assert.Nil(t, err)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/announce", bytes.NewReader(body))
+	req.RemoteAddr = "127.0.0.1:41000"
+	lobbyB.Handler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	_, err = lobbyB.JoinTable("127.0.0.1:23120", "nlh")
+	assert.Equal(t, ErrCodeUnknownTable, err.(*GameError).Code)
+	invalid := testListing("127.0.0.1:23120", "sng", Game{GameVariant: TexasHoldem}, 2)
+	invalid.Config.Tournament = &Tournament{Payouts: []int{80, 40}}
+	lobbyB.Announce(invalid)
+	_, err = lobbyB.JoinTable("127.0.0.1:23120", "sng")
+	assert.Equal(t, ErrCodeInvalidRequest, err.(*GameError).Code)
+	joined, err := lobbyB.JoinTable("127.0.0.1:23120", "plo")
+	assert.Nil(t, err)
+	assert.Equal(t, "plo", joined.ID)
+
+	plo, _ := a.Table("plo")
+	assert.Eventually(t, func() bool {
+		return len(plo.getOtherPlayers()) == 1
+	}, 10*time.Second, 50*time.Millisecond)
+	assert.Equal(t, 2, len(b.Tables()))
+}
@@ -1,12 +1,17 @@
 package p2p
 
+import "github.com/anthdm/ggpoker/deck"
+
 type Message struct {
 	Payload any
 	From    string
+	// Table is the ID of the table the message is about.
+	Table string
 }
 
 type BroadcastTo struct {
 	To      []string
+	Table   string
 	Payload any
 }
 
@@ -18,10 +23,29 @@ func NewMessage(from string, payload any) *Message {
 }
 
 type Handshake struct {
-	Version     string
-	GameVariant GameVariant
-	GameStatus  GameStatus
-	ListenAddr  string
+	Version    string
+	ListenAddr string
+	// Tables holds every table we play at, the players join the tables they
+	// have in common.
+	Tables []TableHandshake
+}
+
+// TableHandshake tells a player how a table is played, and who sits where.
+type TableHandshake struct {
+	ID           string
+	Rotation     Rotation
+	TableOptions TableOptions
+	Tournament   *Tournament
+	MaxPlayers   int
+	GameStatus   GameStatus
+	// Seats holds the seat of every player at the table we know about, a
+	// player that joins later learns the seats from it.
+	Seats map[string]int
+	// Reservations holds the seats that are reserved by a player that did
+	// not sit down yet.
+	Reservations map[string]int
+	// Stacks holds the chips every player that bought in has at the table.
+	Stacks map[string]int
 }
 
 type MessagePlayerAction struct {
@@ -30,11 +54,25 @@ type MessagePlayerAction struct {
 	CurrentGameStatus GameStatus
 	// Action is the action that the player is willin to take.
 	Action PlayerAction
-	// The value of the bet if any
+	// The value of the bet if any, being the total amount the player has
+	// bet in the current street.
 	Value int
 }
 
-type MessagePreFlop struct{}
+type MessagePreFlop struct {
+	// Deck is the deck shuffled and encrypted by every player on the table.
+	Deck [][]byte
+	// Game is the index of the game in the rotation this hand is played with.
+	Game int
+	// Level is the index of the blind level of a tournament this hand is
+	// played at.
+	Level int
+	// Players are the players the dealer deals in, starting with the player
+	// after the button. Players that sit out are not dealt in.
+	Players []string
+	// ForcedBets are the straddle and bomb pot the dealer decided on.
+	ForcedBets ForcedBets
+}
 
 func (msg MessagePreFlop) String() string {
 	return "MSG: PREFLOP"
@@ -48,8 +86,127 @@ type MessageEncDeck struct {
 	Deck [][]byte
 }
 
-type MessageReady struct{}
+// MessageDecryptCard is passed around the table so every player can remove
+// his layer of encryption from a card, until it reaches the owner.
+type MessageDecryptCard struct {
+	// Index is the position of the card in the encrypted deck.
+	Index int
+	// Owner is the player that is allowed to see the card.
+	Owner string
+	Card  []byte
+}
+
+// MessagePublicCard is sent by the dealer once a community card or a face up
+// card is revealed.
+type MessagePublicCard struct {
+	Index int
+	Card  deck.Card
+}
+
+// MessageTakeSeat is sent by a player that reserves a seat at the table. A
+// player the coordinator of a multi-table tournament moved to the table sits
+// down right away, with the Stack he brings from his old table.
+type MessageTakeSeat struct {
+	Seat  int
+	Stack int
+}
+
+// MessageReady is sent by a player that takes a seat at the table.
+type MessageReady struct {
+	Seat int
+	// BuyIn is the amount of chips the player sits down with, it is zero
+	// when the player already has chips at the table.
+	BuyIn int
+}
+
+// MessageAddChips is sent by a player that rebuys or tops up between hands.
+type MessageAddChips struct {
+	Type   LedgerEntryType
+	Amount int
+}
+
+// MessageCashOut is sent by a player that takes his chips off the table.
+type MessageCashOut struct{}
+
+// MessageSitOut is sent by a player that keeps his seat, but does not want
+// to be dealt in.
+type MessageSitOut struct{}
+
+// MessageSitIn is sent by a player that wants to be dealt in again.
+type MessageSitIn struct {
+	// WaitForBB is set when the player waits for the big blind, instead of
+	// posting the blinds he missed.
+	WaitForBB bool
+}
+
+// MessageMovePlayers is sent by the dealer of a table in a multi-table
+// tournament, when the coordinator moves players to another table after the
+// hand.
+type MessageMovePlayers struct {
+	Moves []PlayerMove
+}
+
+// MessageJoinTable is sent to the players we are already connected to when
+// we join another table, the players at that table add us to it and send
+// their own in return.
+type MessageJoinTable struct {
+	Table TableHandshake
+}
+
+// MessageProposeDeal is sent by a player that wants to split the prizes
+// that are left between hands of a tournament. The Payouts are checked by
+// every player, he proposes the deal and accepts it at the same time.
+type MessageProposeDeal struct {
+	Method  DealMethod
+	Payouts []DealPayout
+}
+
+// MessageAcceptDeal is sent by a player that accepts the deal that is
+// proposed. It holds the deal, the acceptance can reach a player before the
+// proposal does.
+type MessageAcceptDeal struct {
+	Method  DealMethod
+	Payouts []DealPayout
+}
+
+// MessageDeclineDeal is sent by a player that declines the deal that is
+// proposed.
+type MessageDeclineDeal struct{}
 
 func (msg MessageReady) String() string {
 	return "MSG: READY"
 }
+
+// MessageStraddle is sent by a player that wants to straddle the next hand.
+type MessageStraddle struct{}
+
+func (msg MessageStraddle) String() string {
+	return "MSG: STRADDLE"
+}
+
+// MessageBombPotVote is sent by a player that votes for a bomb pot in the next hand.
+type MessageBombPotVote struct{}
+
+func (msg MessageBombPotVote) String() string {
+	return "MSG: BOMB POT VOTE"
+}
+
+// MessageRunItTwice is sent by every player in the hand when all players are
+// all-in before the river, telling how many times he wants to run the board.
+type MessageRunItTwice struct {
+	Runs int
+}
+
+// MessageDraw is sent by a player in a draw game that discards and draws new
+// cards from the deck.
+type MessageDraw struct {
+	CurrentGameStatus GameStatus
+	// Discards are the positions in the deck of the discarded cards. The
+	// replacement cards are the next cards of the deck.
+	Discards []int
+}
+
+// MessageShowdown is sent by every player in the hand at showdown.
+type MessageShowdown struct {
+	Cards []deck.Card
+}
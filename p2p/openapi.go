@@ -0,0 +1,22 @@
+package p2p
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPIPath is the route the OpenAPI document of the API is served on. The
+// document is public, so clients can discover the API before they have the
+// token.
+const openAPIPath = "/openapi.yaml"
+
+// OpenAPI is the OpenAPI document describing every route of the APIServer.
+//
+//go:embed openapi.yaml
+var OpenAPI []byte
+
+func (s *APIServer) handleOpenAPI(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/yaml")
+	_, err := w.Write(OpenAPI)
+	return err
+}
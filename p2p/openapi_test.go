@@ -0,0 +1,53 @@
+package p2p
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+// assertDescribesEveryRoute checks that every route of the router is in the
+// OpenAPI document. The routes of a table are described once, for every
+// server.
+func assertDescribesEveryRoute(t *testing.T, spec []byte, router *mux.Router) {
+	var doc struct {
+		Paths map[string]map[string]any `yaml:"paths"`
+	}
+	assert.Nil(t, yaml.Unmarshal(spec, &doc))
+
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		path = strings.TrimPrefix(path, tablePrefix)
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+
+		assert.Contains(t, doc.Paths, path)
+		for _, method := range methods {
+			assert.Contains(t, doc.Paths[path], strings.ToLower(method), path)
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+func TestOpenAPIDescribesEveryRoute(t *testing.T) {
+	api := NewAPIServer(":3001", "token", NewGame(ServerConfig{}, make(chan BroadcastTo)))
+	assertDescribesEveryRoute(t, OpenAPI, api.Handler().(*mux.Router))
+}
+
+func TestLobbyOpenAPIDescribesEveryRoute(t *testing.T) {
+	assertDescribesEveryRoute(t, LobbyOpenAPI, NewLobby("token", nil).Handler().(*mux.Router))
+}
+
+func TestCoordinatorOpenAPIDescribesEveryRoute(t *testing.T) {
+	c := NewCoordinator(Tournament{}, 20, 6)
+	assertDescribesEveryRoute(t, CoordinatorOpenAPI, c.Handler("token").(*mux.Router))
+}
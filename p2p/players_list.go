@@ -2,7 +2,6 @@ package p2p
 
 import (
 	"sort"
-	"strconv"
 	"sync"
 )
 
@@ -74,9 +73,9 @@ func (p *PlayersList) Len() int { return len(p.list) }
 func (p *PlayersList) Swap(i, j int) {
 	p.list[i], p.list[j] = p.list[j], p.list[i]
 }
-func (p *PlayersList) Less(i, j int) bool {
-	portI, _ := strconv.Atoi(p.list[i][1:])
-	portJ, _ := strconv.Atoi(p.list[j][1:])
 
-	return portI < portJ
+// Less orders the players by their normalized address. The order is only
+// used to list the players, the seats decide the order at the table.
+func (p *PlayersList) Less(i, j int) bool {
+	return p.list[i] < p.list[j]
 }
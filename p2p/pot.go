@@ -0,0 +1,224 @@
+package p2p
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/anthdm/ggpoker/deck"
+)
+
+// pot is the main pot or one of the side pots of a hand.
+type pot struct {
+	amount int
+	// eligible are the players that can win this pot.
+	eligible []string
+}
+
+// buildPots splits the chips every player contributed to the hand into a
+// main pot and side pots. Only the active players are eligible to win, but
+// the chips of folded players still end up in the pots.
+func buildPots(contributed map[string]int, active []string) []pot {
+	levels := []int{}
+	seen := map[int]bool{}
+	for _, addr := range active {
+		if c := contributed[addr]; c > 0 && !seen[c] {
+			seen[c] = true
+			levels = append(levels, c)
+		}
+	}
+	sort.Ints(levels)
+
+	pots := []pot{}
+	prev := 0
+	for _, level := range levels {
+		p := pot{eligible: []string{}}
+		for _, c := range contributed {
+			p.amount += min(c, level) - min(c, prev)
+		}
+		for _, addr := range active {
+			if contributed[addr] >= level {
+				p.eligible = append(p.eligible, addr)
+			}
+		}
+		prev = level
+
+		pots = append(pots, p)
+	}
+
+	// Chips above the highest contribution of the active players can only
+	// come from folded players, those go to the last pot.
+	rest := 0
+	for _, c := range contributed {
+		if c > prev {
+			rest += c - prev
+		}
+	}
+	if rest > 0 && len(pots) > 0 {
+		pots[len(pots)-1].amount += rest
+	}
+
+	return pots
+}
+
+// splitAmount divides the amount between the winners. The odd chips go to the
+// first winners in the given order.
+func splitAmount(amount int, winners []string, order []string) map[string]int {
+	shares := make(map[string]int)
+	if len(winners) == 0 {
+		return shares
+	}
+
+	ordered := orderPlayers(winners, order)
+	for i, addr := range ordered {
+		shares[addr] = amount / len(winners)
+		if i < amount%len(winners) {
+			shares[addr]++
+		}
+	}
+
+	return shares
+}
+
+// orderPlayers returns the given players sorted by their position in the order.
+func orderPlayers(players []string, order []string) []string {
+	ordered := []string{}
+	for _, addr := range order {
+		for _, p := range players {
+			if p == addr {
+				ordered = append(ordered, addr)
+			}
+		}
+	}
+	return ordered
+}
+
+// Half tells which part of a pot is awarded. In hi-lo games the pot is split
+// between the best high hand and the best low hand.
+type Half uint8
+
+func (h Half) String() string {
+	switch h {
+	case WholePot:
+		return "WHOLE POT"
+	case HighHalf:
+		return "HIGH HALF"
+	case LowHalf:
+		return "LOW HALF"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	WholePot Half = iota
+	HighHalf
+	LowHalf
+)
+
+// PotAward holds the winners of (a share of) a pot.
+type PotAward struct {
+	// Pot is the index of the pot, 0 being the main pot.
+	Pot     int
+	Half    Half
+	Amount  int
+	Winners []string
+	Hand    deck.HandValue
+}
+
+func (a PotAward) String() string {
+	name := "main pot"
+	if a.Pot > 0 {
+		name = fmt.Sprintf("side pot %d", a.Pot)
+	}
+	return fmt.Sprintf("%s (%s) of %d to %v", name, a.Half, a.Amount, a.Winners)
+}
+
+// RunResult holds the outcome of a single run of the board.
+type RunResult struct {
+	Board  []deck.Card
+	Awards []PotAward
+}
+
+// HandSummary holds the outcome of a hand.
+type HandSummary struct {
+	// Hands are the hole cards that are shown at showdown.
+	Hands map[string][]deck.Card
+	// Runs holds the result of every board that was dealt. There is more than
+	// one run when the players agreed to run it twice (or three times).
+	Runs []RunResult
+}
+
+// winnings returns the total amount each player won in the hand.
+func (hs *HandSummary) winnings(order []string) map[string]int {
+	won := make(map[string]int)
+	for _, run := range hs.Runs {
+		for _, award := range run.Awards {
+			for addr, share := range splitAmount(award.Amount, award.Winners, order) {
+				won[addr] += share
+			}
+		}
+	}
+	return won
+}
+
+// showdown evaluates the hands on every board and divides each pot equally
+// over the runs. The odd chips go to the first run.
+func showdown(variant GameVariant, pots []pot, boards [][]deck.Card, hands map[string][]deck.Card) []RunResult {
+	runs := make([]RunResult, len(boards))
+	for r, board := range boards {
+		runs[r].Board = board
+		runs[r].Awards = []PotAward{}
+
+		for i, p := range pots {
+			amount := p.amount / len(boards)
+			if r == 0 {
+				amount += p.amount % len(boards)
+			}
+
+			high := PotAward{Pot: i, Amount: amount, Winners: []string{}}
+			low := PotAward{Pot: i, Half: LowHalf, Winners: []string{}}
+			for _, addr := range p.eligible {
+				value, _ := variant.bestHand(hands[addr], board)
+				high.add(addr, value, variant.beats)
+
+				if !variant.isHiLo() {
+					continue
+				}
+				if value, ok := variant.bestLow(hands[addr], board); ok {
+					low.add(addr, value, func(a, b deck.HandValue) bool { return a < b })
+				}
+			}
+
+			// Without a qualifying low the high hand scoops the whole pot.
+			// Otherwise the odd chip of the split goes to the high half.
+			if len(low.Winners) > 0 {
+				low.Amount = amount / 2
+				high.Amount = amount - low.Amount
+				high.Half = HighHalf
+				runs[r].Awards = append(runs[r].Awards, high, low)
+				continue
+			}
+			runs[r].Awards = append(runs[r].Awards, high)
+		}
+	}
+
+	return runs
+}
+
+// add compares the hand of the player against the best hand so far.
+func (a *PotAward) add(addr string, value deck.HandValue, beats func(a, b deck.HandValue) bool) {
+	switch {
+	case len(a.Winners) == 0 || beats(value, a.Hand):
+		a.Hand = value
+		a.Winners = []string{addr}
+	case value == a.Hand:
+		a.Winners = append(a.Winners, addr)
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
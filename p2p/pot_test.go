@@ -0,0 +1,104 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/anthdm/ggpoker/deck"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildPots(t *testing.T) {
+	contributed := map[string]int{"a": 100, "b": 50, "c": 200, "d": 20}
+	// d folded, his chips still go to the pot.
+	pots := buildPots(contributed, []string{"a", "b", "c"})
+
+	assert.Equal(t, 3, len(pots))
+	assert.Equal(t, 170, pots[0].amount)
+	assert.Equal(t, []string{"a", "b", "c"}, pots[0].eligible)
+	assert.Equal(t, 100, pots[1].amount)
+	assert.Equal(t, []string{"a", "c"}, pots[1].eligible)
+	assert.Equal(t, 100, pots[2].amount)
+	assert.Equal(t, []string{"c"}, pots[2].eligible)
+}
+
+func TestSplitAmount(t *testing.T) {
+	shares := splitAmount(101, []string{"c", "a"}, []string{"a", "b", "c"})
+	assert.Equal(t, 51, shares["a"])
+	assert.Equal(t, 50, shares["c"])
+}
+
+func TestShowdownRunItTwice(t *testing.T) {
+	hands := map[string][]deck.Card{
+		"a": {{Suit: deck.Spades, Value: 1}, {Suit: deck.Harts, Value: 1}},
+		"b": {{Suit: deck.Spades, Value: 13}, {Suit: deck.Harts, Value: 13}},
+	}
+	flop := []deck.Card{
+		{Suit: deck.Clubs, Value: 2},
+		{Suit: deck.Diamonds, Value: 7},
+		{Suit: deck.Clubs, Value: 9},
+	}
+	boards := [][]deck.Card{
+		append(append([]deck.Card{}, flop...), deck.Card{Suit: deck.Diamonds, Value: 3}, deck.Card{Suit: deck.Clubs, Value: 4}),
+		append(append([]deck.Card{}, flop...), deck.Card{Suit: deck.Diamonds, Value: 13}, deck.Card{Suit: deck.Clubs, Value: 4}),
+	}
+	pots := []pot{{amount: 201, eligible: []string{"a", "b"}}}
+
+	runs := showdown(TexasHoldem, pots, boards, hands)
+	assert.Equal(t, 2, len(runs))
+	assert.Equal(t, []string{"a"}, runs[0].Awards[0].Winners)
+	assert.Equal(t, 101, runs[0].Awards[0].Amount)
+	assert.Equal(t, []string{"b"}, runs[1].Awards[0].Winners)
+	assert.Equal(t, 100, runs[1].Awards[0].Amount)
+
+	summary := &HandSummary{Hands: hands, Runs: runs}
+	won := summary.winnings([]string{"a", "b"})
+	assert.Equal(t, 101, won["a"])
+	assert.Equal(t, 100, won["b"])
+}
+
+func TestShowdownHiLo(t *testing.T) {
+	board := []deck.Card{
+		{Suit: deck.Clubs, Value: 1},
+		{Suit: deck.Diamonds, Value: 2},
+		{Suit: deck.Harts, Value: 7},
+		{Suit: deck.Spades, Value: 13},
+		{Suit: deck.Diamonds, Value: 12},
+	}
+	hands := map[string][]deck.Card{
+		// Trip kings and a 7-4-3-2-A low.
+		"a": {{Suit: deck.Spades, Value: 3}, {Suit: deck.Spades, Value: 4}, {Suit: deck.Harts, Value: 13}, {Suit: deck.Diamonds, Value: 13}},
+		// The same low without a high hand.
+		"b": {{Suit: deck.Harts, Value: 3}, {Suit: deck.Harts, Value: 4}, {Suit: deck.Clubs, Value: 9}, {Suit: deck.Clubs, Value: 10}},
+		// Trip queens and no qualifying low.
+		"c": {{Suit: deck.Spades, Value: 12}, {Suit: deck.Harts, Value: 12}, {Suit: deck.Diamonds, Value: 9}, {Suit: deck.Diamonds, Value: 8}},
+	}
+	pots := []pot{
+		{amount: 101, eligible: []string{"a", "b", "c"}},
+		{amount: 40, eligible: []string{"a", "c"}},
+	}
+
+	runs := showdown(OmahaHiLo, pots, [][]deck.Card{board}, hands)
+	awards := runs[0].Awards
+	assert.Equal(t, 4, len(awards))
+
+	// The odd chip goes to the high half and the low half is quartered.
+	assert.Equal(t, PotAward{Pot: 0, Half: HighHalf, Amount: 51, Winners: []string{"a"}, Hand: awards[0].Hand}, awards[0])
+	assert.Equal(t, LowHalf, awards[1].Half)
+	assert.Equal(t, 50, awards[1].Amount)
+	assert.Equal(t, []string{"a", "b"}, awards[1].Winners)
+	assert.Equal(t, 1, awards[2].Pot)
+	assert.Equal(t, []string{"a"}, awards[3].Winners)
+
+	summary := &HandSummary{Hands: hands, Runs: runs}
+	won := summary.winnings([]string{"a", "b", "c"})
+	assert.Equal(t, 116, won["a"])
+	assert.Equal(t, 25, won["b"])
+	assert.Equal(t, 0, won["c"])
+
+	// Without a qualifying low the high hand scoops.
+	delete(hands, "b")
+	hands["a"] = hands["c"]
+	runs = showdown(OmahaHiLo, []pot{{amount: 40, eligible: []string{"a", "c"}}}, [][]deck.Card{board}, hands)
+	assert.Equal(t, 1, len(runs[0].Awards))
+	assert.Equal(t, WholePot, runs[0].Awards[0].Half)
+}
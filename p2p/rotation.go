@@ -0,0 +1,212 @@
+package p2p
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultRotationHands is the number of hands of each game in a rotation when
+// neither the hands nor the duration are configured, one orbit at a full table.
+const defaultRotationHands = defaultMaxPlayers
+
+// Game is a variant played with a specific betting structure.
+type Game struct {
+	GameVariant      GameVariant
+	BettingStructure BettingStructure
+}
+
+func (gm Game) String() string {
+	return fmt.Sprintf("%s %s", gm.BettingStructure, gm.GameVariant)
+}
+
+// withDefaults fills in the parts of the betting structure that are not set.
+func (gm Game) withDefaults() Game {
+	if gm.BettingStructure == (BettingStructure{}) {
+		gm.BettingStructure = gm.GameVariant.defaultBettingStructure()
+	}
+	if gm.BettingStructure.BigBet == 0 {
+		gm.BettingStructure.BigBet = 2 * gm.BettingStructure.SmallBet
+	}
+	if gm.BettingStructure.BringIn == 0 && gm.GameVariant.isStud() {
+		gm.BettingStructure.BringIn = gm.BettingStructure.SmallBet / 2
+	}
+	return gm
+}
+
+// Rotation is the schedule of the games played at the table. A table that
+// plays a single game has a rotation of one game. Every player at the table
+// needs to play with the exact same rotation.
+type Rotation struct {
+	Games []Game
+	// Hands is the number of hands that are played of each game.
+	Hands int
+	// Duration rotates to the next game on a timer instead of after a number
+	// of hands. The next game starts with the first hand that is dealt after
+	// the time is up.
+	Duration time.Duration
+}
+
+func (r Rotation) String() string {
+	games := make([]string, len(r.Games))
+	for i, gm := range r.Games {
+		games[i] = gm.String()
+	}
+
+	every := fmt.Sprintf("%d hands", r.Hands)
+	if r.Duration > 0 {
+		every = r.Duration.String()
+	}
+
+	return fmt.Sprintf("[%s] every %s", strings.Join(games, ", "), every)
+}
+
+func (r Rotation) equal(other Rotation) bool {
+	if r.Hands != other.Hands || r.Duration != other.Duration || len(r.Games) != len(other.Games) {
+		return false
+	}
+	for i := range r.Games {
+		if r.Games[i] != other.Games[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// maxPlayers returns the number of seats every game of the rotation can be
+// played with, zero means there is no limit.
+func (r Rotation) maxPlayers() int {
+	max := 0
+	for _, gm := range r.Games {
+		if n := gm.GameVariant.maxPlayers(); n > 0 && (max == 0 || n < max) {
+			max = n
+		}
+	}
+	return max
+}
+
+func (r Rotation) withDefaults() Rotation {
+	games := make([]Game, len(r.Games))
+	for i, gm := range r.Games {
+		games[i] = gm.withDefaults()
+	}
+	r.Games = games
+	if len(r.Games) > 1 && r.Hands == 0 && r.Duration == 0 {
+		r.Hands = defaultRotationHands
+	}
+	return r
+}
+
+// HORSE rotates limit hold'em, omaha hi-lo, razz, seven card stud and seven
+// card stud hi-lo every given number of hands.
+func HORSE(hands int) Rotation {
+	return Rotation{
+		Games: []Game{
+			{GameVariant: TexasHoldem, BettingStructure: BettingStructure{Limit: FixedLimit, SmallBet: defaultSmallBet}},
+			{GameVariant: OmahaHiLo},
+			{GameVariant: Razz},
+			{GameVariant: SevenCardStud},
+			{GameVariant: SevenCardStudHiLo},
+		},
+		Hands: hands,
+	}
+}
+
+// EightGame rotates 2-7 triple draw, the HORSE games, no limit hold'em and pot
+// limit omaha every given number of hands.
+func EightGame(hands int) Rotation {
+	return Rotation{
+		Games: []Game{
+			{GameVariant: DeuceToSevenTripleDraw},
+			{GameVariant: TexasHoldem, BettingStructure: BettingStructure{Limit: FixedLimit, SmallBet: defaultSmallBet}},
+			{GameVariant: OmahaHiLo},
+			{GameVariant: Razz},
+			{GameVariant: SevenCardStud},
+			{GameVariant: SevenCardStudHiLo},
+			{GameVariant: TexasHoldem},
+			{GameVariant: Omaha},
+		},
+		Hands: hands,
+	}
+}
+
+// ParseRotation returns the rotation with the given name, horse or 8-game,
+// played every given number of hands.
+func ParseRotation(name string, hands int) (Rotation, error) {
+	switch normalizeName(name) {
+	case "horse":
+		return HORSE(hands), nil
+	case "8-game", "eight-game":
+		return EightGame(hands), nil
+	default:
+		return Rotation{}, fmt.Errorf("unknown rotation (%s)", name)
+	}
+}
+
+func (g *GameState) game() Game {
+	return g.rotation.Games[g.currentGame.Get()]
+}
+
+// variant returns the variant of the game that is currently played.
+func (g *GameState) variant() GameVariant {
+	return g.game().GameVariant
+}
+
+// structure returns the betting structure of the game that is currently
+// played. In a tournament the blinds are those of the current level.
+func (g *GameState) structure() BettingStructure {
+	bs := g.game().BettingStructure
+	if level, ok := g.blindLevel(); ok {
+		bs = level.apply(bs)
+	}
+	return bs
+}
+
+// nextGame returns the game the next hand is played with. Only the dealer
+// decides when to rotate, the other players follow the game he starts the
+// hand with. That way every player switches at the same hand, even when the
+// rotation is on a timer.
+func (g *GameState) nextGame() int {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	current := int(g.currentGame.Get())
+	if len(g.rotation.Games) == 1 {
+		return current
+	}
+
+	rotate := g.rotation.Hands > 0 && g.handsInGame >= g.rotation.Hands
+	if g.rotation.Duration > 0 {
+		rotate = time.Since(g.gameStarted) >= g.rotation.Duration
+	}
+	if rotate {
+		return (current + 1) % len(g.rotation.Games)
+	}
+	return current
+}
+
+// switchGame starts a new hand of the given game.
+func (g *GameState) switchGame(game int) error {
+	if game < 0 || game >= len(g.rotation.Games) {
+		return fmt.Errorf("game (%d) is not in the rotation", game)
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if int(g.currentGame.Get()) != game || g.gameStarted.IsZero() {
+		g.currentGame.Set(int32(game))
+		g.handsInGame = 0
+		g.gameStarted = time.Now()
+
+		logrus.WithFields(logrus.Fields{
+			"we":   g.listenAddr,
+			"game": g.rotation.Games[game],
+		}).Info("rotating to the next game")
+	}
+	g.handsInGame++
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotationEqual(t *testing.T) {
+	assert.True(t, HORSE(8).equal(HORSE(8)))
+	assert.False(t, HORSE(8).equal(HORSE(6)))
+	assert.False(t, HORSE(8).equal(EightGame(8)))
+	assert.Equal(t, defaultRotationHands, Rotation{Games: HORSE(0).Games}.withDefaults().Hands)
+}
+
+func TestRotationNextGame(t *testing.T) {
+	g := &GameState{
+		rotation:    HORSE(2).withDefaults(),
+		currentGame: NewAtomicInt(0),
+	}
+
+	for hand := 0; hand < 2; hand++ {
+		assert.Nil(t, g.switchGame(g.nextGame()))
+		assert.Equal(t, TexasHoldem, g.variant())
+		assert.Equal(t, FixedLimit, g.structure().Limit)
+	}
+	assert.Nil(t, g.switchGame(g.nextGame()))
+	assert.Equal(t, OmahaHiLo, g.variant())
+
+	// After the last game the rotation starts over.
+	g.currentGame.Set(4)
+	g.handsInGame = 2
+	assert.Equal(t, 0, g.nextGame())
+	assert.NotNil(t, g.switchGame(5))
+
+	// On a timer the game only changes once the time is up.
+	g.rotation.Duration = time.Hour
+	assert.Nil(t, g.switchGame(1))
+	assert.Equal(t, 1, g.nextGame())
+	g.gameStarted = time.Now().Add(-2 * time.Hour)
+	assert.Equal(t, 2, g.nextGame())
+}
+
+func TestParseNames(t *testing.T) {
+	gv, err := ParseGameVariant("2-7-triple-draw")
+	assert.Nil(t, err)
+	assert.Equal(t, DeuceToSevenTripleDraw, gv)
+
+	gv, err = ParseGameVariant("Seven_Card_Stud_Hi-Lo")
+	assert.Nil(t, err)
+	assert.Equal(t, SevenCardStudHiLo, gv)
+
+	_, err = ParseGameVariant("go fish")
+	assert.NotNil(t, err)
+
+	limit, err := ParseLimit("pot-limit")
+	assert.Nil(t, err)
+	assert.Equal(t, PotLimit, limit)
+
+	r, err := ParseRotation("HORSE", 6)
+	assert.Nil(t, err)
+	assert.True(t, r.equal(HORSE(6)))
+}
@@ -0,0 +1,161 @@
+package p2p
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// reservationTick is how often expired seat reservations are released.
+const reservationTick = time.Second
+
+// TakeSeat reserves the given seat for us until we sit down with SetReady.
+// When the table is configured with a SeatReservation time the reservation
+// expires if we do not sit down in time.
+func (g *GameState) TakeSeat(seat int) error {
+	if seat < 0 || seat >= g.table.maxSeats {
+		return newGameError(ErrCodeInvalidRequest, "seat (%d) does not exist, the table has %d seats", seat, g.table.maxSeats)
+	}
+	if player, err := g.table.GetPlayer(g.listenAddr); err == nil {
+		return newGameError(ErrCodeNotAllowed, "already sitting on seat (%d)", player.tablePos)
+	}
+	if holder, ok := g.table.seatHolder(seat); ok && holder != g.listenAddr {
+		return newGameError(ErrCodeNotAllowed, "seat (%d) is taken by (%s)", seat, holder)
+	}
+
+	g.claimSeat(g.listenAddr, seat, true)
+	g.sendToPlayers(MessageTakeSeat{Seat: seat}, g.getOtherPlayers()...)
+
+	return nil
+}
+
+func (g *GameState) handleTakeSeat(from string, msg MessageTakeSeat) error {
+	if msg.Stack > 0 {
+		return g.handleArrival(from, msg)
+	}
+	g.claimSeat(from, msg.Seat, true)
+	return nil
+}
+
+// takeSeat seats us at the table. We keep our seat between the hands, sit
+// down on the seat we reserved or take the first free seat.
+func (g *GameState) takeSeat() (int, error) {
+	if player, err := g.table.GetPlayer(g.listenAddr); err == nil {
+		return player.tablePos, nil
+	}
+
+	seat, ok := g.table.reservationOf(g.listenAddr)
+	if !ok {
+		var err error
+		if seat, err = g.table.NextFreeSeat(); err != nil {
+			return 0, err
+		}
+	}
+	g.table.AddPlayerOnPosition(g.listenAddr, seat)
+
+	return seat, nil
+}
+
+// claimSeat seats the player on the seat he announced, or reserves it for him.
+// When two players claim the same seat, every player agrees that the lowest
+// address gets it, no matter in which order the claims arrive. When we lose
+// the seat we were sitting on we take another one and announce it, a lost
+// reservation is released and we have to take another seat ourselves.
+func (g *GameState) claimSeat(addr string, seat int, reserve bool) {
+	if seat < 0 || seat >= g.table.maxSeats {
+		logrus.Errorf("player (%s) claimed invalid seat (%d)", addr, seat)
+		return
+	}
+
+	player, err := g.table.GetPlayer(addr)
+	if err == nil && (reserve || player.tablePos == seat) {
+		// A player that sits at the table does not reserve seats.
+		return
+	}
+
+	holder, ok := g.table.seatHolder(seat)
+	if ok && holder < addr {
+		return
+	}
+
+	lost := ok && holder != addr
+	_, err = g.table.GetPlayer(holder)
+	wasSitting := err == nil
+
+	g.table.release(addr)
+	if lost {
+		g.table.release(holder)
+	}
+	if reserve {
+		g.table.Reserve(addr, seat, g.reservationExpiry())
+		g.publish(EventSeatReserved, SeatEvent{Addr: addr, Seat: seat})
+	} else {
+		g.table.AddPlayerOnPosition(addr, seat)
+	}
+
+	if lost {
+		g.loseSeat(holder, seat, wasSitting)
+	}
+}
+
+// loseSeat is called when the player lost his seat to a player with a lower
+// address.
+func (g *GameState) loseSeat(addr string, seat int, wasSitting bool) {
+	g.publish(EventSeatReleased, SeatEvent{Addr: addr, Seat: seat})
+
+	if addr != g.listenAddr || !wasSitting {
+		return
+	}
+
+	newSeat, err := g.takeSeat()
+	if err != nil {
+		logrus.Errorf("%s: %s", g.listenAddr, err)
+		return
+	}
+	g.sendToPlayers(MessageReady{Seat: newSeat}, g.getOtherPlayers()...)
+}
+
+// applySeats seats the players of a table we just joined and holds the seats
+// they reserved.
+func (g *GameState) applySeats(seats, reservations map[string]int) {
+	for addr, seat := range seats {
+		if addr == g.listenAddr {
+			continue
+		}
+		g.claimSeat(addr, seat, false)
+	}
+	for addr, seat := range reservations {
+		if addr == g.listenAddr {
+			continue
+		}
+		g.claimSeat(addr, seat, true)
+	}
+}
+
+// reservationExpiry returns when a reservation made now expires.
+func (g *GameState) reservationExpiry() time.Time {
+	if g.options.SeatReservation == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(g.options.SeatReservation)
+}
+
+// reservationLoop releases the reservations of the players that did not sit
+// down in time. Every player expires the reservations on his own, they all
+// heard about the reservation at about the same time.
+func (g *GameState) reservationLoop() {
+	ticker := time.NewTicker(reservationTick)
+	defer ticker.Stop()
+
+	for {
+		var now time.Time
+		select {
+		case now = <-ticker.C:
+		case <-g.quit:
+			return
+		}
+		for addr, seat := range g.table.expireReservations(now) {
+			g.publish(EventSeatReleased, SeatEvent{Addr: addr, Seat: seat})
+		}
+	}
+}
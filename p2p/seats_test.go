@@ -0,0 +1,113 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeatConflict(t *testing.T) {
+	broadcastch := make(chan BroadcastTo, 10)
+	cfg := ServerConfig{
+		AdvertiseAddr: "b.example.com:3000",
+		TableOptions:  TableOptions{StartingStack: 100},
+	}
+	g := NewGame(cfg, broadcastch)
+	g.AddPlayer("a.example.com:3000")
+	g.AddPlayer("c.example.com:3000")
+
+	assert.Nil(t, g.SetReady())
+	msg := <-broadcastch
+	assert.Equal(t, MessageReady{Seat: 0, BuyIn: 100}, msg.Payload)
+
+	// c announces our seat, we have the lower address and keep it.
+	g.SetPlayerReady("c.example.com:3000", 0, 0)
+	assert.Equal(t, map[string]int{"b.example.com:3000": 0}, g.table.Seats())
+	g.SetPlayerReady("c.example.com:3000", 1, 0)
+
+	// a has the lower address, we move to the next free seat and tell
+	// the others.
+	g.SetPlayerReady("a.example.com:3000", 0, 0)
+	msg = <-broadcastch
+	assert.Equal(t, MessageReady{Seat: 2}, msg.Payload)
+	assert.Equal(t, map[string]int{
+		"a.example.com:3000": 0,
+		"b.example.com:3000": 2,
+		"c.example.com:3000": 1,
+	}, g.table.Seats())
+
+	// The button is on the first seat, whatever the addresses are.
+	dealer, isDealer := g.getCurrentDealerAddr()
+	assert.Equal(t, "a.example.com:3000", dealer)
+	assert.False(t, isDealer)
+}
+
+func TestTakeSeat(t *testing.T) {
+	broadcastch := make(chan BroadcastTo, 10)
+	cfg := ServerConfig{
+		AdvertiseAddr: "b.example.com:3000",
+		TableOptions:  TableOptions{StartingStack: 100},
+	}
+	g := NewGame(cfg, broadcastch)
+	g.AddPlayer("a.example.com:3000")
+
+	assert.Equal(t, ErrCodeInvalidRequest, g.TakeSeat(6).(*GameError).Code)
+	assert.Nil(t, g.TakeSeat(3))
+	msg := <-broadcastch
+	assert.Equal(t, MessageTakeSeat{Seat: 3}, msg.Payload)
+	assert.Equal(t, map[string]int{"b.example.com:3000": 3}, g.table.Reservations())
+
+	// Nobody gets the seat before us, we sit down on it when we are ready.
+	assert.Nil(t, g.handleTakeSeat("c.example.com:3000", MessageTakeSeat{Seat: 3}))
+	seat, err := g.table.NextFreeSeat()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, seat)
+	assert.Nil(t, g.SetReady())
+	msg = <-broadcastch
+	assert.Equal(t, MessageReady{Seat: 3, BuyIn: 100}, msg.Payload)
+	assert.Empty(t, g.table.Reservations())
+	assert.Equal(t, ErrCodeNotAllowed, g.TakeSeat(4).(*GameError).Code)
+}
+
+func TestSeatReservationConflict(t *testing.T) {
+	// Every player ends up with the same seats, whatever the order of the
+	// claims is.
+	for _, order := range [][]string{
+		{"c.example.com:3000", "a.example.com:3000"},
+		{"a.example.com:3000", "c.example.com:3000"},
+	} {
+		g := NewGame(ServerConfig{AdvertiseAddr: "b.example.com:3000"}, make(chan BroadcastTo, 10))
+		for _, addr := range order {
+			assert.Nil(t, g.handleTakeSeat(addr, MessageTakeSeat{Seat: 1}))
+		}
+		assert.Equal(t, map[string]int{"a.example.com:3000": 1}, g.table.Reservations())
+	}
+
+	// A player that sat down keeps the seat when a player with a higher
+	// address reserved it in the meantime.
+	g := NewGame(ServerConfig{AdvertiseAddr: "b.example.com:3000"}, make(chan BroadcastTo, 10))
+	g.SetPlayerReady("a.example.com:3000", 1, 0)
+	assert.Nil(t, g.handleTakeSeat("c.example.com:3000", MessageTakeSeat{Seat: 1}))
+	assert.Equal(t, map[string]int{"a.example.com:3000": 1}, g.table.Seats())
+	assert.Empty(t, g.table.Reservations())
+}
+
+func TestSeatReservationExpires(t *testing.T) {
+	cfg := ServerConfig{
+		AdvertiseAddr: "b.example.com:3000",
+		TableOptions:  TableOptions{SeatReservation: time.Minute},
+		Rotation:      Rotation{Games: []Game{{GameVariant: TexasHoldem}}}.withDefaults(),
+	}
+	g := NewGame(cfg, make(chan BroadcastTo, 10))
+	assert.Nil(t, g.handleTakeSeat("a.example.com:3000", MessageTakeSeat{Seat: 2}))
+
+	state := g.State()
+	assert.Equal(t, 1, len(state.Seats))
+	assert.Equal(t, seatStatusReserved, state.Seats[0].Status)
+	assert.InDelta(t, time.Minute.Milliseconds(), state.Seats[0].ReservedFor, 1000)
+
+	assert.Empty(t, g.table.expireReservations(time.Now()))
+	assert.Equal(t, map[string]int{"a.example.com:3000": 2}, g.table.expireReservations(time.Now().Add(time.Minute)))
+	assert.Empty(t, g.table.Reservations())
+}
@@ -3,6 +3,7 @@ package p2p
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"net"
 	"sync"
@@ -11,32 +12,69 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-const defaultMaxPlayers = 6
-
-type GameVariant uint8
-
-func (gv GameVariant) String() string {
-	switch gv {
-	case TexasHoldem:
-		return "TEXAS HOLDEM"
-	case Other:
-		return "other"
-	default:
-		return "unknown"
-	}
-}
-
 const (
-	TexasHoldem GameVariant = iota
-	Other
+	defaultMaxPlayers = 6
+	// defaultStartingBlinds is the default starting stack in big blinds.
+	defaultStartingBlinds = 100
 )
 
+var errMaxPeers = errors.New("max peers exceeded")
+
 type ServerConfig struct {
-	Version       string
-	ListenAddr    string
+	Version    string
+	ListenAddr string
+	// AdvertiseAddr is the address the other players dial us on and know us
+	// by, like poker.example.com:3000 or [2001:db8::1]:3000. It defaults to
+	// the ListenAddr, which is only enough when every player runs on the
+	// same machine.
+	AdvertiseAddr string
 	APIListenAddr string
-	GameVariant   GameVariant
-	MaxPlayers    int
+	// APIToken authenticates the requests to the API. A random token is
+	// generated when it is not set.
+	APIToken    string
+	GameVariant GameVariant
+	// BettingStructure defaults to the structure the game variant is usually
+	// played with.
+	BettingStructure BettingStructure
+	// Rotation is the schedule of a mixed game table, like HORSE. When it is
+	// set the GameVariant and BettingStructure are not used.
+	Rotation     Rotation
+	TableOptions TableOptions
+	// MaxPlayers is the number of seats at the table. A table that plays a
+	// draw game has at most as many seats as there are cards left after the
+	// deal to replace every hand once, 5 for 2-7 triple draw, since the
+	// discards are not reshuffled.
+	MaxPlayers int
+	// BootstrapPeers are the addresses of players at the table we connect to
+	// when we start, the other players are discovered through them.
+	BootstrapPeers []string
+	// MaxPeers is the maximum number of players we are connected to, it
+	// defaults to the other seats at the table.
+	MaxPeers int
+	// Tournament plays a sit-and-go instead of a cash game when it is set.
+	Tournament *Tournament
+	// Coordinator keeps the table in sync with the other tables of a
+	// multi-table tournament, CoordinatorTable is the number of the table the
+	// coordinator seated us at. The Tournament needs to be set as well. A
+	// node that is started with a Coordinator registers for the tournament
+	// and waits at its first table until it is seated, it plays at the
+	// tables the coordinator seats it at with the ID <TableID>-<table>.
+	Coordinator      TableCoordinator
+	CoordinatorTable int
+	// TableID identifies the table, every player at the table needs to use
+	// the same ID. It defaults to DefaultTableID.
+	TableID string
+	// Tables are the other tables the node plays at. Only the settings of
+	// the table are used, like the Rotation, TableOptions and BootstrapPeers,
+	// the node keeps its own addresses and version.
+	Tables []ServerConfig
+	// LobbyAddr is the address of the lobby we announce our tables to, like
+	// lobby.example.com:3100. No tables are announced when it is empty.
+	LobbyAddr string
+	// LobbyListenAddr serves a lobby that is embedded in the node, it lists
+	// our tables and the tables other nodes announce to it, and can create
+	// and join tables for us.
+	LobbyListenAddr string
 }
 
 type Server struct {
@@ -50,14 +88,113 @@ type Server struct {
 	msgCh       chan *Message
 	broadcastch chan BroadcastTo
 
-	// gameState *GameState
+	tableLock sync.RWMutex
+	tables    map[string]*tableServer
+	// gameState is the game of the first table of the node. When we leave it
+	// the table with the lowest ID becomes the first table.
 	gameState *GameState
+	// lobby is the embedded lobby, nil when LobbyListenAddr is not set.
+	lobby *Lobby
 }
 
-func NewServer(cfg ServerConfig) *Server {
+// playerAddr returns the address the player is known by at the table.
+func (cfg ServerConfig) playerAddr() string {
+	addr := cfg.AdvertiseAddr
+	if addr == "" {
+		addr = cfg.ListenAddr
+	}
+	if normalized, err := normalizeAddr(addr); err == nil {
+		return normalized
+	}
+	return addr
+}
+
+// Validate checks the settings of the table and of the other tables. The
+// settings that are not set do not need to be valid, they get defaults.
+func (cfg ServerConfig) Validate() error {
+	if cfg.Tournament != nil {
+		if err := ValidatePayouts(cfg.Tournament.Payouts); err != nil {
+			return err
+		}
+	}
+	for _, tc := range cfg.Tables {
+		if err := tc.Validate(); err != nil {
+			return fmt.Errorf("table (%s): %w", tc.TableID, err)
+		}
+	}
+	return nil
+}
+
+// Seats returns the number of seats at the table, a draw game is played with
+// fewer seats than configured.
+func (cfg ServerConfig) Seats() int {
+	return cfg.withDefaults().MaxPlayers
+}
+
+// withDefaults fills in the settings of the table that are not set.
+func (cfg ServerConfig) withDefaults() ServerConfig {
+	if cfg.TableID == "" {
+		cfg.TableID = DefaultTableID
+	}
+	cfg.AdvertiseAddr = cfg.playerAddr()
+	if len(cfg.Rotation.Games) == 0 {
+		cfg.Rotation = Rotation{
+			Games: []Game{{
+				GameVariant:      cfg.GameVariant,
+				BettingStructure: cfg.BettingStructure,
+			}},
+		}
+	}
+	cfg.Rotation = cfg.Rotation.withDefaults()
 	if cfg.MaxPlayers == 0 {
 		cfg.MaxPlayers = defaultMaxPlayers
 	}
+	if max := cfg.Rotation.maxPlayers(); max > 0 && cfg.MaxPlayers > max {
+		logrus.WithFields(logrus.Fields{
+			"table":      cfg.TableID,
+			"maxPlayers": max,
+		}).Warn("the draw games of the table are played with fewer seats")
+		cfg.MaxPlayers = max
+	}
+	if cfg.MaxPeers == 0 {
+		cfg.MaxPeers = cfg.MaxPlayers - 1
+	}
+	bigBlind := cfg.Rotation.Games[0].BettingStructure.bigBlind()
+	if cfg.TableOptions.StartingStack == 0 {
+		cfg.TableOptions.StartingStack = defaultStartingBlinds * bigBlind
+	}
+	if cfg.TableOptions.MaxBuyIn == 0 {
+		cfg.TableOptions.MaxBuyIn = cfg.TableOptions.StartingStack
+	}
+	if cfg.TableOptions.MinBuyIn == 0 {
+		cfg.TableOptions.MinBuyIn = defaultMinBuyInBlinds * bigBlind
+	}
+	if cfg.Tournament != nil {
+		// Every player buys in with the same amount in a tournament.
+		t := cfg.Tournament.withDefaults(bigBlind, cfg.MaxPlayers)
+		cfg.Tournament = &t
+		cfg.TableOptions.StartingStack = t.StartingStack
+		cfg.TableOptions.MinBuyIn = t.BuyIn
+		cfg.TableOptions.MaxBuyIn = t.BuyIn
+	}
+	if cfg.TableOptions.MaxSitOutOrbits == 0 {
+		cfg.TableOptions.MaxSitOutOrbits = defaultMaxSitOutOrbits
+	}
+	if cfg.TableOptions.MinBuyIn > cfg.TableOptions.MaxBuyIn {
+		cfg.TableOptions.MinBuyIn = cfg.TableOptions.MaxBuyIn
+	}
+	return cfg
+}
+
+func NewServer(cfg ServerConfig) *Server {
+	cfg = cfg.withDefaults()
+	if cfg.APIToken == "" {
+		token, err := NewAPIToken()
+		if err != nil {
+			panic(err)
+		}
+		cfg.APIToken = token
+	}
 
 	s := &Server{
 		ServerConfig: cfg,
@@ -66,9 +203,30 @@ func NewServer(cfg ServerConfig) *Server {
 		delPeer:      make(chan *Peer),
 		msgCh:        make(chan *Message, 100),
 		broadcastch:  make(chan BroadcastTo, 100),
+		tables:       make(map[string]*tableServer),
+	}
+	if cfg.Coordinator != nil {
+		// We wait at our first table until the coordinator seats us at a
+		// table of the tournament.
+		cfg.CoordinatorTable = waitingTable
+	}
+	t := newTableServer(s, cfg)
+	s.tables[cfg.TableID] = t
+	s.gameState = t.gameState
+	for _, tc := range cfg.Tables {
+		if _, err := s.AddTable(tc); err != nil {
+			logrus.Errorf("cannot add table: %s", err)
+		}
+	}
+
+	if s.LobbyListenAddr != "" {
+		s.lobby = NewLobby(s.APIToken, s)
+		go func() {
+			if err := s.lobby.Run(s.LobbyListenAddr); err != nil {
+				logrus.Errorf("lobby error: %s", err)
+			}
+		}()
 	}
-	// s.gameState = NewGameState(s.ListenAddr, s.broadcastch)
-	s.gameState = NewGame(s.ListenAddr, s.broadcastch)
 
 	// if s.ListenAddr == ":3000" {
 	// 	s.gameState.isDealer = true // just for testing!
@@ -78,13 +236,16 @@ func NewServer(cfg ServerConfig) *Server {
 	s.transport = tr
 
 	tr.AddPeer = s.addPeer
-	tr.DelPeer = s.addPeer
+	tr.DelPeer = s.delPeer
 
 	go func(s *Server) {
-		apiServer := NewAPIServer(cfg.APIListenAddr, s.gameState)
+		apiServer := NewAPIServer(s.APIListenAddr, s.APIToken, s.gameState)
+		apiServer.game = s.firstTable
+		apiServer.tables = s.Tables
 
 		logrus.WithFields(logrus.Fields{
-			"listenAddr": cfg.APIListenAddr,
+			"listenAddr": s.APIListenAddr,
+			"token":      s.APIToken,
 		}).Info("starting API server")
 
 		apiServer.Run()
@@ -96,39 +257,61 @@ func NewServer(cfg ServerConfig) *Server {
 
 func (s *Server) Start() {
 	go s.loop()
+	go s.discoveryLoop()
+	if s.lobby != nil || s.LobbyAddr != "" {
+		go s.announceLoop()
+	}
+	if s.Coordinator != nil {
+		go s.coordinatorLoop()
+	}
 
-	logrus.WithFields(logrus.Fields{
-		"port":       s.ListenAddr,
-		"variant":    s.GameVariant,
-		"maxPlayers": s.MaxPlayers,
-	}).Info("started new game server")
+	for _, t := range s.tableList() {
+		logrus.WithFields(logrus.Fields{
+			"port":       s.ListenAddr,
+			"table":      t.TableID,
+			"rotation":   t.Rotation,
+			"tournament": t.Tournament,
+			"maxPlayers": t.MaxPlayers,
+		}).Info("started new game server")
+	}
 
-	s.transport.ListenAndAccept()
+	if err := s.transport.ListenAndAccept(); err != nil {
+		logrus.Errorf("listen error: %s", err)
+	}
 }
 
+// sendPeerList sends the new player the other players we are connected to,
+// of every table we play at together.
 func (s *Server) sendPeerList(p *Peer) error {
-	peerList := MessagePeerList{
-		Peers: []string{},
-	}
-
-	peers := s.Peers()
-	for i := 0; i < len(peers); i++ {
-		if peers[i] != p.listenAddr {
-			peerList.Peers = append(peerList.Peers, peers[i])
+	for _, t := range s.tableList() {
+		if !t.hasPlayer(p.listenAddr) {
+			continue
 		}
-	}
 
-	if len(peerList.Peers) == 0 {
-		return nil
-	}
+		peerList := MessagePeerList{
+			Peers: []string{},
+		}
+		for _, addr := range t.peers() {
+			if addr != p.listenAddr {
+				peerList.Peers = append(peerList.Peers, addr)
+			}
+		}
+		if len(peerList.Peers) == 0 {
+			continue
+		}
 
-	msg := NewMessage(s.ListenAddr, peerList)
-	buf := new(bytes.Buffer)
-	if err := gob.NewEncoder(buf).Encode(msg); err != nil {
-		return err
+		msg := NewMessage(s.AdvertiseAddr, peerList)
+		msg.Table = t.TableID
+		buf := new(bytes.Buffer)
+		if err := gob.NewEncoder(buf).Encode(msg); err != nil {
+			return err
+		}
+		if err := p.Send(buf.Bytes()); err != nil {
+			return err
+		}
 	}
 
-	return p.Send(buf.Bytes())
+	return nil
 }
 
 func (s *Server) AddPeer(p *Peer) {
@@ -138,6 +321,13 @@ func (s *Server) AddPeer(p *Peer) {
 	s.peers[p.listenAddr] = p
 }
 
+func (s *Server) peerCount() int {
+	s.peerLock.RLock()
+	defer s.peerLock.RUnlock()
+
+	return len(s.peers)
+}
+
 func (s *Server) Peers() []string {
 	s.peerLock.RLock()
 	defer s.peerLock.RUnlock()
@@ -154,10 +344,12 @@ func (s *Server) Peers() []string {
 
 func (s *Server) SendHandshake(p *Peer) error {
 	hs := &Handshake{
-		GameVariant: s.GameVariant,
-		Version:     s.Version,
-		GameStatus:  GameStatus(s.gameState.currentStatus.Get()),
-		ListenAddr:  s.ListenAddr,
+		Version:    s.Version,
+		ListenAddr: s.AdvertiseAddr,
+		Tables:     []TableHandshake{},
+	}
+	for _, t := range s.tableList() {
+		hs.Tables = append(hs.Tables, t.handshake())
 	}
 
 	buf := new(bytes.Buffer)
@@ -181,6 +373,21 @@ func (s *Server) isInPeerList(addr string) bool {
 // TODO(@anthdm): Right now we have some redundent code in registering new peers to the game network.
 // maybe construct a new peer and handshake protocol after registering a plain connection?
 func (s *Server) Connect(addr string) error {
+	addr, err := normalizeAddr(addr)
+	if err != nil {
+		return err
+	}
+
+	// Remember the player at our first table, so we connect again when the
+	// connection drops.
+	if t, ok := s.table(s.firstTable().ID()); ok {
+		t.book.add(addr)
+	}
+
+	return s.connect(addr)
+}
+
+func (s *Server) connect(addr string) error {
 	if s.isInPeerList(addr) {
 		return nil
 	}
@@ -193,6 +400,7 @@ func (s *Server) Connect(addr string) error {
 	peer := &Peer{
 		conn:     conn,
 		outbound: true,
+		dialAddr: addr,
 	}
 
 	s.addPeer <- peer
@@ -211,18 +419,29 @@ func (s *Server) loop() {
 			}()
 
 		case peer := <-s.delPeer:
+			if !s.removePeer(peer) {
+				continue
+			}
+
 			logrus.WithFields(logrus.Fields{
-				"addr": peer.conn.RemoteAddr(),
-			}).Info("new player disconnected")
+				"addr": peer.listenAddr,
+			}).Info("player disconnected")
 
-			delete(s.peers, peer.conn.RemoteAddr().String())
+			for _, t := range s.tableList() {
+				if t.hasPlayer(peer.listenAddr) {
+					t.gameState.RemovePlayer(peer.listenAddr)
+				}
+			}
 
 			// If a new peer connects to the server we send our handshake message and wait
-			// for his reply.
+			// for his reply. The handshake runs on its own, so a slow player cannot
+			// hold up the other connections.
 		case peer := <-s.addPeer:
-			if err := s.handleNewPeer(peer); err != nil {
-				logrus.Errorf("handle peer error: %s", err)
-			}
+			go func() {
+				if err := s.handleNewPeer(peer); err != nil {
+					logrus.Errorf("handle peer error: %s", err)
+				}
+			}()
 
 		case msg := <-s.msgCh:
 			go func() {
@@ -235,24 +454,44 @@ func (s *Server) loop() {
 }
 
 func (s *Server) handleNewPeer(peer *Peer) error {
-	_, err := s.handshake(peer)
+	hs, err := s.handshake(peer)
 	if err != nil {
 		peer.conn.Close()
-		delete(s.peers, peer.conn.RemoteAddr().String())
 
-		return fmt.Errorf("%s:handshake with incoming player failed: %s ", s.ListenAddr, err)
+		return fmt.Errorf("%s:handshake with incoming player failed: %s ", s.AdvertiseAddr, err)
 	}
 
-	// NOTE: this readLoop always needs to start after the handshake!
-	go peer.ReadLoop(s.msgCh)
-
 	if !peer.outbound {
 		if err := s.SendHandshake(peer); err != nil {
 			peer.conn.Close()
-			delete(s.peers, peer.conn.RemoteAddr().String())
 
 			return fmt.Errorf("failed to send handshake with peer: %s", err)
 		}
+	}
+
+	// We know the player by the address it advertises from now on, so the
+	// address we dialed is not dialed again.
+	if peer.outbound && peer.dialAddr != peer.listenAddr {
+		for _, t := range s.tableList() {
+			t.book.rename(peer.dialAddr, peer.listenAddr)
+		}
+	}
+
+	// Two players that dial each other at the same time end up with two
+	// connections, only one of them is kept.
+	old, isNew := s.registerPeer(peer)
+	if old == peer {
+		peer.conn.Close()
+		return nil
+	}
+	if old != nil {
+		old.conn.Close()
+	}
+
+	// NOTE: this readLoop always needs to start after the handshake!
+	go peer.ReadLoop(s.msgCh, s.delPeer)
+
+	if !peer.outbound {
 
 		go func() {
 			if err := s.sendPeerList(peer); err != nil {
@@ -264,24 +503,80 @@ func (s *Server) handleNewPeer(peer *Peer) error {
 	logrus.WithFields(logrus.Fields{
 		"peer":       peer.conn.RemoteAddr(),
 		"listenAddr": peer.listenAddr,
-		"we":         s.ListenAddr,
+		"we":         s.AdvertiseAddr,
 	}).Info("handshake successfull: new player connected")
 
-	s.AddPeer(peer)
-
-	s.gameState.AddPlayer(peer.listenAddr)
+	if isNew {
+		for _, th := range hs.Tables {
+			if t, ok := s.table(th.ID); ok {
+				t.join(peer.listenAddr, th)
+			}
+		}
+		// Let the tables know about the new player right away instead of
+		// waiting for the next exchange.
+		s.gossipPeers()
+	}
 
 	return nil
 }
 
+// registerPeer adds the peer unless we are already connected to the player
+// with a connection we keep. It returns the connection that is dropped, which
+// is the given peer itself when it is a duplicate, and whether the player is
+// new to us.
+func (s *Server) registerPeer(peer *Peer) (*Peer, bool) {
+	s.peerLock.Lock()
+	defer s.peerLock.Unlock()
+
+	old, ok := s.peers[peer.listenAddr]
+	if ok && !s.keepNewConn(old, peer) {
+		return peer, false
+	}
+	s.peers[peer.listenAddr] = peer
+
+	return old, !ok
+}
+
+// keepNewConn decides which of two connections with the same player is kept.
+// Both players need to make the same choice, so the connection that is
+// dialed by the player with the lowest address wins. When both are dialed by
+// the same player the old one is replaced, it was dropped.
+func (s *Server) keepNewConn(old, peer *Peer) bool {
+	if old.outbound == peer.outbound {
+		return true
+	}
+	weDialNew := peer.outbound
+	weAreLowest := s.AdvertiseAddr < peer.listenAddr
+
+	return weDialNew == weAreLowest
+}
+
+// removePeer reports whether the peer was still connected, a connection that
+// was replaced by another one is not.
+func (s *Server) removePeer(peer *Peer) bool {
+	s.peerLock.Lock()
+	defer s.peerLock.Unlock()
+
+	if s.peers[peer.listenAddr] != peer {
+		return false
+	}
+	delete(s.peers, peer.listenAddr)
+
+	return true
+}
+
 func (s *Server) Broadcast(broadcastMsg BroadcastTo) error {
-	msg := NewMessage(s.ListenAddr, broadcastMsg.Payload)
+	msg := NewMessage(s.AdvertiseAddr, broadcastMsg.Payload)
+	msg.Table = broadcastMsg.Table
 
 	buf := new(bytes.Buffer)
 	if err := gob.NewEncoder(buf).Encode(msg); err != nil {
 		return err
 	}
 
+	s.peerLock.RLock()
+	defer s.peerLock.RUnlock()
+
 	for _, addr := range broadcastMsg.To {
 		peer, ok := s.peers[addr]
 
@@ -298,89 +593,81 @@ func (s *Server) Broadcast(broadcastMsg BroadcastTo) error {
 }
 
 func (s *Server) handshake(p *Peer) (*Handshake, error) {
-	if len(s.peers) > s.MaxPlayers {
-		return nil, fmt.Errorf("max players exceeded (%d)", s.MaxPlayers)
-	}
+	p.conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	defer p.conn.SetReadDeadline(time.Time{})
 
 	hs := &Handshake{}
-	if err := gob.NewDecoder(p.conn).Decode(hs); err != nil {
+	if err := p.decode(hs); err != nil {
 		return nil, err
 	}
 
-	if s.GameVariant != hs.GameVariant {
-		return nil, fmt.Errorf("gamevariant does not match %s", hs.GameVariant)
+	if !s.isInPeerList(hs.ListenAddr) && s.peerCount() >= s.maxPeers() {
+		return nil, errMaxPeers
 	}
+
 	if s.Version != hs.Version {
 		return nil, fmt.Errorf("invalid version %s", hs.Version)
 	}
+	// The player needs to play the same game at every table we have in
+	// common.
+	shared := 0
+	for _, th := range hs.Tables {
+		t, ok := s.table(th.ID)
+		if !ok {
+			continue
+		}
+		if err := t.check(th); err != nil {
+			return nil, err
+		}
+		shared++
+	}
+	if shared == 0 {
+		return nil, fmt.Errorf("player (%s) does not play at any of our tables", hs.ListenAddr)
+	}
 
-	p.listenAddr = hs.ListenAddr
+	addr, err := normalizeAddr(hs.ListenAddr)
+	if err != nil {
+		return nil, err
+	}
+	p.listenAddr = addr
 
 	return hs, nil
 }
 
 func (s *Server) handleMessage(msg *Message) error {
-	switch v := msg.Payload.(type) {
-	case MessagePreFlop:
-		return s.handleMsgPreFlop(msg.From)
-	case MessagePeerList:
-		return s.handlePeerList(v)
-	case MessageEncDeck:
-		return s.handleMsgEncDeck(msg.From, v)
-	case MessageReady:
-		return s.handleMsgReady(msg.From)
-	case MessagePlayerAction:
-		return s.handleGetMsgPlayerAction(msg.From, v)
-	}
-	return nil
-}
-
-func (s *Server) handleGetMsgPlayerAction(from string, msg MessagePlayerAction) error {
-	return s.gameState.handlePlayerAction(from, msg)
-}
-
-func (s *Server) handleMsgPreFlop(from string) error {
-	s.gameState.SetStatus(GameStatusPreFlop)
-
-	return nil
-}
-
-func (s *Server) handleMsgReady(from string) error {
-	s.gameState.SetPlayerReady(from)
-
-	return nil
-}
-
-func (s *Server) handleMsgEncDeck(from string, msg MessageEncDeck) error {
-	logrus.WithFields(logrus.Fields{
-		"we":   s.ListenAddr,
-		"from": from,
-	}) // .Info("recv env deck")
-
-	return s.gameState.ShuffleAndEncrypt(from, msg.Deck)
-}
-
-// TODO FIXME: (@anthdm) maybe goroutine??
-func (s *Server) handlePeerList(l MessagePeerList) error {
-	logrus.WithFields(logrus.Fields{
-		"we":   s.ListenAddr,
-		"list": l.Peers,
-	}) //.Info("received peerList message")
-
-	for i := 0; i < len(l.Peers); i++ {
-		if err := s.Connect(l.Peers[i]); err != nil {
-			logrus.Errorf("failed to dial peer: %s", err)
-			continue
+	t, ok := s.table(msg.Table)
+	if !ok {
+		if _, ok := msg.Payload.(MessageJoinTable); ok {
+			// The player joined a table we do not play at.
+			return nil
 		}
+		return fmt.Errorf("message of player (%s) for unknown table (%s)", msg.From, msg.Table)
 	}
 
-	return nil
+	return t.handleMessage(msg.From, msg.Payload)
 }
 
 func init() {
 	gob.Register(MessagePeerList{})
 	gob.Register(MessageEncDeck{})
 	gob.Register(MessageReady{})
+	gob.Register(MessageTakeSeat{})
+	gob.Register(MessageAddChips{})
+	gob.Register(MessageCashOut{})
+	gob.Register(MessageSitOut{})
+	gob.Register(MessageSitIn{})
+	gob.Register(MessageMovePlayers{})
+	gob.Register(MessageProposeDeal{})
+	gob.Register(MessageAcceptDeal{})
+	gob.Register(MessageDeclineDeal{})
+	gob.Register(MessageJoinTable{})
 	gob.Register(MessagePreFlop{})
 	gob.Register(MessagePlayerAction{})
+	gob.Register(MessageDecryptCard{})
+	gob.Register(MessagePublicCard{})
+	gob.Register(MessageDraw{})
+	gob.Register(MessageStraddle{})
+	gob.Register(MessageBombPotVote{})
+	gob.Register(MessageRunItTwice{})
+	gob.Register(MessageShowdown{})
 }
@@ -0,0 +1,293 @@
+package p2p
+
+import (
+	"fmt"
+
+	"github.com/anthdm/ggpoker/deck"
+	"github.com/sirupsen/logrus"
+)
+
+// maxRuns is the maximum number of times the board can be run.
+const maxRuns = 3
+
+// SetRunPreference sets the number of times we want to run the board when all
+// players are all-in before the river. The board is only run more than once
+// when every player in the hand agrees.
+func (g *GameState) SetRunPreference(runs int) error {
+	if runs < 1 || runs > maxRuns {
+		return newGameError(ErrCodeIllegalAmount, "the board can only be run 1 to %d times", maxRuns)
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.runPreference = runs
+
+	return nil
+}
+
+// startRunout is called when all players are all-in before the last street.
+// Every player in the hand tells the others how many times he wants to run it.
+func (g *GameState) startRunout() {
+	g.betting.nextStreet()
+	g.setCurrentTurn(-1)
+
+	g.lock.Lock()
+	g.runout = true
+	runs := g.runPreference
+	g.lock.Unlock()
+
+	if g.betting.isActive(g.listenAddr) {
+		g.sendToPlayers(MessageRunItTwice{Runs: runs}, g.getOtherPlayers()...)
+		g.addRunProposal(g.listenAddr, runs)
+		return
+	}
+
+	g.maybeDealRunout()
+}
+
+func (g *GameState) handleRunItTwice(from string, msg MessageRunItTwice) error {
+	if msg.Runs < 1 || msg.Runs > maxRuns {
+		return fmt.Errorf("player (%s) wants to run the board an invalid number of times (%d)", from, msg.Runs)
+	}
+	if !g.betting.isActive(from) {
+		return fmt.Errorf("player (%s) is not in the hand", from)
+	}
+
+	g.addRunProposal(from, msg.Runs)
+
+	return nil
+}
+
+func (g *GameState) addRunProposal(addr string, runs int) {
+	g.lock.Lock()
+	g.runProposals[addr] = runs
+	g.lock.Unlock()
+
+	g.maybeDealRunout()
+}
+
+// maybeDealRunout deals the rest of the cards once every player in the hand
+// told how many times he wants to run it. The lowest number wins, so the
+// board is only run more than once when everybody agrees. The extra boards
+// are dealt from the same encrypted deck, there is no reshuffle. Games
+// without a board are always run once.
+func (g *GameState) maybeDealRunout() {
+	active := g.betting.activePlayers()
+
+	g.lock.Lock()
+	if !g.runout || g.runsDealt {
+		g.lock.Unlock()
+		return
+	}
+
+	runs := maxRuns
+	for _, addr := range active {
+		proposal, ok := g.runProposals[addr]
+		if !ok {
+			g.lock.Unlock()
+			return
+		}
+		if proposal < runs {
+			runs = proposal
+		}
+	}
+	if !g.variant().hasBoard() {
+		runs = 1
+	}
+	g.runsDealt = true
+
+	own, public := []int{}, []int{}
+	before := len(g.layout.board)
+	for _, st := range g.remainingStreets() {
+		o, p := g.dealStreetLocked(st, active)
+		own = append(own, o...)
+		public = append(public, p...)
+	}
+	n := len(g.layout.board) - before
+	for i := 1; i < runs; i++ {
+		public = append(public, g.layout.dealRun(n)...)
+	}
+	g.lock.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"we":   g.listenAddr,
+		"runs": runs,
+	}).Info("players are all-in, running the board")
+
+	g.revealCards(own, public)
+	g.startShowdown()
+}
+
+// remainingStreets returns the streets that are not dealt yet.
+func (g *GameState) remainingStreets() []street {
+	streets := g.variant().streets()
+	status := GameStatus(g.currentStatus.Get())
+	for i, st := range streets {
+		if st.status == status {
+			return streets[i+1:]
+		}
+	}
+	return nil
+}
+
+func (g *GameState) startShowdown() {
+	g.setCurrentTurn(-1)
+	g.currentStatus.Set(int32(GameStatusShowdown))
+	g.publish(EventStreetAdvanced, StreetEvent{Status: GameStatusShowdown.String()})
+
+	g.maybeShowHand()
+	g.maybeFinishHand()
+}
+
+// maybeShowHand shows our hole cards to the other players if we are still in
+// the hand. When the last cards were dealt in a runout we wait until we can
+// read all of them.
+func (g *GameState) maybeShowHand() {
+	if GameStatus(g.currentStatus.Get()) != GameStatusShowdown || !g.betting.isActive(g.listenAddr) {
+		return
+	}
+
+	g.lock.Lock()
+	cards, complete := g.holeCardsLocked()
+	if _, ok := g.shownHands[g.listenAddr]; ok || !complete || len(cards) < g.variant().holeCards() {
+		g.lock.Unlock()
+		return
+	}
+	g.shownHands[g.listenAddr] = cards
+	g.lock.Unlock()
+
+	g.sendToPlayers(MessageShowdown{Cards: cards}, g.getOtherPlayers()...)
+	g.maybeFinishHand()
+}
+
+// NOTE: the shown cards are trusted, like the public cards the dealer sends.
+// The keys of the deck are never revealed, so nobody can check that the
+// cards a player shows are the ones he was dealt.
+func (g *GameState) handleShowdown(from string, msg MessageShowdown) error {
+	if !g.betting.isActive(from) {
+		return fmt.Errorf("player (%s) is not in the hand", from)
+	}
+	if len(msg.Cards) != g.variant().holeCards() {
+		return fmt.Errorf("player (%s) showed %d cards", from, len(msg.Cards))
+	}
+
+	g.lock.Lock()
+	g.shownHands[from] = msg.Cards
+	g.lock.Unlock()
+
+	g.maybeFinishHand()
+
+	return nil
+}
+
+// maybeFinishHand divides the pots once every player in the hand has shown
+// his cards and all the public cards are revealed. The face up cards of a
+// player are part of his hand.
+func (g *GameState) maybeFinishHand() {
+	if GameStatus(g.currentStatus.Get()) != GameStatusShowdown {
+		return
+	}
+
+	active := g.betting.activePlayers()
+
+	g.lock.Lock()
+	if g.handFinished {
+		g.lock.Unlock()
+		return
+	}
+
+	hands := make(map[string][]deck.Card)
+	for _, addr := range active {
+		cards, ok := g.shownHands[addr]
+		if !ok {
+			g.lock.Unlock()
+			return
+		}
+		up, ok := g.upCardsLocked(addr)
+		if !ok {
+			g.lock.Unlock()
+			return
+		}
+		hands[addr] = append(append([]deck.Card{}, cards...), up...)
+	}
+
+	boards, ok := g.boardsLocked()
+	if !ok {
+		g.lock.Unlock()
+		return
+	}
+	g.handFinished = true
+	g.lock.Unlock()
+
+	shown := make(map[string][]CardState)
+	for addr, cards := range hands {
+		shown[addr] = newCardStates(cards)
+	}
+	g.publish(EventShowdown, ShowdownEvent{Hands: shown})
+
+	g.finishHand(&HandSummary{
+		Hands: hands,
+		Runs:  showdown(g.variant(), g.betting.pots(), boards, hands),
+	})
+}
+
+// winByFold gives the whole pot to the last player in the hand.
+func (g *GameState) winByFold(winner string) {
+	g.finishHand(&HandSummary{
+		Hands: map[string][]deck.Card{},
+		Runs: []RunResult{{
+			Board: g.Board(),
+			Awards: []PotAward{{
+				Amount:  g.betting.total(),
+				Winners: []string{winner},
+			}},
+		}},
+	})
+}
+
+// finishHand pays out the winners and gets ready for the next hand.
+func (g *GameState) finishHand(summary *HandSummary) {
+	winnings := summary.winnings(g.dealOrder())
+	for addr, amount := range winnings {
+		g.betting.award(addr, amount)
+	}
+
+	g.lock.Lock()
+	g.lastHand = summary
+	g.lock.Unlock()
+
+	for r, run := range summary.Runs {
+		for _, award := range run.Awards {
+			logrus.WithFields(logrus.Fields{
+				"we":   g.listenAddr,
+				"run":  r,
+				"hand": award.Hand,
+			}).Info(award)
+
+			g.publish(EventPotAwarded, PotAwardedEvent{
+				Run:     r,
+				Pot:     award.Pot,
+				Half:    award.Half.String(),
+				Amount:  award.Amount,
+				Winners: award.Winners,
+			})
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"we":       g.listenAddr,
+		"runs":     len(summary.Runs),
+		"winnings": winnings,
+	}).Info("hand finished")
+
+	g.endHand()
+}
+
+// LastHand returns the summary of the last finished hand.
+func (g *GameState) LastHand() *HandSummary {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	return g.lastHand
+}
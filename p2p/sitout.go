@@ -0,0 +1,203 @@
+package p2p
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultMaxSitOutOrbits is the number of orbits a player can sit out before
+// he is removed from the table, when the table is not configured with one.
+const defaultMaxSitOutOrbits = 3
+
+// SitOut keeps our seat, but we are not dealt in from the next hand on.
+func (g *GameState) SitOut() error {
+	if g.tournament != nil {
+		return newGameError(ErrCodeNotAllowed, "players can not sit out in a tournament")
+	}
+	player, err := g.table.GetPlayer(g.listenAddr)
+	if err != nil {
+		return newGameError(ErrCodeNotAllowed, "we are not sitting at the table")
+	}
+	if player.sittingOut {
+		return newGameError(ErrCodeNotAllowed, "we are already sitting out")
+	}
+
+	g.sitOut(g.listenAddr)
+	g.sendToPlayers(MessageSitOut{}, g.getOtherPlayers()...)
+
+	return nil
+}
+
+// SitIn deals us in again from the next hand on. When we missed the blinds we
+// either post a big blind in the next hand, or wait until the big blind
+// reaches our seat.
+func (g *GameState) SitIn(waitForBB bool) error {
+	player, err := g.table.GetPlayer(g.listenAddr)
+	if err != nil {
+		return newGameError(ErrCodeNotAllowed, "we are not sitting at the table")
+	}
+	if !player.sittingOut {
+		return newGameError(ErrCodeNotAllowed, "we are not sitting out")
+	}
+
+	g.sitIn(g.listenAddr, waitForBB)
+	g.sendToPlayers(MessageSitIn{WaitForBB: waitForBB}, g.getOtherPlayers()...)
+
+	return nil
+}
+
+func (g *GameState) handleSitOut(from string) error {
+	if g.tournament != nil {
+		return fmt.Errorf("player (%s) sat out in a tournament", from)
+	}
+	return g.sitOut(from)
+}
+
+func (g *GameState) handleSitIn(from string, msg MessageSitIn) error {
+	return g.sitIn(from, msg.WaitForBB)
+}
+
+func (g *GameState) sitOut(addr string) error {
+	err := g.table.updatePlayer(addr, func(p *Player) {
+		p.sittingOut = true
+		p.waitForBB = false
+	})
+	if err != nil {
+		return err
+	}
+
+	g.publish(EventPlayerSatOut, PlayerEvent{Addr: addr})
+	return nil
+}
+
+func (g *GameState) sitIn(addr string, waitForBB bool) error {
+	err := g.table.updatePlayer(addr, func(p *Player) {
+		p.sittingOut = false
+		p.orbitsOut = 0
+		p.waitForBB = waitForBB && p.missedBlinds
+	})
+	if err != nil {
+		return err
+	}
+
+	g.publish(EventPlayerSatIn, PlayerEvent{Addr: addr})
+	return nil
+}
+
+// handOrder returns the players the dealer deals in the next hand, starting
+// with the player after the button. Players without chips and players that
+// sit out are skipped. A player that waits for the big blind is dealt in as
+// the big blind once it passes his seat, at a short handed table, or in a
+// game without blinds, he is dealt in right away.
+func (g *GameState) handOrder() []string {
+	all := g.dealOrder()
+	order := []string{}
+	waiting := map[string]bool{}
+	for _, addr := range all {
+		player, err := g.table.GetPlayer(addr)
+		if err != nil || player.sittingOut || g.betting.stack(addr) == 0 {
+			continue
+		}
+		if player.waitForBB {
+			waiting[addr] = true
+		}
+		order = append(order, addr)
+	}
+	if len(waiting) == 0 || g.variant().isStud() || len(order)-len(waiting) < 3 {
+		return order
+	}
+
+	dealt := []string{}
+	for _, addr := range order {
+		if !waiting[addr] {
+			dealt = append(dealt, addr)
+		}
+	}
+
+	sb, bb := blindPositions(len(dealt))
+	from, to := indexOf(all, dealt[sb]), indexOf(all, dealt[bb])
+	for _, addr := range all[from+1 : to] {
+		if waiting[addr] {
+			// The player takes the big blind, the player that would have
+			// posted it is next to act.
+			dealt = append(dealt[:bb], append([]string{addr}, dealt[bb:]...)...)
+			break
+		}
+	}
+
+	return dealt
+}
+
+// trackMissedBlinds remembers the players that were not dealt in while the
+// blinds passed their seat. The order is the order of the hand that is about
+// to be played.
+func (g *GameState) trackMissedBlinds(order []string) {
+	all := g.dealOrder()
+	sb, bb := blindPositions(len(order))
+	from, to := indexOf(all, order[sb]), indexOf(all, order[bb])
+	if from > to {
+		from = -1
+	}
+
+	for i, addr := range all[:to] {
+		if indexOf(order, addr) != -1 {
+			continue
+		}
+		g.table.updatePlayer(addr, func(p *Player) {
+			if !g.variant().isStud() {
+				p.missedBlinds = true
+			}
+			// The big blind passes every seat once per orbit.
+			if p.sittingOut && i > from {
+				p.orbitsOut++
+			}
+		})
+	}
+}
+
+// removeSatOutPlayers cashes out the players that sat out too many orbits and
+// frees their seats. This happens after the hand, so every player at the
+// table still helped to decrypt the cards.
+func (g *GameState) removeSatOutPlayers() {
+	if g.options.MaxSitOutOrbits <= 0 || g.tournament != nil {
+		return
+	}
+
+	for _, player := range g.table.Players() {
+		if !player.sittingOut || player.orbitsOut < g.options.MaxSitOutOrbits {
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"we":     g.listenAddr,
+			"player": player.addr,
+			"orbits": player.orbitsOut,
+		}).Info("removing player that sat out too long")
+
+		if g.betting.hasStack(player.addr) {
+			g.cashOut(player.addr)
+		} else {
+			g.table.release(player.addr)
+		}
+		g.publish(EventSeatReleased, SeatEvent{Addr: player.addr, Seat: player.tablePos})
+	}
+}
+
+// blindPositions returns the index of the small and the big blind in the
+// order of the hand. Heads up the dealer posts the small blind.
+func blindPositions(players int) (int, int) {
+	if players == 2 {
+		return 1, 0
+	}
+	return 0, 1 % players
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
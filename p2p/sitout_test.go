@@ -0,0 +1,96 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newSitOutGame seats the players in the given order, the button is on the
+// first seat.
+func newSitOutGame(players ...string) *GameState {
+	cfg := ServerConfig{
+		AdvertiseAddr: players[0],
+		Rotation:      Rotation{Games: []Game{{GameVariant: TexasHoldem}}}.withDefaults(),
+		TableOptions:  TableOptions{MaxSitOutOrbits: 2},
+	}
+	g := NewGame(cfg, make(chan BroadcastTo, 10))
+	for i, addr := range players {
+		g.table.AddPlayerOnPosition(addr, i)
+		g.betting.sitDown(addr, 1000)
+	}
+	return g
+}
+
+func TestSitOut(t *testing.T) {
+	g := newSitOutGame(":3000", ":4000", ":5000", ":6000")
+
+	assert.Equal(t, ErrCodeNotAllowed, g.SitIn(false).(*GameError).Code)
+	assert.Nil(t, g.SitOut())
+	assert.Equal(t, MessageSitOut{}, (<-g.broadcastch).Payload)
+	assert.Equal(t, ErrCodeNotAllowed, g.SitOut().(*GameError).Code)
+
+	// We keep the button, but we are not dealt in.
+	assert.Nil(t, g.handleSitOut(":5000"))
+	assert.Equal(t, []string{":4000", ":6000"}, g.handOrder())
+
+	assert.Nil(t, g.handleSitIn(":5000", MessageSitIn{}))
+	assert.Equal(t, []string{":4000", ":5000", ":6000"}, g.handOrder())
+	assert.True(t, g.State().Seats[0].SittingOut)
+}
+
+func TestMissedBlinds(t *testing.T) {
+	g := newSitOutGame(":3000", ":4000", ":5000", ":6000", ":7000")
+	assert.Nil(t, g.handleSitOut(":4000"))
+	assert.Nil(t, g.handleSitOut(":5000"))
+
+	// The blinds pass both players that sit out.
+	order := g.handOrder()
+	assert.Equal(t, []string{":6000", ":7000", ":3000"}, order)
+	g.trackMissedBlinds(order)
+	for _, addr := range []string{":4000", ":5000"} {
+		player, _ := g.table.GetPlayer(addr)
+		assert.True(t, player.missedBlinds)
+	}
+
+	// One posts a big blind, the other waits for the big blind.
+	assert.Nil(t, g.handleSitIn(":4000", MessageSitIn{}))
+	assert.Nil(t, g.handleSitIn(":5000", MessageSitIn{WaitForBB: true}))
+	// The button moves to the next seat, the player that sits out between
+	// the button and the small blind waits.
+	assert.Equal(t, 1, g.getNextDealer())
+	g.currentDealer.Set(1)
+	order = g.handOrder()
+	assert.Equal(t, []string{":6000", ":7000", ":3000", ":4000"}, order)
+
+	g.betting.reset(order)
+	g.postBlinds(order, "")
+	assert.Equal(t, 5, g.betting.bet(":6000"))
+	assert.Equal(t, 10, g.betting.bet(":7000"))
+	assert.Equal(t, 10, g.betting.bet(":4000"))
+	player, _ := g.table.GetPlayer(":4000")
+	assert.False(t, player.missedBlinds)
+
+	// The big blind reaches the player that waits for it.
+	g.currentDealer.Set(0)
+	assert.Equal(t, []string{":4000", ":5000", ":6000", ":7000", ":3000"}, g.handOrder())
+}
+
+func TestRemoveSatOutPlayers(t *testing.T) {
+	g := newSitOutGame(":3000", ":4000", ":5000", ":6000")
+	assert.Nil(t, g.handleSitOut(":6000"))
+
+	for orbit := 0; orbit < 2; orbit++ {
+		for hand := 0; hand < 4; hand++ {
+			g.trackMissedBlinds(g.handOrder())
+			g.currentDealer.Set(int32(g.getNextDealer()))
+		}
+		player, _ := g.table.GetPlayer(":6000")
+		assert.Equal(t, orbit+1, player.orbitsOut)
+	}
+
+	g.removeSatOutPlayers()
+	assert.Equal(t, 3, g.table.LenPlayers())
+	assert.False(t, g.betting.hasStack(":6000"))
+	assert.Equal(t, LedgerCashOut, g.Ledger().Entries[0].Type)
+}
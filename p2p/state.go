@@ -1,4 +1,182 @@
 package p2p
 
+import (
+	"sort"
+	"time"
+
+	"github.com/anthdm/ggpoker/deck"
+)
+
+// State is the view of the table from the perspective of our own
+// player. It never holds the hidden cards of the other players.
 type State struct {
+	// Table is the ID of the table.
+	Table string `json:"table"`
+	// Addr is the address of our own player.
+	Addr   string `json:"addr"`
+	Game   string `json:"game"`
+	Status string `json:"status"`
+	// Dealer is the address of the player with the dealer button.
+	Dealer string `json:"dealer"`
+	// CurrentPlayer is the address of the player that needs to act, it is
+	// empty when nobody can act.
+	CurrentPlayer string `json:"currentPlayer"`
+	// TimeToAct is the number of milliseconds the current player has left to
+	// act, it is zero when the table plays without an action timer.
+	TimeToAct int `json:"timeToAct,omitempty"`
+	// MinBuyIn and MaxBuyIn are the limits of a buy-in or rebuy at the table.
+	MinBuyIn     int           `json:"minBuyIn"`
+	MaxBuyIn     int           `json:"maxBuyIn"`
+	Pot          int           `json:"pot"`
+	CurrentBet   int           `json:"currentBet"`
+	Board        []CardState   `json:"board"`
+	HoleCards    []CardState   `json:"holeCards"`
+	Seats        []SeatState   `json:"seats"`
+	LegalActions []LegalAction `json:"legalActions"`
+	// Tournament is the progress of the tournament, it is not set in a cash
+	// game.
+	Tournament *TournamentState `json:"tournament,omitempty"`
+}
+
+// SeatState is a player sitting at the table, or a seat reserved for a
+// player that did not sit down yet.
+type SeatState struct {
+	Seat   int    `json:"seat"`
+	Addr   string `json:"addr"`
+	Stack  int    `json:"stack"`
+	Bet    int    `json:"bet"`
+	Status string `json:"status"`
+	// InHand reports whether the player is dealt in and has not folded.
+	InHand bool `json:"inHand"`
+	// UpCards are the face up cards of the player in stud games.
+	UpCards []CardState `json:"upCards,omitempty"`
+	// SittingOut is set when the player keeps his seat without being dealt
+	// in.
+	SittingOut bool `json:"sittingOut,omitempty"`
+	// MissedBlinds is set when the blinds passed the player while he was not
+	// dealt in, he posts a big blind when he sits in again.
+	MissedBlinds bool `json:"missedBlinds,omitempty"`
+	// WaitingForBB is set when the player sat in and waits for the big blind.
+	WaitingForBB bool `json:"waitingForBB,omitempty"`
+	// ReservedFor is the number of milliseconds left before the reservation
+	// of the seat expires, it is zero when the reservation does not expire.
+	ReservedFor int `json:"reservedFor,omitempty"`
+}
+
+// seatStatusReserved is the status of a seat that is reserved.
+const seatStatusReserved = "RESERVED"
+
+// LegalAction is an action we can take right now. For a bet Min and Max hold
+// the lowest and highest total amount we can bet in this street, for a call
+// Min holds the amount to call and for a draw Max holds the number of cards
+// we can discard.
+type LegalAction struct {
+	Action string `json:"action"`
+	Min    int    `json:"min,omitempty"`
+	Max    int    `json:"max,omitempty"`
+}
+
+type CardState struct {
+	Suit  string `json:"suit"`
+	Value int    `json:"value"`
+}
+
+func newCardStates(cards []deck.Card) []CardState {
+	states := make([]CardState, len(cards))
+	for i, c := range cards {
+		states[i] = CardState{Suit: c.Suit.String(), Value: c.Value}
+	}
+	return states
+}
+
+// State returns the current state of the table as seen by our own player.
+func (g *GameState) State() State {
+	dealer, _ := g.getCurrentDealerAddr()
+	status := GameStatus(g.currentStatus.Get())
+	state := State{
+		Table:        g.id,
+		Addr:         g.listenAddr,
+		Game:         g.game().String(),
+		Status:       status.String(),
+		Dealer:       dealer,
+		MinBuyIn:     g.options.MinBuyIn,
+		MaxBuyIn:     g.options.MaxBuyIn,
+		Pot:          g.betting.total(),
+		CurrentBet:   g.betting.currentBetSize(),
+		Board:        newCardStates(g.Board()),
+		HoleCards:    newCardStates(g.HoleCards()),
+		Seats:        []SeatState{},
+		LegalActions: g.legalActions(),
+		Tournament:   g.tournamentProgress(),
+	}
+
+	// There is only a player to act during the streets of a hand.
+	if _, ok := g.variant().street(status); ok {
+		if player, err := g.table.GetPlayerAtPos(int(g.currentPlayerTurn.Get())); err == nil {
+			state.CurrentPlayer = player.addr
+			state.TimeToAct = int(g.timeToAct().Milliseconds())
+		}
+	}
+
+	for _, player := range g.table.Players() {
+		state.Seats = append(state.Seats, SeatState{
+			Seat:         player.tablePos,
+			Addr:         player.addr,
+			Stack:        g.betting.stack(player.addr),
+			Bet:          g.betting.bet(player.addr),
+			Status:       player.gameStatus.String(),
+			InHand:       g.betting.isActive(player.addr),
+			UpCards:      newCardStates(g.UpCards(player.addr)),
+			SittingOut:   player.sittingOut,
+			MissedBlinds: player.missedBlinds,
+			WaitingForBB: player.waitForBB,
+		})
+	}
+	for seat, r := range g.table.reserved() {
+		seatState := SeatState{
+			Seat:   seat,
+			Addr:   r.addr,
+			Status: seatStatusReserved,
+		}
+		if left := time.Until(r.expires); !r.expires.IsZero() && left > 0 {
+			seatState.ReservedFor = int(left.Milliseconds())
+		}
+		state.Seats = append(state.Seats, seatState)
+	}
+	sort.Slice(state.Seats, func(i, j int) bool {
+		return state.Seats[i].Seat < state.Seats[j].Seat
+	})
+
+	return state
+}
+
+// legalActions returns the actions we can take, there are none when it is not
+// our turn.
+func (g *GameState) legalActions() []LegalAction {
+	actions := []LegalAction{}
+	if _, ok := g.variant().street(GameStatus(g.currentStatus.Get())); !ok || !g.canTakeAction(g.listenAddr) {
+		return actions
+	}
+
+	if g.isDrawing() {
+		return append(actions, LegalAction{
+			Action: "DRAW",
+			Max:    len(g.HoleCards()),
+		})
+	}
+
+	actions = append(actions, LegalAction{Action: PlayerActionFold.String()})
+	if toCall := g.betting.toCall(g.listenAddr); toCall > 0 {
+		if stack := g.betting.stack(g.listenAddr); toCall > stack {
+			toCall = stack
+		}
+		actions = append(actions, LegalAction{Action: PlayerActionCall.String(), Min: toCall})
+	} else {
+		actions = append(actions, LegalAction{Action: PlayerActionCheck.String()})
+	}
+	if min, max, ok := g.betting.betLimits(g.listenAddr, g.structure(), g.isBigBetStreet()); ok {
+		actions = append(actions, LegalAction{Action: PlayerActionBet.String(), Min: min, Max: max})
+	}
+
+	return actions
 }
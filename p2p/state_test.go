@@ -0,0 +1,35 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/anthdm/ggpoker/deck"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateHidesOtherCards(t *testing.T) {
+	cfg := ServerConfig{
+		ListenAddr: ":3000",
+		Rotation:   Rotation{Games: []Game{{GameVariant: SevenCardStud}}}.withDefaults(),
+	}
+	g := NewGame(cfg, make(chan BroadcastTo, 10))
+	g.table.AddPlayerOnPosition(":3000", 0)
+	g.table.AddPlayerOnPosition(":4000", 1)
+	g.betting.sitDown(":3000", 100)
+	g.betting.sitDown(":4000", 100)
+	g.betting.reset([]string{":3000", ":4000"})
+
+	g.layout = newCardLayout()
+	g.layout.dealHoleCards([]string{":3000", ":4000"}, 2)
+	g.layout.dealUpCards([]string{":3000", ":4000"}, 1)
+	g.holeCards[0] = deck.NewCard(deck.Spades, 1)
+	g.holeCards[2] = deck.NewCard(deck.Spades, 13)
+	g.publicCards[4] = deck.NewCard(deck.Harts, 2)
+	g.publicCards[5] = deck.NewCard(deck.Clubs, 9)
+
+	state := g.State()
+	assert.Equal(t, []CardState{{Suit: "SPADES", Value: 1}, {Suit: "SPADES", Value: 13}}, state.HoleCards)
+	assert.Equal(t, 2, len(state.Seats))
+	assert.Equal(t, []CardState{{Suit: "CLUBS", Value: 9}}, state.Seats[1].UpCards)
+	assert.Empty(t, state.LegalActions)
+}
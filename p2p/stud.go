@@ -0,0 +1,103 @@
+package p2p
+
+import (
+	"github.com/anthdm/ggpoker/deck"
+	"github.com/sirupsen/logrus"
+)
+
+// maybeStartStudBetting gives the turn to the first player to act once all the
+// face up cards of the street are revealed. On third street the player with
+// the lowest up card (the highest in razz) is forced to bring it in and the
+// player after him acts first. On the later streets the best visible hand
+// acts first.
+func (g *GameState) maybeStartStudBetting() {
+	active := g.betting.activePlayers()
+
+	g.lock.Lock()
+	if !g.awaitingUpCards {
+		g.lock.Unlock()
+		return
+	}
+	upCards := make(map[string][]deck.Card)
+	for _, addr := range active {
+		cards, ok := g.upCardsLocked(addr)
+		if !ok {
+			g.lock.Unlock()
+			return
+		}
+		upCards[addr] = cards
+	}
+	g.awaitingUpCards = false
+	g.lock.Unlock()
+
+	if GameStatus(g.currentStatus.Get()) == g.variant().streets()[0].status {
+		i := lowestUpCard(active, upCards)
+		if g.variant().isLowball() {
+			i = highestUpCard(active, upCards)
+		}
+		g.betting.postBringIn(active[i], g.structure().BringIn)
+
+		logrus.WithFields(logrus.Fields{
+			"we":      g.listenAddr,
+			"bringIn": active[i],
+		}).Info("bring-in posted")
+
+		g.setTurn(rotate(active, i+1))
+		return
+	}
+
+	g.setTurn(rotate(active, bestVisibleHand(g.variant(), active, upCards)))
+}
+
+// lowestUpCard returns the index of the player with the lowest up card. Aces
+// are high and ties are broken by suit, clubs being the lowest and spades the
+// highest.
+func lowestUpCard(players []string, upCards map[string][]deck.Card) int {
+	lowest := 0
+	for i, addr := range players {
+		if isLowerCard(upCards[addr][0], upCards[players[lowest]][0]) {
+			lowest = i
+		}
+	}
+	return lowest
+}
+
+// highestUpCard returns the index of the player with the highest up card in
+// razz. Aces are low and ties are broken by suit, spades being the highest.
+func highestUpCard(players []string, upCards map[string][]deck.Card) int {
+	highest := 0
+	for i, addr := range players {
+		a, b := upCards[addr][0], upCards[players[highest]][0]
+		if a.Value > b.Value || (a.Value == b.Value && suitRank(a.Suit) > suitRank(b.Suit)) {
+			highest = i
+		}
+	}
+	return highest
+}
+
+func isLowerCard(a, b deck.Card) bool {
+	if a.Rank() != b.Rank() {
+		return a.Rank() < b.Rank()
+	}
+	return suitRank(a.Suit) < suitRank(b.Suit)
+}
+
+// suitRank returns the strength of the suit for the bring-in: clubs, diamonds,
+// hearts and spades from low to high.
+func suitRank(s deck.Suit) int {
+	return int(deck.Clubs - s)
+}
+
+// bestVisibleHand returns the index of the player with the best hand showing.
+// Ties go to the first player in the given order.
+func bestVisibleHand(variant GameVariant, players []string, upCards map[string][]deck.Card) int {
+	best := 0
+	bestValue := variant.visibleHand(upCards[players[0]])
+	for i, addr := range players[1:] {
+		if value := variant.visibleHand(upCards[addr]); variant.beats(value, bestValue) {
+			best = i + 1
+			bestValue = value
+		}
+	}
+	return best
+}
@@ -0,0 +1,32 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/anthdm/ggpoker/deck"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStudActionOrder(t *testing.T) {
+	players := []string{"a", "b", "c"}
+
+	// The deuce of clubs is the lowest card, the ace plays high.
+	upCards := map[string][]deck.Card{
+		"a": {deck.NewCard(deck.Spades, 2)},
+		"b": {deck.NewCard(deck.Clubs, 2)},
+		"c": {deck.NewCard(deck.Harts, 1)},
+	}
+	assert.Equal(t, 1, lowestUpCard(players, upCards))
+
+	upCards = map[string][]deck.Card{
+		"a": {deck.NewCard(deck.Spades, 13), deck.NewCard(deck.Harts, 1)},
+		"b": {deck.NewCard(deck.Clubs, 4), deck.NewCard(deck.Harts, 4)},
+		"c": {deck.NewCard(deck.Diamonds, 4), deck.NewCard(deck.Spades, 4)},
+	}
+	// A pair beats ace high, ties go to the first player.
+	assert.Equal(t, 1, bestVisibleHand(SevenCardStud, players, upCards))
+
+	// In razz the highest card brings it in and the lowest hand acts first.
+	assert.Equal(t, 0, highestUpCard(players, upCards))
+	assert.Equal(t, 0, bestVisibleHand(Razz, players, upCards))
+}
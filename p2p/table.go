@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 )
 
 type Player struct {
@@ -11,6 +12,18 @@ type Player struct {
 	currentAction PlayerAction
 	gameStatus    GameStatus
 	tablePos      int
+	// sittingOut is set while the player keeps his seat without being dealt
+	// in.
+	sittingOut bool
+	// missedBlinds is set when the blinds passed the seat of the player while
+	// he was not dealt in.
+	missedBlinds bool
+	// waitForBB is set when the player sat in and waits for the big blind to
+	// reach his seat, instead of posting the blinds he missed.
+	waitForBB bool
+	// orbitsOut is the number of times the blinds passed the seat of the
+	// player while he was sitting out.
+	orbitsOut int
 }
 
 func NewPlayer(addr string) *Player {
@@ -22,17 +35,26 @@ func NewPlayer(addr string) *Player {
 	}
 }
 
+// reservation holds a seat for a player until he sits down.
+type reservation struct {
+	addr string
+	// expires is zero when the reservation does not expire.
+	expires time.Time
+}
+
 type Table struct {
-	lock  sync.RWMutex
-	seats map[int]*Player
+	lock         sync.RWMutex
+	seats        map[int]*Player
+	reservations map[int]reservation
 
 	maxSeats int
 }
 
 func NewTable(maxSeats int) *Table {
 	return &Table{
-		seats:    make(map[int]*Player),
-		maxSeats: maxSeats,
+		seats:        make(map[int]*Player),
+		reservations: make(map[int]reservation),
+		maxSeats:     maxSeats,
 	}
 }
 
@@ -83,8 +105,8 @@ func (t *Table) GetPlayerBefore(addr string) (*Player, error) {
 		}
 
 		i--
-		if i <= 0 {
-			i = t.maxSeats
+		if i < 0 {
+			i = t.maxSeats - 1
 		}
 	}
 }
@@ -117,6 +139,7 @@ func (t *Table) GetPlayerAfter(addr string) (*Player, error) {
 
 func (t *Table) clear() {
 	t.seats = map[int]*Player{}
+	t.reservations = map[int]reservation{}
 }
 
 func (t *Table) LenPlayers() int {
@@ -150,6 +173,18 @@ func (t *Table) GetPlayer(addr string) (*Player, error) {
 	return t.getPlayer(addr)
 }
 
+func (t *Table) GetPlayerAtPos(pos int) (*Player, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	player, ok := t.seats[pos]
+	if !ok {
+		return nil, fmt.Errorf("no player at position (%d)", pos)
+	}
+
+	return player, nil
+}
+
 func (t *Table) getPlayer(addr string) (*Player, error) {
 	for i := 0; i < t.maxSeats; i++ {
 		player, ok := t.seats[i]
@@ -163,6 +198,20 @@ func (t *Table) getPlayer(addr string) (*Player, error) {
 	return nil, fmt.Errorf("player (%s) not on the table", addr)
 }
 
+// updatePlayer changes the player while holding the lock of the table.
+func (t *Table) updatePlayer(addr string, fn func(p *Player)) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	p, err := t.getPlayer(addr)
+	if err != nil {
+		return err
+	}
+	fn(p)
+
+	return nil
+}
+
 func (t *Table) SetPlayerStatus(addr string, s GameStatus) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
@@ -182,7 +231,9 @@ func (t *Table) AddPlayerOnPosition(addr string, pos int) error {
 		return fmt.Errorf("player table is full")
 	}
 
-	// pos := t.getNextFreeSeat()
+	// The player sits down on the seat he reserved.
+	delete(t.reservations, pos)
+
 	player := NewPlayer(addr)
 	player.tablePos = pos
 	player.gameStatus = GameStatusPlayerReady
@@ -211,6 +262,36 @@ func (t *Table) AddPlayer(addr string) error {
 	return nil
 }
 
+// Seats returns the seat of every player at the table.
+func (t *Table) Seats() map[string]int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	seats := make(map[string]int, len(t.seats))
+	for pos, player := range t.seats {
+		seats[player.addr] = pos
+	}
+
+	return seats
+}
+
+// NextFreeSeat returns the first seat nobody is sitting on or has reserved.
+func (t *Table) NextFreeSeat() (int, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	for i := 0; i < t.maxSeats; i++ {
+		if _, ok := t.seats[i]; ok {
+			continue
+		}
+		if _, ok := t.reservations[i]; !ok {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("player table is full")
+}
+
 func (t *Table) getNextFreeSeat() int {
 	for i := 0; i < t.maxSeats; i++ {
 		if _, ok := t.seats[i]; !ok {
@@ -220,3 +301,113 @@ func (t *Table) getNextFreeSeat() int {
 
 	panic("no free seat is available!!")
 }
+
+// Reserve holds the seat for the player. A zero expires means the
+// reservation does not expire.
+func (t *Table) Reserve(addr string, seat int, expires time.Time) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if seat < 0 || seat >= t.maxSeats {
+		return fmt.Errorf("seat (%d) does not exist", seat)
+	}
+	if player, ok := t.seats[seat]; ok {
+		return fmt.Errorf("seat (%d) is taken by (%s)", seat, player.addr)
+	}
+
+	t.reservations[seat] = reservation{
+		addr:    addr,
+		expires: expires,
+	}
+
+	return nil
+}
+
+// Reservations returns the reserved seat of every player.
+func (t *Table) Reservations() map[string]int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	reservations := make(map[string]int, len(t.reservations))
+	for seat, r := range t.reservations {
+		reservations[r.addr] = seat
+	}
+
+	return reservations
+}
+
+// reserved returns the reservations by seat.
+func (t *Table) reserved() map[int]reservation {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	reserved := make(map[int]reservation, len(t.reservations))
+	for seat, r := range t.reservations {
+		reserved[seat] = r
+	}
+
+	return reserved
+}
+
+// reservationOf returns the seat the player reserved.
+func (t *Table) reservationOf(addr string) (int, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	for seat, r := range t.reservations {
+		if r.addr == addr {
+			return seat, true
+		}
+	}
+
+	return 0, false
+}
+
+// seatHolder returns the player that sits on or reserved the seat.
+func (t *Table) seatHolder(seat int) (string, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if player, ok := t.seats[seat]; ok {
+		return player.addr, true
+	}
+	if r, ok := t.reservations[seat]; ok {
+		return r.addr, true
+	}
+
+	return "", false
+}
+
+// release frees the seat the player sits on or reserved.
+func (t *Table) release(addr string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for seat, player := range t.seats {
+		if player.addr == addr {
+			delete(t.seats, seat)
+		}
+	}
+	for seat, r := range t.reservations {
+		if r.addr == addr {
+			delete(t.reservations, seat)
+		}
+	}
+}
+
+// expireReservations frees the seats of the reservations that expired and
+// returns the seat every player lost.
+func (t *Table) expireReservations(now time.Time) map[string]int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	expired := map[string]int{}
+	for seat, r := range t.reservations {
+		if !r.expires.IsZero() && !now.Before(r.expires) {
+			expired[r.addr] = seat
+			delete(t.reservations, seat)
+		}
+	}
+
+	return expired
+}
@@ -112,3 +112,21 @@ func TestTableGetPlayer(t *testing.T) {
 	}
 	assert.Equal(t, maxSeats, table.LenPlayers())
 }
+
+func TestTableSeatsWithGaps(t *testing.T) {
+	table := NewTable(6)
+
+	assert.Nil(t, table.AddPlayerOnPosition("poker.example.com:3000", 0))
+	assert.Nil(t, table.AddPlayerOnPosition("[::1]:3000", 2))
+	seat, err := table.NextFreeSeat()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, seat)
+	assert.Equal(t, map[string]int{"poker.example.com:3000": 0, "[::1]:3000": 2}, table.Seats())
+
+	prevPlayer, err := table.GetPlayerBefore("[::1]:3000")
+	assert.Nil(t, err)
+	assert.Equal(t, "poker.example.com:3000", prevPlayer.addr)
+	prevPlayer, err = table.GetPlayerBefore("poker.example.com:3000")
+	assert.Nil(t, err)
+	assert.Equal(t, "[::1]:3000", prevPlayer.addr)
+}
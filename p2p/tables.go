@@ -0,0 +1,384 @@
+package p2p
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultTableID is the ID of a table that is not given one.
+const DefaultTableID = "main"
+
+// TableInfo is a table the node plays at.
+type TableInfo struct {
+	ID     string `json:"id"`
+	Game   string `json:"game"`
+	Status string `json:"status"`
+	// Players is the number of players sitting at the table.
+	Players    int  `json:"players"`
+	Tournament bool `json:"tournament"`
+}
+
+// ID returns the ID of the table.
+func (g *GameState) ID() string {
+	return g.id
+}
+
+// Info returns what table the game is played at.
+func (g *GameState) Info() TableInfo {
+	return TableInfo{
+		ID:         g.id,
+		Game:       g.game().String(),
+		Status:     GameStatus(g.currentStatus.Get()).String(),
+		Players:    g.table.LenPlayers(),
+		Tournament: g.tournament != nil,
+	}
+}
+
+// tableServer is a table the node plays at. Every table has its own game and
+// players, the connections to the players are shared by all the tables.
+type tableServer struct {
+	ServerConfig
+
+	server    *Server
+	gameState *GameState
+	// book holds every player at the table we know about.
+	book *peerBook
+}
+
+func newTableServer(s *Server, cfg ServerConfig) *tableServer {
+	t := &tableServer{
+		ServerConfig: cfg,
+		server:       s,
+		gameState:    NewGame(cfg, s.broadcastch),
+		book:         newPeerBook(cfg.AdvertiseAddr),
+	}
+	t.book.add(cfg.BootstrapPeers...)
+
+	return t
+}
+
+// AddTable joins another table. We tell the players we are already connected
+// to, the ones at the same table add us to it, and we connect to the
+// BootstrapPeers of the table.
+func (s *Server) AddTable(cfg ServerConfig) (*GameState, error) {
+	return s.addTable(cfg, nil)
+}
+
+// addTable joins the table. When the coordinator moved us to the table we
+// sit down before the players at the table learn about us.
+func (s *Server) addTable(cfg ServerConfig, arrival *PlayerMove) (*GameState, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cfg.Version = s.Version
+	cfg.ListenAddr = s.ListenAddr
+	cfg.AdvertiseAddr = s.AdvertiseAddr
+	cfg.APIListenAddr = s.APIListenAddr
+	cfg.APIToken = s.APIToken
+	cfg.LobbyAddr = s.LobbyAddr
+	cfg.LobbyListenAddr = s.LobbyListenAddr
+	cfg.Tables = nil
+	cfg = cfg.withDefaults()
+
+	s.tableLock.Lock()
+	if _, ok := s.tables[cfg.TableID]; ok {
+		s.tableLock.Unlock()
+		return nil, fmt.Errorf("we already play at table (%s)", cfg.TableID)
+	}
+	t := newTableServer(s, cfg)
+	if arrival != nil {
+		t.gameState.arrive(*arrival)
+	}
+	s.tables[cfg.TableID] = t
+	s.tableLock.Unlock()
+
+	if peers := s.Peers(); len(peers) > 0 {
+		s.broadcastch <- BroadcastTo{
+			To:      peers,
+			Table:   t.TableID,
+			Payload: MessageJoinTable{Table: t.handshake()},
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"we":       s.AdvertiseAddr,
+		"table":    t.TableID,
+		"rotation": t.Rotation,
+	}).Info("joined table")
+
+	return t.gameState, nil
+}
+
+// RemoveTable leaves the table and stops its game. The other players keep us
+// at the table like a player that disconnected. The last table of the node
+// can not be left.
+func (s *Server) RemoveTable(id string) error {
+	s.tableLock.Lock()
+	t, ok := s.tables[id]
+	if !ok {
+		s.tableLock.Unlock()
+		return newGameError(ErrCodeUnknownTable, "we do not play at table (%s)", id)
+	}
+	if len(s.tables) == 1 {
+		s.tableLock.Unlock()
+		return newGameError(ErrCodeNotAllowed, "the last table of the node can not be left")
+	}
+	delete(s.tables, id)
+	if s.gameState == t.gameState {
+		first := ""
+		for other := range s.tables {
+			if first == "" || other < first {
+				first = other
+			}
+		}
+		s.gameState = s.tables[first].gameState
+	}
+	s.tableLock.Unlock()
+
+	t.gameState.Close()
+
+	logrus.WithFields(logrus.Fields{
+		"we":    s.AdvertiseAddr,
+		"table": id,
+	}).Info("left table")
+
+	return nil
+}
+
+// firstTable returns the game of the first table of the node.
+func (s *Server) firstTable() *GameState {
+	s.tableLock.RLock()
+	defer s.tableLock.RUnlock()
+
+	return s.gameState
+}
+
+// Table returns the game of the table with the given ID.
+func (s *Server) Table(id string) (*GameState, bool) {
+	t, ok := s.table(id)
+	if !ok {
+		return nil, false
+	}
+	return t.gameState, true
+}
+
+// Tables returns the games of every table we play at, by their ID.
+func (s *Server) Tables() []*GameState {
+	games := []*GameState{}
+	for _, t := range s.tableList() {
+		games = append(games, t.gameState)
+	}
+	return games
+}
+
+func (s *Server) table(id string) (*tableServer, bool) {
+	s.tableLock.RLock()
+	defer s.tableLock.RUnlock()
+
+	t, ok := s.tables[id]
+	return t, ok
+}
+
+// tableList returns every table we play at, by their ID.
+func (s *Server) tableList() []*tableServer {
+	s.tableLock.RLock()
+	defer s.tableLock.RUnlock()
+
+	tables := make([]*tableServer, 0, len(s.tables))
+	for _, t := range s.tables {
+		tables = append(tables, t)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].TableID < tables[j].TableID
+	})
+	return tables
+}
+
+// maxPeers returns the number of players we can be connected to, the sum of
+// the limits of every table.
+func (s *Server) maxPeers() int {
+	max := 0
+	for _, t := range s.tableList() {
+		max += t.MaxPeers
+	}
+	return max
+}
+
+// handshake returns how the table is played and who sits where, for a player
+// that joins it.
+func (t *tableServer) handshake() TableHandshake {
+	g := t.gameState
+	stacks := g.betting.stackSnapshot()
+	if g.isArriving() {
+		// The players take the stack we brought from the coordinator.
+		delete(stacks, g.listenAddr)
+	}
+	return TableHandshake{
+		ID:           t.TableID,
+		Rotation:     t.Rotation,
+		TableOptions: t.TableOptions,
+		Tournament:   t.Tournament,
+		MaxPlayers:   t.MaxPlayers,
+		GameStatus:   GameStatus(g.currentStatus.Get()),
+		Seats:        g.table.Seats(),
+		Reservations: g.table.Reservations(),
+		Stacks:       stacks,
+	}
+}
+
+// check returns an error when the player does not play the same game at the
+// table.
+func (t *tableServer) check(hs TableHandshake) error {
+	if !t.Rotation.equal(hs.Rotation) {
+		return fmt.Errorf("game rotation of table (%s) does not match %s", t.TableID, hs.Rotation)
+	}
+	if t.TableOptions != hs.TableOptions {
+		return fmt.Errorf("table options of table (%s) do not match %+v", t.TableID, hs.TableOptions)
+	}
+	if !t.Tournament.equal(hs.Tournament) {
+		return fmt.Errorf("tournament of table (%s) does not match %s", t.TableID, hs.Tournament)
+	}
+	if t.MaxPlayers != hs.MaxPlayers {
+		return fmt.Errorf("table (%s) has %d seats, not %d", t.TableID, t.MaxPlayers, hs.MaxPlayers)
+	}
+	return nil
+}
+
+// join adds the player to the table, with the seats and stacks he knows
+// about.
+func (t *tableServer) join(addr string, hs TableHandshake) {
+	t.book.add(addr)
+	t.gameState.AddPlayer(addr)
+	t.gameState.applySeats(hs.Seats, hs.Reservations)
+	t.gameState.applyStacks(hs.Stacks)
+	t.gameState.announceArrival(addr)
+}
+
+// hasPlayer reports whether the player joined the table.
+func (t *tableServer) hasPlayer(addr string) bool {
+	return t.gameState.playersList.getIndex(addr) != -1
+}
+
+// peers returns the players at the table we are connected to.
+func (t *tableServer) peers() []string {
+	peers := []string{}
+	for _, addr := range t.gameState.getOtherPlayers() {
+		if t.server.isInPeerList(addr) {
+			peers = append(peers, addr)
+		}
+	}
+	return peers
+}
+
+func (t *tableServer) handleMessage(from string, payload any) error {
+	switch v := payload.(type) {
+	case MessagePreFlop:
+		return t.handleMsgPreFlop(from, v)
+	case MessagePeerList:
+		return t.handlePeerList(v)
+	case MessageJoinTable:
+		return t.handleJoinTable(from, v)
+	case MessageEncDeck:
+		return t.handleMsgEncDeck(from, v)
+	case MessageTakeSeat:
+		return t.gameState.handleTakeSeat(from, v)
+	case MessageAddChips:
+		return t.gameState.handleAddChips(from, v)
+	case MessageCashOut:
+		return t.gameState.handleCashOut(from)
+	case MessageSitOut:
+		return t.gameState.handleSitOut(from)
+	case MessageSitIn:
+		return t.gameState.handleSitIn(from, v)
+	case MessageMovePlayers:
+		return t.gameState.handleMovePlayers(from, v)
+	case MessageProposeDeal:
+		return t.gameState.handleProposeDeal(from, v)
+	case MessageAcceptDeal:
+		return t.gameState.handleAcceptDeal(from, v)
+	case MessageDeclineDeal:
+		return t.gameState.handleDeclineDeal(from)
+	case MessageReady:
+		return t.handleMsgReady(from, v)
+	case MessagePlayerAction:
+		return t.handleGetMsgPlayerAction(from, v)
+	case MessageDecryptCard:
+		return t.gameState.handleDecryptCard(from, v)
+	case MessagePublicCard:
+		return t.gameState.handlePublicCard(from, v)
+	case MessageDraw:
+		return t.gameState.handleDraw(from, v)
+	case MessageStraddle:
+		return t.gameState.handleStraddle(from)
+	case MessageBombPotVote:
+		return t.gameState.handleBombPotVote(from)
+	case MessageRunItTwice:
+		return t.gameState.handleRunItTwice(from, v)
+	case MessageShowdown:
+		return t.gameState.handleShowdown(from, v)
+	}
+	return nil
+}
+
+func (t *tableServer) handleGetMsgPlayerAction(from string, msg MessagePlayerAction) error {
+	return t.gameState.handlePlayerAction(from, msg)
+}
+
+func (t *tableServer) handleMsgPreFlop(from string, msg MessagePreFlop) error {
+	if !t.gameState.isFromCurrentDealer(from) {
+		return fmt.Errorf("received preflop from player (%s) that is not the dealer", from)
+	}
+
+	if err := t.gameState.switchGame(msg.Game); err != nil {
+		return err
+	}
+	if err := t.gameState.switchLevel(msg.Level); err != nil {
+		return err
+	}
+	if err := t.gameState.validateForcedBets(msg.Players, msg.ForcedBets); err != nil {
+		return fmt.Errorf("received preflop from dealer (%s) with %s", from, err)
+	}
+	t.gameState.SetStatus(t.gameState.variant().streets()[0].status)
+	t.gameState.startHand(msg.Deck, msg.Players, msg.ForcedBets)
+
+	return nil
+}
+
+func (t *tableServer) handleMsgReady(from string, msg MessageReady) error {
+	t.gameState.SetPlayerReady(from, msg.Seat, msg.BuyIn)
+
+	return nil
+}
+
+func (t *tableServer) handleMsgEncDeck(from string, msg MessageEncDeck) error {
+	return t.gameState.ShuffleAndEncrypt(from, msg.Deck)
+}
+
+// TODO FIXME: (@anthdm) maybe goroutine??
+func (t *tableServer) handlePeerList(l MessagePeerList) error {
+	if t.book.add(l.Peers...) > 0 {
+		t.server.dialKnownPeers()
+	}
+
+	return nil
+}
+
+// handleJoinTable adds a player we are already connected to, that joined the
+// table after we connected.
+func (t *tableServer) handleJoinTable(from string, msg MessageJoinTable) error {
+	if err := t.check(msg.Table); err != nil {
+		return fmt.Errorf("player (%s) cannot join: %s", from, err)
+	}
+	if t.hasPlayer(from) {
+		return nil
+	}
+
+	t.join(from, msg.Table)
+	// The player does not know we are at the table yet.
+	t.gameState.sendToPlayers(MessageJoinTable{Table: t.handshake()}, from)
+	t.server.gossipPeers()
+
+	return nil
+}
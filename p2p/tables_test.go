@@ -0,0 +1,97 @@
+package p2p
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPITables(t *testing.T) {
+	newTable := func(id string, gv GameVariant) *GameState {
+		return NewGame(ServerConfig{
+			ListenAddr: ":3000",
+			TableID:    id,
+			Rotation:   Rotation{Games: []Game{{GameVariant: gv}}}.withDefaults(),
+		}, make(chan BroadcastTo, 10))
+	}
+	main := newTable("main", TexasHoldem)
+	plo := newTable("plo", Omaha)
+	api := NewAPIServer(":3001", "token", main)
+	api.tables = func() []*GameState { return []*GameState{main, plo} }
+	router := api.Handler()
+
+	get := func(path string, v any) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		router.ServeHTTP(rec, req)
+		assert.Nil(t, json.NewDecoder(rec.Body).Decode(v))
+		return rec.Code
+	}
+
+	tables := []TableInfo{}
+	assert.Equal(t, http.StatusOK, get("/tables", &tables))
+	assert.Equal(t, []string{"main", "plo"}, []string{tables[0].ID, tables[1].ID})
+	assert.Contains(t, tables[1].Game, "OMAHA")
+
+	// Without a table the first table is served.
+	var state State
+	assert.Equal(t, http.StatusOK, get("/state", &state))
+	assert.Equal(t, "main", state.Table)
+	assert.Equal(t, http.StatusOK, get("/tables/plo/state", &state))
+	assert.Equal(t, "plo", state.Table)
+
+	var resp ErrorResponse
+	assert.Equal(t, http.StatusNotFound, get("/tables/vip/state", &resp))
+	assert.Equal(t, ErrCodeUnknownTable, resp.Code)
+}
+
+func TestJoinTable(t *testing.T) {
+	a := NewServer(ServerConfig{
+		ListenAddr:    ":23110",
+		APIListenAddr: ":0",
+		Tables:        []ServerConfig{{TableID: "plo", GameVariant: Omaha}},
+	})
+	b := NewServer(ServerConfig{
+		ListenAddr:     ":23112",
+		APIListenAddr:  ":0",
+		BootstrapPeers: []string{":23110"},
+	})
+	go a.Start()
+	go b.Start()
+
+	assert.Eventually(t, func() bool {
+		return len(a.Peers()) == 1 && len(b.Peers()) == 1
+	}, 10*time.Second, 50*time.Millisecond)
+
+	plo, ok := a.Table("plo")
+	assert.True(t, ok)
+	assert.Empty(t, plo.getOtherPlayers())
+	_, ok = b.Table("plo")
+	assert.False(t, ok)
+
+	// b joins the table over the connection it already has to a.
+	joined, err := b.AddTable(ServerConfig{TableID: "plo", GameVariant: Omaha})
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		return len(plo.getOtherPlayers()) == 1 && len(joined.getOtherPlayers()) == 1
+	}, 10*time.Second, 50*time.Millisecond)
+	assert.Equal(t, 2, len(b.Tables()))
+
+	_, err = b.AddTable(ServerConfig{TableID: "plo"})
+	assert.NotNil(t, err)
+
+	// b leaves its first table, the game of the table is stopped and plo
+	// becomes its first table.
+	first := b.firstTable()
+	assert.Nil(t, b.RemoveTable(DefaultTableID))
+	assert.Equal(t, joined, b.firstTable())
+	_, open := <-first.quit
+	assert.False(t, open)
+	assert.Equal(t, ErrCodeUnknownTable, b.RemoveTable(DefaultTableID).(*GameError).Code)
+	assert.Equal(t, ErrCodeNotAllowed, b.RemoveTable("plo").(*GameError).Code)
+}
@@ -1,6 +1,7 @@
 package p2p
 
 import (
+	"bufio"
 	"encoding/gob"
 	"net"
 
@@ -16,6 +17,13 @@ type Peer struct {
 	conn       net.Conn
 	outbound   bool
 	listenAddr string
+	// dialAddr is the address we dialed, it can differ from the address the
+	// player advertises in the handshake.
+	dialAddr string
+	// r buffers the reads of the connection. Every message is gob encoded on
+	// its own, and a decoder on the bare connection reads past the end of its
+	// message, so the decoders of the messages share one buffer.
+	r *bufio.Reader
 }
 
 func (p *Peer) Send(b []byte) error {
@@ -23,10 +31,20 @@ func (p *Peer) Send(b []byte) error {
 	return err
 }
 
-func (p *Peer) ReadLoop(msgch chan *Message) {
+// decode reads the next message of the peer into v.
+func (p *Peer) decode(v any) error {
+	if p.r == nil {
+		p.r = bufio.NewReader(p.conn)
+	}
+	return gob.NewDecoder(p.r).Decode(v)
+}
+
+// ReadLoop reads the messages of the peer until the connection is closed, the
+// peer is sent on delch when it is done.
+func (p *Peer) ReadLoop(msgch chan *Message, delch chan *Peer) {
 	for {
 		msg := new(Message)
-		if err := gob.NewDecoder(p.conn).Decode(msg); err != nil {
+		if err := p.decode(msg); err != nil {
 			logrus.Errorf("decode message error: %s", err)
 			break
 		}
@@ -34,8 +52,8 @@ func (p *Peer) ReadLoop(msgch chan *Message) {
 		msgch <- msg
 	}
 
-	// TODO(@anthdm): unregister this peer!!!
 	p.conn.Close()
+	delch <- p
 }
 
 type TCPTransport struct {
@@ -0,0 +1,600 @@
+package p2p
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultLevelHands is the number of hands played at each blind level when
+// neither the hands nor the duration of a level are configured.
+const defaultLevelHands = 10
+
+// defaultBlindLevels is the blind schedule of a tournament that is not
+// configured with one, as a percentage of the big blind of the game.
+var defaultBlindLevels = []int{100, 150, 200, 300, 400, 600, 800, 1000, 1500, 2000, 3000, 4000}
+
+// defaultAnteLevel is the first level of the default blind schedule that is
+// played with an ante of a tenth of the big blind.
+const defaultAnteLevel = 4
+
+// BlindLevel is a level of the blind schedule of a tournament.
+type BlindLevel struct {
+	// BigBlind is the big blind, the small blind is half of it. In fixed
+	// limit this is the small bet.
+	BigBlind int
+	// Ante is posted by every player that is dealt in. Zero means no ante.
+	Ante int
+}
+
+// apply returns the betting structure played at this level. The big bet and
+// bring-in keep the same size relative to the big blind.
+func (l BlindLevel) apply(bs BettingStructure) BettingStructure {
+	if bs.SmallBet > 0 {
+		bs.BigBet = bs.BigBet * l.BigBlind / bs.SmallBet
+		bs.BringIn = bs.BringIn * l.BigBlind / bs.SmallBet
+	}
+	bs.SmallBet = l.BigBlind
+	return bs
+}
+
+// Tournament is a sit-and-go. Every player buys in for the same amount and
+// starts with the same stack, the blinds go up on a schedule and the players
+// that lose their chips are eliminated until one player holds all of them.
+// Every player at the table needs to play the exact same tournament.
+type Tournament struct {
+	// BuyIn is what every player pays to enter, the prize pool is the sum of
+	// the buy-ins. It defaults to the starting stack.
+	BuyIn int
+	// StartingStack is the chips every player starts with.
+	StartingStack int
+	// Players is the number of players the tournament starts with, it
+	// defaults to the seats at the table.
+	Players int
+	// Levels is the blind schedule, the last level is played until the
+	// tournament is over.
+	Levels []BlindLevel
+	// LevelHands is the number of hands played at each level.
+	LevelHands int
+	// LevelDuration raises the blinds on a timer instead of after a number
+	// of hands. The next level starts with the first hand that is dealt after
+	// the time is up.
+	LevelDuration time.Duration
+	// Payouts is the percentage of the prize pool paid to each place,
+	// starting with the winner.
+	Payouts []int
+}
+
+func (t *Tournament) String() string {
+	every := fmt.Sprintf("%d hands", t.LevelHands)
+	if t.LevelDuration > 0 {
+		every = t.LevelDuration.String()
+	}
+
+	return fmt.Sprintf("buy-in %d, %d players, %d levels every %s, payouts %v", t.BuyIn, t.Players, len(t.Levels), every, t.Payouts)
+}
+
+// equal reports whether both are the same tournament, or both are nil.
+func (t *Tournament) equal(other *Tournament) bool {
+	if t == nil || other == nil {
+		return t == other
+	}
+	if t.BuyIn != other.BuyIn || t.StartingStack != other.StartingStack || t.Players != other.Players ||
+		t.LevelHands != other.LevelHands || t.LevelDuration != other.LevelDuration ||
+		len(t.Levels) != len(other.Levels) || len(t.Payouts) != len(other.Payouts) {
+		return false
+	}
+	for i := range t.Levels {
+		if t.Levels[i] != other.Levels[i] {
+			return false
+		}
+	}
+	for i := range t.Payouts {
+		if t.Payouts[i] != other.Payouts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidatePayouts checks the percentages of the prize pool paid to each
+// place. Every place gets a share and together they pay at most the prize
+// pool.
+func ValidatePayouts(payouts []int) error {
+	total := 0
+	for i, pct := range payouts {
+		if pct <= 0 {
+			return fmt.Errorf("the payout of place %d (%d%%) needs to be positive", i+1, pct)
+		}
+		total += pct
+	}
+	if total > 100 {
+		return fmt.Errorf("the payouts %v add up to %d%%, more than the prize pool", payouts, total)
+	}
+	return nil
+}
+
+// withDefaults fills in the parts of the tournament that are not set, the
+// blind schedule starts at the big blind of the game. Invalid payouts are
+// replaced by the default ones, the config is validated before.
+func (t Tournament) withDefaults(bigBlind, seats int) Tournament {
+	if len(t.Levels) == 0 {
+		for i, pct := range defaultBlindLevels {
+			level := BlindLevel{BigBlind: bigBlind * pct / 100}
+			if i >= defaultAnteLevel {
+				level.Ante = level.BigBlind / 10
+			}
+			t.Levels = append(t.Levels, level)
+		}
+	}
+	if t.LevelHands == 0 && t.LevelDuration == 0 {
+		t.LevelHands = defaultLevelHands
+	}
+	if t.StartingStack == 0 {
+		t.StartingStack = defaultStartingBlinds * t.Levels[0].BigBlind
+	}
+	if t.BuyIn == 0 {
+		t.BuyIn = t.StartingStack
+	}
+	if t.Players < 2 || t.Players > seats {
+		t.Players = seats
+	}
+	if err := ValidatePayouts(t.Payouts); err != nil {
+		logrus.Errorf("ignoring the payouts of the tournament: %s", err)
+		t.Payouts = nil
+	}
+	if len(t.Payouts) == 0 {
+		t.Payouts = defaultPayouts(t.Players)
+	}
+	return t
+}
+
+// defaultPayouts returns the percentages of the prize pool that are paid
+// when the tournament is not configured with them.
+func defaultPayouts(players int) []int {
+	switch {
+	case players <= 4:
+		return []int{100}
+	case players <= 6:
+		return []int{65, 35}
+	default:
+		return []int{50, 30, 20}
+	}
+}
+
+// TournamentResult is the finishing place of a player and the prize he won.
+type TournamentResult struct {
+	Addr  string `json:"addr"`
+	Place int    `json:"place"`
+	Prize int    `json:"prize,omitempty"`
+	// Deal is set when the prize was split in a deal, the players that made
+	// it are placed by their stacks.
+	Deal bool `json:"deal,omitempty"`
+}
+
+// TournamentFinishedEvent is published when a single player holds all the
+// chips.
+type TournamentFinishedEvent struct {
+	Results []TournamentResult `json:"results"`
+}
+
+// tournamentState keeps track of the blind level and the players that are
+// eliminated.
+type tournamentState struct {
+	Tournament
+
+	lock sync.RWMutex
+	// level is the index of the blind level of the current hand.
+	level int
+	// handsInLevel is the number of hands played at the current level.
+	handsInLevel int
+	// started is the time the first hand was dealt, it is zero while the
+	// players are registering.
+	started time.Time
+	// entrants are the players that are dealt in the first hand.
+	entrants []string
+	// results holds the players that are eliminated, and the winner once the
+	// tournament is finished.
+	results []TournamentResult
+	// deal is the deal that is proposed, or the deal that finished the
+	// tournament.
+	deal *Deal
+	// earlyAccepts holds the deals players accepted before they were
+	// proposed to us.
+	earlyAccepts map[string]*Deal
+}
+
+func newTournamentState(t Tournament) *tournamentState {
+	return &tournamentState{
+		Tournament:   t,
+		results:      []TournamentResult{},
+		earlyAccepts: make(map[string]*Deal),
+	}
+}
+
+// finished reports whether the tournament has a winner.
+func (ts *tournamentState) finished() bool {
+	for _, result := range ts.results {
+		if result.Place == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// prizePool returns the sum of the buy-ins of the players that entered.
+func (ts *tournamentState) prizePool() int {
+	return ts.BuyIn * len(ts.entrants)
+}
+
+// prize returns what the given place wins. The chips that are lost when the
+// shares are rounded go to the winner.
+func (ts *tournamentState) prize(place int) int {
+	if place > len(ts.Payouts) {
+		return 0
+	}
+
+	pool := ts.prizePool()
+	prize := pool * ts.Payouts[place-1] / 100
+	if place == 1 {
+		paid, total := 0, 0
+		for _, pct := range ts.Payouts {
+			paid += pool * pct / 100
+			total += pct
+		}
+		prize += pool*total/100 - paid
+	}
+	return prize
+}
+
+// blindLevel returns the blind level of the current hand, there is none in a
+// cash game.
+func (g *GameState) blindLevel() (BlindLevel, bool) {
+	if g.tournament == nil {
+		return BlindLevel{}, false
+	}
+
+	g.tournament.lock.RLock()
+	defer g.tournament.lock.RUnlock()
+
+	return g.tournament.Levels[g.tournament.level], true
+}
+
+// ante returns the ante every player posts in the current hand.
+func (g *GameState) ante() int {
+	if level, ok := g.blindLevel(); ok {
+		return level.Ante
+	}
+	return g.options.Ante
+}
+
+// nextLevel returns the blind level the next hand is played at. Just like the
+// game of a rotation, only the dealer decides when the blinds go up and the
+// other players follow the level he starts the hand with. In a multi-table
+// tournament every table plays at the level of the coordinator.
+func (g *GameState) nextLevel() int {
+	if g.tournament == nil {
+		return 0
+	}
+
+	ts := g.tournament
+	if g.coordinator != nil {
+		level := g.coordinator.Level()
+		if level >= len(ts.Levels) {
+			level = len(ts.Levels) - 1
+		}
+		return level
+	}
+
+	ts.lock.RLock()
+	defer ts.lock.RUnlock()
+
+	if ts.started.IsZero() {
+		return 0
+	}
+
+	level := ts.level
+	if ts.LevelDuration > 0 {
+		if byTime := int(time.Since(ts.started) / ts.LevelDuration); byTime > level {
+			level = byTime
+		}
+	} else if ts.handsInLevel >= ts.LevelHands {
+		level++
+	}
+	if level >= len(ts.Levels) {
+		level = len(ts.Levels) - 1
+	}
+	return level
+}
+
+// switchLevel starts a new hand at the given blind level.
+func (g *GameState) switchLevel(level int) error {
+	if g.tournament == nil {
+		return nil
+	}
+
+	ts := g.tournament
+	if level < 0 || level >= len(ts.Levels) {
+		return fmt.Errorf("blind level (%d) is not in the schedule", level)
+	}
+
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	if ts.level != level {
+		ts.level = level
+		ts.handsInLevel = 0
+
+		logrus.WithFields(logrus.Fields{
+			"we":    g.listenAddr,
+			"level": level + 1,
+			"blind": ts.Levels[level],
+		}).Info("blinds going up")
+	}
+	ts.handsInLevel++
+
+	return nil
+}
+
+// startTournament closes the registration once the first hand is dealt, the
+// players dealt in are the entrants.
+func (g *GameState) startTournament(order []string) {
+	if g.tournament == nil {
+		return
+	}
+
+	ts := g.tournament
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	if !ts.started.IsZero() {
+		return
+	}
+	ts.started = time.Now()
+	ts.entrants = append([]string{}, order...)
+	sort.Strings(ts.entrants)
+}
+
+// isRegistering reports whether players can still enter the tournament.
+func (g *GameState) isRegistering() bool {
+	g.tournament.lock.RLock()
+	defer g.tournament.lock.RUnlock()
+
+	return g.tournament.started.IsZero()
+}
+
+// hasEntrants reports whether enough players registered to start the
+// tournament, or whether it already started. The coordinator of a
+// multi-table tournament starts it for every table.
+func (g *GameState) hasEntrants(order []string) bool {
+	if g.tournament == nil || g.coordinator != nil || !g.isRegistering() {
+		return true
+	}
+	return len(order) >= g.tournament.Players
+}
+
+// validateEntry checks the chips a player wants to buy in a tournament. The
+// players only buy in once, before the first hand is dealt.
+func (g *GameState) validateEntry(addr string, typ LedgerEntryType) error {
+	if typ != LedgerBuyIn {
+		return newGameError(ErrCodeNotAllowed, "%s is not allowed in a tournament", typ)
+	}
+	if !g.isRegistering() {
+		return newGameError(ErrCodeNotAllowed, "the registration of the tournament is closed")
+	}
+	if len(g.betting.stackSnapshot()) >= g.tournament.Players && !g.betting.hasStack(addr) {
+		return newGameError(ErrCodeNotAllowed, "the tournament is full")
+	}
+	return nil
+}
+
+// eliminateBustedPlayers gives the players that lost all their chips in the
+// hand their finishing place and frees their seats. Of the players that bust
+// in the same hand, the one that started it with the most chips finishes
+// higher. Once a single player is left the prizes are paid. In a multi-table
+// tournament the coordinator gives the places, the dealer of the hand reports
+// the players that busted to it.
+func (g *GameState) eliminateBustedPlayers(wasDealer bool) {
+	if g.tournament == nil {
+		return
+	}
+
+	busted := g.betting.busted()
+	if g.coordinator != nil {
+		for addr := range busted {
+			g.betting.standUp(addr)
+			g.table.release(addr)
+			g.publish(EventPlayerEliminated, TournamentResult{Addr: addr})
+		}
+		if wasDealer {
+			g.reportHand(busted)
+		}
+		return
+	}
+
+	addrs := finishingOrder(busted)
+
+	ts := g.tournament
+	ts.lock.Lock()
+	left := len(ts.entrants) - len(ts.results)
+	eliminated := []TournamentResult{}
+	for _, addr := range addrs {
+		result := TournamentResult{Addr: addr, Place: left, Prize: ts.prize(left)}
+		ts.results = append(ts.results, result)
+		eliminated = append(eliminated, result)
+		left--
+	}
+
+	var winner *TournamentResult
+	if left == 1 {
+		for _, addr := range ts.entrants {
+			if g.betting.stack(addr) > 0 {
+				winner = &TournamentResult{Addr: addr, Place: 1, Prize: ts.prize(1)}
+				ts.results = append(ts.results, *winner)
+				break
+			}
+		}
+	}
+	ts.lock.Unlock()
+
+	for _, result := range eliminated {
+		logrus.WithFields(logrus.Fields{
+			"we":     g.listenAddr,
+			"player": result.Addr,
+			"place":  result.Place,
+		}).Info("player eliminated")
+
+		g.betting.standUp(result.Addr)
+		g.table.release(result.Addr)
+		g.publish(EventPlayerEliminated, result)
+	}
+	if winner != nil {
+		g.finishTournament()
+	}
+}
+
+// finishTournament pays the prizes once the tournament has a winner.
+func (g *GameState) finishTournament() {
+	results := g.TournamentResults()
+	for _, result := range results {
+		if result.Prize > 0 {
+			g.ledger.record(LedgerEntry{
+				Time:   time.Now(),
+				Addr:   result.Addr,
+				Type:   LedgerPayout,
+				Amount: result.Prize,
+			})
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"we":     g.listenAddr,
+		"winner": results[0].Addr,
+	}).Info("tournament finished")
+
+	g.publish(EventTournamentFinished, TournamentFinishedEvent{Results: results})
+}
+
+// finishingOrder sorts the players that bust at the same time by the chips
+// they had, the player that finishes last comes first.
+func finishingOrder(busted map[string]int) []string {
+	addrs := make([]string, 0, len(busted))
+	for addr := range busted {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		if busted[addrs[i]] != busted[addrs[j]] {
+			return busted[addrs[i]] < busted[addrs[j]]
+		}
+		return addrs[i] > addrs[j]
+	})
+	return addrs
+}
+
+// TournamentResults returns the finishing places of the players that are
+// eliminated so far, starting with the best place.
+func (g *GameState) TournamentResults() []TournamentResult {
+	if g.tournament == nil {
+		return nil
+	}
+
+	g.tournament.lock.RLock()
+	defer g.tournament.lock.RUnlock()
+
+	results := append([]TournamentResult{}, g.tournament.results...)
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Place < results[j].Place
+	})
+	return results
+}
+
+// TournamentState is the progress of the tournament played at the table.
+type TournamentState struct {
+	// Level is the blind level, the first level is 1.
+	Level      int `json:"level"`
+	SmallBlind int `json:"smallBlind"`
+	BigBlind   int `json:"bigBlind"`
+	Ante       int `json:"ante,omitempty"`
+	// HandsToNextLevel is the number of hands left at this level, and
+	// TimeToNextLevel the milliseconds left when the blinds go up on a timer.
+	// Both are zero at the last level.
+	HandsToNextLevel int `json:"handsToNextLevel,omitempty"`
+	TimeToNextLevel  int `json:"timeToNextLevel,omitempty"`
+	BuyIn            int `json:"buyIn"`
+	PrizePool        int `json:"prizePool"`
+	// Entrants is the number of players that entered, and Players the number
+	// the tournament starts with.
+	Entrants    int  `json:"entrants"`
+	Players     int  `json:"players"`
+	PlayersLeft int  `json:"playersLeft"`
+	Started     bool `json:"started"`
+	Finished    bool `json:"finished"`
+	// Deal is the deal that is proposed, or the deal the players made.
+	Deal *Deal `json:"deal,omitempty"`
+	// Results are the finishing places of the players that are eliminated,
+	// starting with the best place.
+	Results []TournamentResult `json:"results"`
+}
+
+// tournamentProgress returns the progress of the tournament, there is none in
+// a cash game.
+func (g *GameState) tournamentProgress() *TournamentState {
+	if g.tournament == nil {
+		return nil
+	}
+
+	results := g.TournamentResults()
+	bs := g.structure()
+
+	ts := g.tournament
+	ts.lock.RLock()
+	defer ts.lock.RUnlock()
+
+	state := &TournamentState{
+		Level:      ts.level + 1,
+		SmallBlind: bs.smallBlind(),
+		BigBlind:   bs.bigBlind(),
+		Ante:       ts.Levels[ts.level].Ante,
+		BuyIn:      ts.BuyIn,
+		PrizePool:  ts.prizePool(),
+		Entrants:   len(ts.entrants),
+		Players:    ts.Players,
+		Started:    !ts.started.IsZero(),
+		Results:    results,
+	}
+	state.PlayersLeft = state.Entrants - len(ts.results)
+	for _, result := range ts.results {
+		if result.Place == 1 {
+			state.Finished = true
+		}
+		if result.Place == 1 || result.Deal {
+			state.PlayersLeft++
+		}
+	}
+	if ts.deal != nil {
+		deal := *ts.deal
+		deal.Accepted = append([]string{}, deal.Accepted...)
+		state.Deal = &deal
+	}
+	if !state.Started {
+		state.Entrants = len(g.betting.stackSnapshot())
+		state.PrizePool = ts.BuyIn * state.Entrants
+		state.PlayersLeft = state.Entrants
+	}
+
+	if ts.level < len(ts.Levels)-1 && state.Started {
+		if ts.LevelDuration > 0 {
+			next := ts.started.Add(time.Duration(ts.level+1) * ts.LevelDuration)
+			if left := time.Until(next); left > 0 {
+				state.TimeToNextLevel = int(left.Milliseconds())
+			}
+		} else if left := ts.LevelHands - ts.handsInLevel; left > 0 {
+			state.HandsToNextLevel = left
+		}
+	}
+
+	return state
+}
@@ -0,0 +1,189 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTournamentGame(players int) (*GameState, chan BroadcastTo) {
+	broadcastch := make(chan BroadcastTo, 10)
+	cfg := ServerConfig{
+		AdvertiseAddr: "a.example.com:3000",
+		Rotation:      Rotation{Games: []Game{{GameVariant: TexasHoldem}}}.withDefaults(),
+		TableOptions: TableOptions{
+			StartingStack: 1500,
+			MinBuyIn:      100,
+			MaxBuyIn:      100,
+		},
+		Tournament: &Tournament{
+			BuyIn:         100,
+			StartingStack: 1500,
+			Players:       players,
+			Levels:        []BlindLevel{{BigBlind: 20}, {BigBlind: 40, Ante: 5}},
+			LevelHands:    2,
+			Payouts:       []int{70, 30},
+		},
+	}
+	return NewGame(cfg, broadcastch), broadcastch
+}
+
+func TestTournamentDefaults(t *testing.T) {
+	tournament := Tournament{}.withDefaults(10, 9)
+	assert.Equal(t, BlindLevel{BigBlind: 10}, tournament.Levels[0])
+	assert.Equal(t, BlindLevel{BigBlind: 60, Ante: 6}, tournament.Levels[5])
+	assert.Equal(t, 10, tournament.LevelHands)
+	assert.Equal(t, 1000, tournament.StartingStack)
+	assert.Equal(t, 1000, tournament.BuyIn)
+	assert.Equal(t, 9, tournament.Players)
+	assert.Equal(t, []int{50, 30, 20}, tournament.Payouts)
+
+	sixMax := Tournament{Players: 6, LevelDuration: 1}.withDefaults(10, 9)
+	assert.Equal(t, 0, sixMax.LevelHands)
+	assert.Equal(t, []int{65, 35}, sixMax.Payouts)
+
+	// Invalid payouts are never paid.
+	assert.NotNil(t, ValidatePayouts([]int{60, 50}))
+	assert.NotNil(t, ValidatePayouts([]int{100, 0}))
+	assert.NotNil(t, ValidatePayouts([]int{120, -20}))
+	assert.Nil(t, ValidatePayouts([]int{60, 30}))
+	assert.Equal(t, []int{65, 35}, Tournament{Players: 6, Payouts: []int{120, -20}}.withDefaults(10, 9).Payouts)
+	assert.NotNil(t, ServerConfig{Tables: []ServerConfig{{TableID: "mtt", Tournament: &Tournament{Payouts: []int{60, 50}}}}}.Validate())
+
+	var cashGame *Tournament
+	assert.True(t, cashGame.equal(nil))
+	assert.False(t, cashGame.equal(&tournament))
+	assert.True(t, tournament.equal(&tournament))
+	assert.False(t, tournament.equal(&sixMax))
+}
+
+func TestTournamentRegistration(t *testing.T) {
+	g, broadcastch := newTournamentGame(3)
+
+	assert.Equal(t, ErrCodeIllegalAmount, g.BuyIn(50).(*GameError).Code)
+	assert.Nil(t, g.SetReady())
+	msg := <-broadcastch
+	assert.Equal(t, MessageReady{Seat: 0, BuyIn: 100}, msg.Payload)
+	assert.Equal(t, 1500, g.betting.stack(g.listenAddr))
+
+	entry := g.Ledger().Entries[0]
+	assert.Equal(t, LedgerBuyIn, entry.Type)
+	assert.Equal(t, 100, entry.Amount)
+	assert.Equal(t, 1500, entry.Stack)
+
+	assert.Equal(t, ErrCodeNotAllowed, g.TopUp(100).(*GameError).Code)
+	assert.Equal(t, ErrCodeNotAllowed, g.CashOut().(*GameError).Code)
+	assert.Equal(t, ErrCodeNotAllowed, g.SitOut().(*GameError).Code)
+
+	g.SetPlayerReady("b.example.com:3000", 1, 100)
+	assert.False(t, g.hasEntrants(g.handOrder()))
+	g.SetPlayerReady("c.example.com:3000", 2, 100)
+	assert.True(t, g.hasEntrants(g.handOrder()))
+
+	// The tournament is full.
+	assert.Equal(t, ErrCodeNotAllowed, g.validateChips("d.example.com:3000", LedgerBuyIn, 100).(*GameError).Code)
+
+	state := g.State().Tournament
+	assert.Equal(t, 3, state.Entrants)
+	assert.Equal(t, 300, state.PrizePool)
+	assert.False(t, state.Started)
+
+	g.startTournament(g.handOrder())
+	assert.Equal(t, ErrCodeNotAllowed, g.validateChips("d.example.com:3000", LedgerBuyIn, 100).(*GameError).Code)
+	assert.True(t, g.State().Tournament.Started)
+}
+
+func TestBlindLevels(t *testing.T) {
+	g, _ := newTournamentGame(2)
+
+	assert.Equal(t, 0, g.nextLevel())
+	g.startTournament([]string{"a.example.com:3000", "b.example.com:3000"})
+
+	for hand := 0; hand < 2; hand++ {
+		level := g.nextLevel()
+		assert.Equal(t, 0, level)
+		assert.Nil(t, g.switchLevel(level))
+		assert.Equal(t, 1-hand, g.State().Tournament.HandsToNextLevel)
+	}
+	assert.Equal(t, 20, g.structure().bigBlind())
+	assert.Equal(t, 0, g.ante())
+
+	assert.Equal(t, 1, g.nextLevel())
+	assert.Nil(t, g.switchLevel(1))
+	assert.Equal(t, 40, g.structure().bigBlind())
+	assert.Equal(t, 20, g.structure().smallBlind())
+	assert.Equal(t, 5, g.ante())
+
+	// The last level is played until the tournament is over.
+	assert.Nil(t, g.switchLevel(1))
+	assert.Nil(t, g.switchLevel(1))
+	assert.Equal(t, 1, g.nextLevel())
+	assert.Equal(t, 0, g.State().Tournament.HandsToNextLevel)
+	assert.Equal(t, 2, g.State().Tournament.Level)
+	assert.NotNil(t, g.switchLevel(2))
+}
+
+func TestEliminationsAndPayouts(t *testing.T) {
+	var (
+		a = "a.example.com:3000"
+		b = "b.example.com:3000"
+		c = "c.example.com:3000"
+		d = "d.example.com:3000"
+	)
+	g, broadcastch := newTournamentGame(4)
+	assert.Nil(t, g.SetReady())
+	<-broadcastch
+	g.SetPlayerReady(b, 1, 100)
+	g.SetPlayerReady(c, 2, 100)
+	g.SetPlayerReady(d, 3, 100)
+	order := []string{a, b, c, d}
+	g.startTournament(order)
+
+	// d loses all his chips to b.
+	g.betting.reset(order)
+	g.betting.takeLocked(d, 1500)
+	g.betting.award(b, 1500)
+	g.eliminateBustedPlayers(true)
+	assert.Equal(t, []TournamentResult{{Addr: d, Place: 4}}, g.TournamentResults())
+	_, err := g.table.GetPlayer(d)
+	assert.NotNil(t, err)
+	assert.False(t, g.betting.hasStack(d))
+
+	// b and c both lose to a, b started the hand with more chips.
+	order = []string{a, b, c}
+	g.betting.reset(order)
+	g.betting.takeLocked(b, 3000)
+	g.betting.takeLocked(c, 1500)
+	g.betting.award(a, 4500)
+	g.eliminateBustedPlayers(true)
+
+	assert.Equal(t, []TournamentResult{
+		{Addr: a, Place: 1, Prize: 280},
+		{Addr: b, Place: 2, Prize: 120},
+		{Addr: c, Place: 3},
+		{Addr: d, Place: 4},
+	}, g.TournamentResults())
+
+	state := g.State().Tournament
+	assert.True(t, state.Finished)
+	assert.Equal(t, 1, state.PlayersLeft)
+	assert.Equal(t, 400, state.PrizePool)
+
+	balances := map[string]LedgerBalance{}
+	for _, balance := range g.Ledger().Balances {
+		balances[balance.Addr] = balance
+	}
+	assert.Equal(t, 180, balances[a].Net)
+	assert.Equal(t, 20, balances[b].Net)
+	assert.Equal(t, -100, balances[c].Net)
+}
+
+func TestPrizeRounding(t *testing.T) {
+	ts := newTournamentState(Tournament{BuyIn: 33, Payouts: []int{50, 30, 20}})
+	ts.entrants = []string{"a", "b", "c"}
+
+	assert.Equal(t, 51, ts.prize(1))
+	assert.Equal(t, 29, ts.prize(2))
+	assert.Equal(t, 19, ts.prize(3))
+	assert.Equal(t, 0, ts.prize(4))
+}
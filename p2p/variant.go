@@ -0,0 +1,264 @@
+package p2p
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anthdm/ggpoker/deck"
+)
+
+type GameVariant uint8
+
+func (gv GameVariant) String() string {
+	switch gv {
+	case TexasHoldem:
+		return "TEXAS HOLDEM"
+	case Omaha:
+		return "OMAHA"
+	case SevenCardStud:
+		return "SEVEN CARD STUD"
+	case Razz:
+		return "RAZZ"
+	case DeuceToSevenTripleDraw:
+		return "2-7 TRIPLE DRAW"
+	case OmahaHiLo:
+		return "OMAHA HI-LO"
+	case SevenCardStudHiLo:
+		return "SEVEN CARD STUD HI-LO"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	TexasHoldem GameVariant = iota
+	Omaha
+	SevenCardStud
+	Razz
+	DeuceToSevenTripleDraw
+	OmahaHiLo
+	SevenCardStudHiLo
+)
+
+// street describes the cards that are dealt before a betting round.
+type street struct {
+	status GameStatus
+	// down and up are the number of private and face up cards each player
+	// still in the hand is dealt.
+	down, up int
+	// board is the number of community cards that are dealt.
+	board int
+	// bigBet reports whether the bets are of the big bet size in fixed limit.
+	bigBet bool
+	// draw reports whether the players can replace some of their cards
+	// before the betting round.
+	draw bool
+}
+
+var (
+	holdemStreets = []street{
+		{status: GameStatusPreFlop, down: 2},
+		{status: GameStatusFlop, board: 3},
+		{status: GameStatusTurn, board: 1, bigBet: true},
+		{status: GameStatusRiver, board: 1, bigBet: true},
+	}
+	omahaStreets = []street{
+		{status: GameStatusPreFlop, down: 4},
+		{status: GameStatusFlop, board: 3},
+		{status: GameStatusTurn, board: 1, bigBet: true},
+		{status: GameStatusRiver, board: 1, bigBet: true},
+	}
+	studStreets = []street{
+		{status: GameStatusThirdStreet, down: 2, up: 1},
+		{status: GameStatusFourthStreet, up: 1},
+		{status: GameStatusFifthStreet, up: 1, bigBet: true},
+		{status: GameStatusSixthStreet, up: 1, bigBet: true},
+		{status: GameStatusSeventhStreet, down: 1, bigBet: true},
+	}
+	tripleDrawStreets = []street{
+		{status: GameStatusPreDraw, down: 5},
+		{status: GameStatusFirstDraw, draw: true},
+		{status: GameStatusSecondDraw, draw: true, bigBet: true},
+		{status: GameStatusThirdDraw, draw: true, bigBet: true},
+	}
+)
+
+// streets returns the betting rounds of the variant in the order they are played.
+func (gv GameVariant) streets() []street {
+	switch gv {
+	case Omaha, OmahaHiLo:
+		return omahaStreets
+	case SevenCardStud, Razz, SevenCardStudHiLo:
+		return studStreets
+	case DeuceToSevenTripleDraw:
+		return tripleDrawStreets
+	default:
+		return holdemStreets
+	}
+}
+
+// street returns the street that belongs to the given status.
+func (gv GameVariant) street(status GameStatus) (street, bool) {
+	for _, st := range gv.streets() {
+		if st.status == status {
+			return st, true
+		}
+	}
+	return street{}, false
+}
+
+// holeCards returns the amount of private cards that are dealt to each player.
+func (gv GameVariant) holeCards() int {
+	n := 0
+	for _, st := range gv.streets() {
+		n += st.down
+	}
+	return n
+}
+
+// hasBoard reports whether the variant is played with community cards.
+func (gv GameVariant) hasBoard() bool {
+	for _, st := range gv.streets() {
+		if st.board > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasDraws reports whether the players can replace their cards during the hand.
+func (gv GameVariant) hasDraws() bool {
+	for _, st := range gv.streets() {
+		if st.draw {
+			return true
+		}
+	}
+	return false
+}
+
+// maxPlayers returns the number of seats the variant can be played with,
+// zero means there is no limit. The discards of a draw game can not be
+// reshuffled into the deck, that would need every player to give up his key,
+// so the cards left after the deal need to replace the whole hand of every
+// player once.
+func (gv GameVariant) maxPlayers() int {
+	if !gv.hasDraws() {
+		return 0
+	}
+	return len(deck.New()) / (2 * gv.holeCards())
+}
+
+// isStud reports whether the variant is played with face up cards, a
+// bring-in and action order by the best visible hand.
+func (gv GameVariant) isStud() bool {
+	return gv == SevenCardStud || gv == Razz || gv == SevenCardStudHiLo
+}
+
+// isHiLo reports whether the pots are split between the best high hand and
+// the best qualifying low hand.
+func (gv GameVariant) isHiLo() bool {
+	return gv == OmahaHiLo || gv == SevenCardStudHiLo
+}
+
+// isLowball reports whether the lowest hand wins the pot.
+func (gv GameVariant) isLowball() bool {
+	return gv == Razz || gv == DeuceToSevenTripleDraw
+}
+
+// beats reports whether hand a wins against hand b.
+func (gv GameVariant) beats(a, b deck.HandValue) bool {
+	if gv.isLowball() {
+		return a < b
+	}
+	return a > b
+}
+
+// visibleHand scores the face up cards of a player in stud games.
+func (gv GameVariant) visibleHand(up []deck.Card) deck.HandValue {
+	if gv == Razz {
+		return deck.EvaluateAceToFive(up)
+	}
+	return deck.Evaluate(up)
+}
+
+// defaultBettingStructure returns the structure the variant is usually played with.
+func (gv GameVariant) defaultBettingStructure() BettingStructure {
+	limit := NoLimit
+	switch gv {
+	case Omaha:
+		limit = PotLimit
+	case SevenCardStud, Razz, DeuceToSevenTripleDraw, OmahaHiLo, SevenCardStudHiLo:
+		limit = FixedLimit
+	}
+
+	return BettingStructure{
+		Limit:    limit,
+		SmallBet: defaultSmallBet,
+		BigBet:   2 * defaultSmallBet,
+	}
+}
+
+// bestHand evaluates the strongest hand a player can make with his own cards
+// and the community cards on the board. In lowball games the strongest hand
+// has the lowest value.
+func (gv GameVariant) bestHand(hole, board []deck.Card) (deck.HandValue, []deck.Card) {
+	switch gv {
+	case Omaha, OmahaHiLo:
+		// In omaha a player must use exactly two of his hole cards.
+		return deck.BestOmahaHand(hole, board)
+	case Razz:
+		return deck.BestAceToFiveLow(append(append([]deck.Card{}, hole...), board...))
+	case DeuceToSevenTripleDraw:
+		return deck.BestDeuceToSevenLow(hole)
+	default:
+		return deck.BestHand(append(append([]deck.Card{}, hole...), board...))
+	}
+}
+
+// bestLow evaluates the best eight or better low hand for the low half of the
+// pot in hi-lo games. It returns false when the player has no qualifying low.
+func (gv GameVariant) bestLow(hole, board []deck.Card) (deck.HandValue, bool) {
+	var (
+		value deck.HandValue
+		ok    bool
+	)
+	switch gv {
+	case OmahaHiLo:
+		value, _, ok = deck.BestOmahaEightOrBetterLow(hole, board)
+	case SevenCardStudHiLo:
+		value, _, ok = deck.BestEightOrBetterLow(append(append([]deck.Card{}, hole...), board...))
+	}
+	return value, ok
+}
+
+// ParseGameVariant returns the variant with the given name. Names are matched
+// without case and with dashes for spaces, like texas-holdem or 2-7-triple-draw.
+func ParseGameVariant(name string) (GameVariant, error) {
+	for gv := TexasHoldem; gv <= SevenCardStudHiLo; gv++ {
+		if normalizeName(gv.String()) == normalizeName(name) {
+			return gv, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown game variant (%s)", name)
+}
+
+// MarshalText encodes the variant by its name, so it can be read back with
+// UnmarshalText.
+func (gv GameVariant) MarshalText() ([]byte, error) {
+	return []byte(gv.String()), nil
+}
+
+func (gv *GameVariant) UnmarshalText(text []byte) error {
+	v, err := ParseGameVariant(string(text))
+	if err != nil {
+		return err
+	}
+	*gv = v
+	return nil
+}
+
+// normalizeName makes names that are written in different styles comparable.
+func normalizeName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", "-", "_", "-").Replace(name)
+}
@@ -0,0 +1,308 @@
+// Package tui is a terminal client to play at a table through the API of a
+// node.
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthdm/ggpoker/client"
+	"github.com/anthdm/ggpoker/deck"
+	"github.com/anthdm/ggpoker/p2p"
+	"golang.org/x/term"
+)
+
+// refreshRate is how often the screen is drawn to update the action timer.
+const refreshRate = 200 * time.Millisecond
+
+const (
+	keyCtrlC     = 3
+	keyBackspace = 8
+	keyEnter     = 13
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// UI renders the table and takes the actions of the player on key presses.
+type UI struct {
+	client *client.Client
+
+	state *p2p.State
+	// received is the time the state was received, the action timer counts
+	// down from it.
+	received time.Time
+	// betting is set while the amount of a bet is typed in.
+	betting bool
+	amount  string
+	// message is the result of the last action, like an error of the API.
+	message string
+}
+
+func New(c *client.Client) *UI {
+	return &UI{client: c}
+}
+
+// Run plays in the terminal until the player quits or the context is done.
+func (ui *UI) Run(ctx context.Context) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return errors.New("the terminal UI needs to run in a terminal")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events, err := ui.client.Events(ctx)
+	if err != nil {
+		return err
+	}
+
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, oldState)
+
+	// Hide the cursor while playing and clear the screen when we are done.
+	fmt.Print("\x1b[?25l")
+	defer fmt.Print("\x1b[H\x1b[2J\x1b[?25h")
+
+	keys := make(chan byte)
+	go readKeys(os.Stdin, keys)
+
+	ticker := time.NewTicker(refreshRate)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return errors.New("lost the connection to the node")
+			}
+			if state, ok := event.Data.(*p2p.State); ok {
+				ui.setState(state)
+			} else if state, err := ui.client.State(ctx); err == nil {
+				ui.setState(state)
+			}
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			if quit := ui.handleKey(ctx, key); quit {
+				return nil
+			}
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		ui.render(os.Stdout, time.Now())
+	}
+}
+
+func readKeys(r io.Reader, keys chan<- byte) {
+	buf := make([]byte, 1)
+	for {
+		if _, err := r.Read(buf); err != nil {
+			close(keys)
+			return
+		}
+		keys <- buf[0]
+	}
+}
+
+func (ui *UI) setState(state *p2p.State) {
+	ui.state = state
+	ui.received = time.Now()
+}
+
+// handleKey takes the action of the shortcut and reports whether the player
+// wants to quit.
+func (ui *UI) handleKey(ctx context.Context, key byte) bool {
+	if key == keyCtrlC {
+		return true
+	}
+
+	if ui.betting {
+		switch {
+		case key >= '0' && key <= '9':
+			ui.amount += string(key)
+		case key == keyBackspace || key == keyDelete:
+			if len(ui.amount) > 0 {
+				ui.amount = ui.amount[:len(ui.amount)-1]
+			}
+		case key == keyEnter:
+			ui.betting = false
+			value, err := strconv.Atoi(ui.amount)
+			if err != nil {
+				ui.message = "invalid amount"
+				return false
+			}
+			ui.takeAction(ui.client.Bet(ctx, value))
+		case key == keyEscape:
+			ui.betting = false
+		}
+		return false
+	}
+
+	switch key {
+	case 'q':
+		return true
+	case 'r':
+		ui.takeAction(ui.client.Ready(ctx))
+	case 'f':
+		ui.takeAction(ui.client.Fold(ctx))
+	case 'k':
+		ui.takeAction(ui.client.Check(ctx))
+	case 'c':
+		ui.takeAction(ui.client.Call(ctx))
+	case 'b':
+		ui.betting = true
+		ui.amount = ""
+		if action, ok := ui.legalAction(p2p.PlayerActionBet.String()); ok {
+			ui.amount = strconv.Itoa(action.Min)
+		}
+	}
+	return false
+}
+
+func (ui *UI) takeAction(state *p2p.State, err error) {
+	if err != nil {
+		ui.message = err.Error()
+		return
+	}
+	ui.message = ""
+	ui.setState(state)
+}
+
+func (ui *UI) legalAction(name string) (p2p.LegalAction, bool) {
+	if ui.state == nil {
+		return p2p.LegalAction{}, false
+	}
+	for _, action := range ui.state.LegalActions {
+		if action.Action == name {
+			return action, true
+		}
+	}
+	return p2p.LegalAction{}, false
+}
+
+// render draws the whole screen. The terminal is in raw mode, so every line
+// needs a carriage return.
+func (ui *UI) render(w io.Writer, now time.Time) {
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+
+	line := func(format string, args ...any) {
+		fmt.Fprintf(&b, format+"\r\n", args...)
+	}
+
+	s := ui.state
+	if s == nil {
+		line("connecting...")
+		fmt.Fprint(w, b.String())
+		return
+	}
+
+	line("%s - %s", s.Game, s.Status)
+	line("")
+	line("Board: %-20s Pot: %d", cards(s.Board), s.Pot)
+	line("")
+	line("   SEAT  PLAYER                 STACK    BET  CARDS")
+	for _, seat := range s.Seats {
+		marker := " "
+		if seat.Addr == s.CurrentPlayer {
+			marker = ">"
+		}
+
+		name := seat.Addr
+		if seat.Addr == s.Dealer {
+			name += " (D)"
+		}
+		if seat.Addr == s.Addr {
+			name += " (you)"
+		}
+
+		hand := cards(seat.UpCards)
+		if !seat.InHand {
+			hand = "folded"
+		}
+		line(" %s %4d  %-20s %7d %6d  %s", marker, seat.Seat, name, seat.Stack, seat.Bet, hand)
+	}
+	line("")
+	line("Your cards: %s", cards(s.HoleCards))
+	line("")
+
+	if s.CurrentPlayer != "" {
+		who := "Waiting for " + s.CurrentPlayer
+		if s.CurrentPlayer == s.Addr {
+			who = "Your turn"
+		}
+		if left := ui.timeToAct(now); left >= 0 {
+			who += fmt.Sprintf(" (%ds left)", int(left.Round(time.Second).Seconds()))
+		}
+		line(who)
+	}
+	line(ui.shortcuts())
+
+	if ui.betting {
+		line("Bet: %s_  [enter] bet [esc] cancel", ui.amount)
+	}
+	if ui.message != "" {
+		line("")
+		line("%s", ui.message)
+	}
+
+	fmt.Fprint(w, b.String())
+}
+
+// timeToAct returns the time the current player has left, it is negative when
+// the table plays without an action timer.
+func (ui *UI) timeToAct(now time.Time) time.Duration {
+	if ui.state.TimeToAct == 0 {
+		return -1
+	}
+	left := time.Duration(ui.state.TimeToAct)*time.Millisecond - now.Sub(ui.received)
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+func (ui *UI) shortcuts() string {
+	keys := []string{}
+	for _, action := range ui.state.LegalActions {
+		switch action.Action {
+		case p2p.PlayerActionFold.String():
+			keys = append(keys, "[f]old")
+		case p2p.PlayerActionCheck.String():
+			keys = append(keys, "[k] check")
+		case p2p.PlayerActionCall.String():
+			keys = append(keys, fmt.Sprintf("[c]all %d", action.Min))
+		case p2p.PlayerActionBet.String():
+			keys = append(keys, fmt.Sprintf("[b]et %d-%d", action.Min, action.Max))
+		}
+	}
+	keys = append(keys, "[r]eady", "[q]uit")
+	return strings.Join(keys, "  ")
+}
+
+// cards writes the cards with the unicode symbol of their suit, like A♠ K♥.
+func cards(states []p2p.CardState) string {
+	strs := make([]string, 0, len(states))
+	for _, cs := range states {
+		suit, err := deck.ParseSuit(cs.Suit)
+		if err != nil {
+			strs = append(strs, "??")
+			continue
+		}
+		strs = append(strs, deck.Card{Suit: suit, Value: cs.Value}.Short())
+	}
+	return strings.Join(strs, " ")
+}
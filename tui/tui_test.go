@@ -0,0 +1,69 @@
+package tui
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthdm/ggpoker/p2p"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	now := time.Now()
+	ui := &UI{
+		received: now,
+		state: &p2p.State{
+			Addr:          ":3000",
+			Game:          "NO LIMIT TEXAS HOLDEM",
+			Status:        "FLOP",
+			Dealer:        ":4000",
+			CurrentPlayer: ":3000",
+			TimeToAct:     12000,
+			Pot:           60,
+			Board:         []p2p.CardState{{Suit: "SPADES", Value: 1}, {Suit: "HARTS", Value: 10}, {Suit: "CLUBS", Value: 7}},
+			HoleCards:     []p2p.CardState{{Suit: "DIAMONDS", Value: 13}, {Suit: "DIAMONDS", Value: 12}},
+			Seats: []p2p.SeatState{
+				{Seat: 0, Addr: ":3000", Stack: 970, InHand: true},
+				{Seat: 1, Addr: ":4000", Stack: 970, InHand: true},
+			},
+			LegalActions: []p2p.LegalAction{{Action: "FOLD"}, {Action: "CHECK"}, {Action: "BET", Min: 20, Max: 970}},
+		},
+	}
+
+	var b strings.Builder
+	ui.render(&b, now.Add(2*time.Second))
+	screen := b.String()
+
+	assert.Contains(t, screen, "A♠ T♥ 7♣")
+	assert.Contains(t, screen, "K♦ Q♦")
+	assert.Contains(t, screen, ":3000 (you)")
+	assert.Contains(t, screen, ":4000 (D)")
+	assert.Contains(t, screen, "Your turn (10s left)")
+	assert.Contains(t, screen, "[k] check")
+	assert.Contains(t, screen, "[b]et 20-970")
+	assert.NotContains(t, screen, "[c]all")
+}
+
+func TestBetShortcut(t *testing.T) {
+	ui := &UI{
+		state: &p2p.State{LegalActions: []p2p.LegalAction{{Action: "BET", Min: 20, Max: 970}}},
+	}
+	ctx := context.Background()
+
+	// The amount starts at the minimum bet.
+	assert.False(t, ui.handleKey(ctx, 'b'))
+	assert.True(t, ui.betting)
+	assert.Equal(t, "20", ui.amount)
+
+	ui.handleKey(ctx, '0')
+	ui.handleKey(ctx, keyDelete)
+	ui.handleKey(ctx, keyDelete)
+	ui.handleKey(ctx, '5')
+	assert.Equal(t, "25", ui.amount)
+
+	ui.handleKey(ctx, keyEscape)
+	assert.False(t, ui.betting)
+	assert.True(t, ui.handleKey(ctx, 'q'))
+}